@@ -0,0 +1,75 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"math"
+	"strings"
+)
+
+var ErrEmbeddingsUnsupported = errors.New("AI provider does not support embeddings")
+
+// Embedder is implemented by providers that can turn text into vectors
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Embed returns one vector per text, or ErrEmbeddingsUnsupported when p cannot embed
+func Embed(ctx context.Context, p Provider, texts []string) ([][]float32, error) {
+	e, ok := p.(Embedder)
+	if !ok {
+		return nil, ErrEmbeddingsUnsupported
+	}
+	return e.Embed(ctx, texts)
+}
+
+func CosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+func (t *timeoutProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return Embed(ctx, t.Provider, texts)
+}
+
+func (m *Meter) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return Embed(ctx, m.Provider, texts)
+}
+
+const fakeEmbeddingSize = 256
+
+// Embed hashes character trigrams into a fixed-size vector, so texts that
+// share wording are similar without calling a model
+func (f *FakeProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		v := make([]float32, fakeEmbeddingSize)
+		padded := "  " + strings.ToLower(text) + " "
+		for j := 0; j+3 <= len(padded); j++ {
+			h := fnv.New32a()
+			h.Write([]byte(padded[j : j+3]))
+			v[h.Sum32()%fakeEmbeddingSize]++
+		}
+		vectors[i] = v
+	}
+	return vectors, nil
+}
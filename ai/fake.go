@@ -0,0 +1,83 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+)
+
+// FakeProvider answers without calling a model so the copilot can run
+// offline and in tests. Responses depend only on the request.
+type FakeProvider struct {
+	// Respond overrides the default echo behaviour when set
+	Respond func(req Request) (string, error)
+}
+
+// estimateTokens approximates a token count as one token per four bytes
+func estimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{}
+}
+
+func (f *FakeProvider) Name() string {
+	return "fake"
+}
+
+func (f *FakeProvider) Generate(ctx context.Context, req Request) (Response, error) {
+	if err := ctx.Err(); err != nil {
+		return Response{}, err
+	}
+
+	text, err := f.respond(req)
+	if err != nil {
+		return Response{}, err
+	}
+	return Response{
+		Text:             text,
+		PromptTokens:     estimateTokens(req.instructions()) + estimateTokens(req.Prompt),
+		CompletionTokens: estimateTokens(text),
+	}, nil
+}
+
+func (f *FakeProvider) respond(req Request) (string, error) {
+	if f.Respond != nil {
+		return f.Respond(req)
+	}
+
+	if req.Example != "" {
+		return req.Example, nil
+	}
+
+	// JSON requests echo the first JSON object embedded in the prompt,
+	// which leaves e.g. an enhanced task unchanged
+	if req.JSON {
+		if obj, ok := firstJSONObject(req.Prompt); ok {
+			return obj, nil
+		}
+		return "{}", nil
+	}
+
+	line, _, _ := strings.Cut(strings.TrimSpace(req.Prompt), "\n")
+	if len(line) > 200 {
+		line = line[:200]
+	}
+	return line, nil
+}
+
+func firstJSONObject(s string) (string, bool) {
+	for i := strings.IndexByte(s, '{'); i >= 0; {
+		var raw json.RawMessage
+		if err := json.NewDecoder(strings.NewReader(s[i:])).Decode(&raw); err == nil {
+			return string(raw), true
+		}
+		next := strings.IndexByte(s[i+1:], '{')
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	return "", false
+}
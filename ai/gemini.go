@@ -0,0 +1,108 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"google.golang.org/genai"
+)
+
+const (
+	defaultGeminiModel          = "gemini-3-flash-preview"
+	defaultGeminiEmbeddingModel = "gemini-embedding-001"
+)
+
+// GeminiProvider calls the Google Gemini API. The client is created on first
+// use so the server can start without an API key when the copilot is unused.
+type GeminiProvider struct {
+	model          string
+	embeddingModel string
+
+	mu     sync.Mutex
+	client *genai.Client
+}
+
+func NewGeminiProvider(model, embeddingModel string) *GeminiProvider {
+	if model == "" {
+		model = defaultGeminiModel
+	}
+	if embeddingModel == "" {
+		embeddingModel = defaultGeminiEmbeddingModel
+	}
+	return &GeminiProvider{model: model, embeddingModel: embeddingModel}
+}
+
+func (g *GeminiProvider) Name() string {
+	return "gemini:" + g.model
+}
+
+func (g *GeminiProvider) getClient(ctx context.Context) (*genai.Client, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.client != nil {
+		return g.client, nil
+	}
+
+	// Reads GEMINI_API_KEY / GOOGLE_API_KEY from the environment
+	client, err := genai.NewClient(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create genai client: %w", err)
+	}
+	g.client = client
+	return client, nil
+}
+
+func (g *GeminiProvider) Generate(ctx context.Context, req Request) (Response, error) {
+	client, err := g.getClient(ctx)
+	if err != nil {
+		return Response{}, err
+	}
+
+	config := &genai.GenerateContentConfig{}
+	if system := req.instructions(); system != "" {
+		config.SystemInstruction = genai.NewContentFromText(system, genai.RoleUser)
+	}
+	if req.JSON {
+		config.ResponseMIMEType = "application/json"
+	}
+
+	result, err := client.Models.GenerateContent(ctx, g.model, genai.Text(req.Prompt), config)
+	if err != nil {
+		return Response{}, fmt.Errorf("gemini generation failed: %w", err)
+	}
+
+	resp := Response{Text: result.Text()}
+	if result.UsageMetadata != nil {
+		resp.PromptTokens = int(result.UsageMetadata.PromptTokenCount)
+		resp.CompletionTokens = int(result.UsageMetadata.CandidatesTokenCount)
+	}
+	return resp, nil
+}
+
+func (g *GeminiProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	client, err := g.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	contents := make([]*genai.Content, len(texts))
+	for i, text := range texts {
+		contents[i] = genai.NewContentFromText(text, genai.RoleUser)
+	}
+
+	result, err := client.Models.EmbedContent(ctx, g.embeddingModel, contents, nil)
+	if err != nil {
+		return nil, fmt.Errorf("gemini embedding failed: %w", err)
+	}
+	if len(result.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("gemini returned %d embeddings for %d texts", len(result.Embeddings), len(texts))
+	}
+
+	vectors := make([][]float32, len(texts))
+	for i, e := range result.Embeddings {
+		vectors[i] = e.Values
+	}
+	return vectors, nil
+}
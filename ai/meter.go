@@ -0,0 +1,38 @@
+package ai
+
+import (
+	"context"
+	"sync"
+)
+
+// Meter wraps a provider and adds up the tokens of every call made through it,
+// so a feature that calls the model several times can be accounted as one use
+type Meter struct {
+	Provider
+
+	mu               sync.Mutex
+	promptTokens     int
+	completionTokens int
+}
+
+func NewMeter(p Provider) *Meter {
+	return &Meter{Provider: p}
+}
+
+func (m *Meter) Generate(ctx context.Context, req Request) (Response, error) {
+	resp, err := m.Provider.Generate(ctx, req)
+
+	m.mu.Lock()
+	m.promptTokens += resp.PromptTokens
+	m.completionTokens += resp.CompletionTokens
+	m.mu.Unlock()
+
+	return resp, err
+}
+
+// Tokens returns the prompt and completion tokens used so far
+func (m *Meter) Tokens() (int, int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.promptTokens, m.completionTokens
+}
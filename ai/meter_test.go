@@ -0,0 +1,94 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// scriptedProvider answers with fixed token counts and an optional error
+type scriptedProvider struct {
+	resp Response
+	err  error
+}
+
+func (s scriptedProvider) Name() string {
+	return "scripted"
+}
+
+func (s scriptedProvider) Generate(ctx context.Context, req Request) (Response, error) {
+	return s.resp, s.err
+}
+
+func TestMeterAddsUpTokens(t *testing.T) {
+	tests := []struct {
+		name           string
+		provider       Provider
+		calls          int
+		wantPrompt     int
+		wantCompletion int
+	}{
+		{"no calls", scriptedProvider{resp: Response{PromptTokens: 10, CompletionTokens: 5}}, 0, 0, 0},
+		{"one call", scriptedProvider{resp: Response{PromptTokens: 10, CompletionTokens: 5}}, 1, 10, 5},
+		{"several calls", scriptedProvider{resp: Response{PromptTokens: 10, CompletionTokens: 5}}, 3, 30, 15},
+		{
+			name:           "failed calls still count tokens reported",
+			provider:       scriptedProvider{resp: Response{PromptTokens: 7}, err: errors.New("invalid answer")},
+			calls:          2,
+			wantPrompt:     14,
+			wantCompletion: 0,
+		},
+		{"failed calls without usage", scriptedProvider{err: errors.New("unavailable")}, 2, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMeter(tt.provider)
+			for i := 0; i < tt.calls; i++ {
+				m.Generate(context.Background(), Request{Prompt: "hello"})
+			}
+
+			prompt, completion := m.Tokens()
+			if prompt != tt.wantPrompt || completion != tt.wantCompletion {
+				t.Errorf("tokens = (%d, %d), want (%d, %d)", prompt, completion, tt.wantPrompt, tt.wantCompletion)
+			}
+		})
+	}
+}
+
+func TestMeterMatchesResponses(t *testing.T) {
+	m := NewMeter(NewFakeProvider())
+
+	var wantPrompt, wantCompletion int
+	for _, prompt := range []string{"short", "a somewhat longer prompt for the model"} {
+		resp, err := m.Generate(context.Background(), Request{System: "Be brief.", Prompt: prompt})
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantPrompt += resp.PromptTokens
+		wantCompletion += resp.CompletionTokens
+	}
+
+	if prompt, completion := m.Tokens(); prompt != wantPrompt || completion != wantCompletion {
+		t.Errorf("tokens = (%d, %d), want (%d, %d)", prompt, completion, wantPrompt, wantCompletion)
+	}
+}
+
+func TestMeterConcurrentCalls(t *testing.T) {
+	m := NewMeter(scriptedProvider{resp: Response{PromptTokens: 3, CompletionTokens: 2}})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.Generate(context.Background(), Request{})
+		}()
+	}
+	wg.Wait()
+
+	if prompt, completion := m.Tokens(); prompt != 150 || completion != 100 {
+		t.Errorf("tokens = (%d, %d), want (150, 100)", prompt, completion)
+	}
+}
@@ -0,0 +1,162 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultOpenAIBaseURL        = "http://localhost:11434/v1"
+	defaultOpenAIModel          = "llama3.1"
+	defaultOpenAIEmbeddingModel = "nomic-embed-text"
+)
+
+// OpenAIProvider calls an OpenAI-compatible /chat/completions endpoint, which
+// covers OpenAI itself as well as local servers such as Ollama and llama.cpp
+type OpenAIProvider struct {
+	baseURL        string
+	apiKey         string
+	model          string
+	embeddingModel string
+	client         *http.Client
+}
+
+func NewOpenAIProvider(baseURL, apiKey, model, embeddingModel string) *OpenAIProvider {
+	if baseURL == "" {
+		baseURL = defaultOpenAIBaseURL
+	}
+	if model == "" {
+		model = defaultOpenAIModel
+	}
+	if embeddingModel == "" {
+		embeddingModel = defaultOpenAIEmbeddingModel
+	}
+	return &OpenAIProvider{
+		baseURL:        strings.TrimSuffix(baseURL, "/"),
+		apiKey:         apiKey,
+		model:          model,
+		embeddingModel: embeddingModel,
+		// Deadlines come from the request context
+		client: &http.Client{},
+	}
+}
+
+func (o *OpenAIProvider) Name() string {
+	return "openai:" + o.model
+}
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIRequest struct {
+	Model          string            `json:"model"`
+	Messages       []openAIMessage   `json:"messages"`
+	ResponseFormat map[string]string `json:"response_format,omitempty"`
+}
+
+type openAIResponse struct {
+	Choices []struct {
+		Message openAIMessage `json:"message"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+func (o *OpenAIProvider) Generate(ctx context.Context, req Request) (Response, error) {
+	body := openAIRequest{Model: o.model}
+	if system := req.instructions(); system != "" {
+		body.Messages = append(body.Messages, openAIMessage{Role: "system", Content: system})
+	}
+	body.Messages = append(body.Messages, openAIMessage{Role: "user", Content: req.Prompt})
+	if req.JSON {
+		body.ResponseFormat = map[string]string{"type": "json_object"}
+	}
+
+	var result openAIResponse
+	if err := o.post(ctx, "/chat/completions", body, &result); err != nil {
+		return Response{}, err
+	}
+	if len(result.Choices) == 0 {
+		return Response{}, fmt.Errorf("openai response contained no choices")
+	}
+	return Response{
+		Text:             result.Choices[0].Message.Content,
+		PromptTokens:     result.Usage.PromptTokens,
+		CompletionTokens: result.Usage.CompletionTokens,
+	}, nil
+}
+
+type openAIEmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type openAIEmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+func (o *OpenAIProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	var result openAIEmbeddingResponse
+	if err := o.post(ctx, "/embeddings", openAIEmbeddingRequest{Model: o.embeddingModel, Input: texts}, &result); err != nil {
+		return nil, err
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, d := range result.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("openai returned an embedding for unknown index %d", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	for i, v := range vectors {
+		if v == nil {
+			return nil, fmt.Errorf("openai returned no embedding for text %d", i)
+		}
+	}
+	return vectors, nil
+}
+
+// post sends body as JSON to the endpoint and decodes the JSON answer into out
+func (o *OpenAIProvider) post(ctx context.Context, path string, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if o.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+
+	resp, err := o.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("openai request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("openai request failed: %s: %s", resp.Status, msg)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode openai response: %w", err)
+	}
+	return nil
+}
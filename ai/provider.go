@@ -0,0 +1,120 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const defaultTimeout = 30 * time.Second
+
+// Request is a single prompt sent to a language model
+type Request struct {
+	// System carries the instructions, Prompt the user content
+	System string
+	Prompt string
+
+	// JSON asks the model to answer with a single JSON value
+	JSON bool
+
+	// Example is a valid answer shown to the model after the instructions.
+	// The fake provider returns it as-is.
+	Example string
+}
+
+// instructions joins the system prompt and the example answer
+func (r Request) instructions() string {
+	if r.Example == "" {
+		return r.System
+	}
+	return strings.TrimSpace(r.System + "\n\nExample response:\n" + r.Example)
+}
+
+// Response is a model's answer with the tokens it was billed for
+type Response struct {
+	Text             string
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// Provider generates text completions from a language model
+type Provider interface {
+	Name() string
+	Generate(ctx context.Context, req Request) (Response, error)
+}
+
+/*
+NewFromEnv builds the provider selected by AI_PROVIDER:
+
+	gemini: Google Gemini, authenticated by GEMINI_API_KEY (default)
+	openai: any OpenAI-compatible chat completions server at OPENAI_BASE_URL
+	        (default http://localhost:11434/v1, e.g. Ollama or llama.cpp),
+	        authenticated by OPENAI_API_KEY when set
+	fake:   deterministic offline responses for development and tests
+
+AI_MODEL and AI_EMBEDDING_MODEL override the provider's default models and AI_TIMEOUT
+(a Go duration, default 30s) bounds every generation call.
+*/
+func NewFromEnv() (Provider, error) {
+	timeout := defaultTimeout
+	if v := os.Getenv("AI_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid AI_TIMEOUT: %s", v)
+		}
+		timeout = d
+	}
+
+	model := os.Getenv("AI_MODEL")
+	embeddingModel := os.Getenv("AI_EMBEDDING_MODEL")
+
+	var p Provider
+	switch name := strings.ToLower(os.Getenv("AI_PROVIDER")); name {
+	case "", "gemini":
+		p = NewGeminiProvider(model, embeddingModel)
+	case "openai":
+		p = NewOpenAIProvider(os.Getenv("OPENAI_BASE_URL"), os.Getenv("OPENAI_API_KEY"), model, embeddingModel)
+	case "fake":
+		p = NewFakeProvider()
+	default:
+		return nil, fmt.Errorf("unknown AI_PROVIDER: %s", name)
+	}
+
+	return WithTimeout(p, timeout), nil
+}
+
+type timeoutProvider struct {
+	Provider
+	timeout time.Duration
+}
+
+// WithTimeout bounds every Generate call on p, on top of any deadline
+// already carried by the caller's context
+func WithTimeout(p Provider, timeout time.Duration) Provider {
+	return &timeoutProvider{Provider: p, timeout: timeout}
+}
+
+func (t *timeoutProvider) Generate(ctx context.Context, req Request) (Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.Provider.Generate(ctx, req)
+}
+
+// StripCodeFence removes the ```json ... ``` wrapper models sometimes add
+// around JSON answers even when told not to
+func StripCodeFence(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "```") {
+		return s
+	}
+	s = strings.TrimPrefix(s, "```")
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[i+1:]
+	} else {
+		s = strings.TrimPrefix(s, "json")
+	}
+	s = strings.TrimSuffix(strings.TrimSpace(s), "```")
+	return strings.TrimSpace(s)
+}
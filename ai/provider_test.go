@@ -0,0 +1,163 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNewFromEnv(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		wantName    string
+		wantTimeout time.Duration
+		wantErr     bool
+	}{
+		{"default is gemini", nil, "gemini:" + defaultGeminiModel, defaultTimeout, false},
+		{"gemini with model", map[string]string{"AI_PROVIDER": "gemini", "AI_MODEL": "gemini-pro"}, "gemini:gemini-pro", defaultTimeout, false},
+		{"openai", map[string]string{"AI_PROVIDER": "openai"}, "openai:" + defaultOpenAIModel, defaultTimeout, false},
+		{"provider name is case insensitive", map[string]string{"AI_PROVIDER": "OpenAI", "AI_MODEL": "llama3"}, "openai:llama3", defaultTimeout, false},
+		{"fake", map[string]string{"AI_PROVIDER": "fake"}, "fake", defaultTimeout, false},
+		{"custom timeout", map[string]string{"AI_PROVIDER": "fake", "AI_TIMEOUT": "5s"}, "fake", 5 * time.Second, false},
+		{"unknown provider", map[string]string{"AI_PROVIDER": "claude"}, "", 0, true},
+		{"invalid timeout", map[string]string{"AI_PROVIDER": "fake", "AI_TIMEOUT": "soon"}, "", 0, true},
+		{"zero timeout", map[string]string{"AI_PROVIDER": "fake", "AI_TIMEOUT": "0s"}, "", 0, true},
+		{"negative timeout", map[string]string{"AI_PROVIDER": "fake", "AI_TIMEOUT": "-1s"}, "", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"AI_PROVIDER", "AI_MODEL", "AI_EMBEDDING_MODEL", "AI_TIMEOUT"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			p, err := NewFromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if got := p.Name(); got != tt.wantName {
+				t.Errorf("name %q, want %q", got, tt.wantName)
+			}
+			tp, ok := p.(*timeoutProvider)
+			if !ok {
+				t.Fatalf("provider %T is not bounded by a timeout", p)
+			}
+			if tp.timeout != tt.wantTimeout {
+				t.Errorf("timeout %v, want %v", tp.timeout, tt.wantTimeout)
+			}
+		})
+	}
+}
+
+// blockingProvider waits for its context to end before answering
+type blockingProvider struct{}
+
+func (blockingProvider) Name() string {
+	return "blocking"
+}
+
+func (blockingProvider) Generate(ctx context.Context, req Request) (Response, error) {
+	<-ctx.Done()
+	return Response{}, ctx.Err()
+}
+
+func TestWithTimeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider Provider
+		timeout  time.Duration
+		ctx      func() (context.Context, context.CancelFunc)
+		wantErr  error
+	}{
+		{
+			name:     "fast provider answers",
+			provider: NewFakeProvider(),
+			timeout:  time.Second,
+			ctx:      func() (context.Context, context.CancelFunc) { return context.WithCancel(context.Background()) },
+		},
+		{
+			name:     "slow provider times out",
+			provider: blockingProvider{},
+			timeout:  20 * time.Millisecond,
+			ctx:      func() (context.Context, context.CancelFunc) { return context.WithCancel(context.Background()) },
+			wantErr:  context.DeadlineExceeded,
+		},
+		{
+			name:     "caller deadline still applies",
+			provider: blockingProvider{},
+			timeout:  time.Minute,
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 20*time.Millisecond)
+			},
+			wantErr: context.DeadlineExceeded,
+		},
+		{
+			name:     "caller cancellation still applies",
+			provider: blockingProvider{},
+			timeout:  time.Minute,
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx, cancel
+			},
+			wantErr: context.Canceled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := tt.ctx()
+			defer cancel()
+
+			start := time.Now()
+			_, err := WithTimeout(tt.provider, tt.timeout).Generate(ctx, Request{Prompt: "hello"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("generate took %v", elapsed)
+			}
+		})
+	}
+}
+
+func TestWithTimeoutBoundsEmbed(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// The fake embedder honours the bounded context
+	if _, err := Embed(ctx, WithTimeout(NewFakeProvider(), time.Minute), []string{"a"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want %v", err, context.Canceled)
+	}
+	if _, err := Embed(context.Background(), WithTimeout(NewFakeProvider(), time.Second), []string{"a"}); err != nil {
+		t.Errorf("embedding through the wrapper failed: %v", err)
+	}
+	if _, err := Embed(context.Background(), WithTimeout(blockingProvider{}, time.Second), []string{"a"}); !errors.Is(err, ErrEmbeddingsUnsupported) {
+		t.Errorf("err = %v, want %v", err, ErrEmbeddingsUnsupported)
+	}
+}
+
+func TestStripCodeFence(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`{"a": 1}`, `{"a": 1}`},
+		{"  {\"a\": 1}\n", `{"a": 1}`},
+		{"```json\n{\"a\": 1}\n```", `{"a": 1}`},
+		{"```\n{\"a\": 1}\n```", `{"a": 1}`},
+		{"```json{\"a\": 1}```", `{"a": 1}`},
+	}
+
+	for _, tt := range tests {
+		if got := StripCodeFence(tt.in); got != tt.want {
+			t.Errorf("StripCodeFence(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
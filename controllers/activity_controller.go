@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/drumilbhati/teamsync/logs"
+	"github.com/drumilbhati/teamsync/middleware"
+	"github.com/drumilbhati/teamsync/models"
+	"github.com/drumilbhati/teamsync/store"
+	"github.com/drumilbhati/teamsync/ws"
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultActivityLimit = 50
+	maxActivityLimit     = 200
+)
+
+type ActivityHandler struct {
+	store *store.Store
+}
+
+func NewActivityHandler(s *store.Store) *ActivityHandler {
+	return &ActivityHandler{store: s}
+}
+
+/*
+recordActivity saves an event to the team's activity feed and pushes it to
+the team as an ACTIVITY frame. The action it describes has already happened,
+so failing to record it is only logged.
+*/
+func recordActivity(s *store.Store, hub *ws.Hub, a models.Activity) {
+	if err := s.CreateActivity(&a); err != nil {
+		logs.Log.Errorf("Failed to record %s activity for team %d: %v", a.Type, a.TeamID, err)
+		return
+	}
+
+	msg_bytes, _ := json.Marshal(Message{
+		Type: "ACTIVITY",
+		Data: a,
+	})
+	hub.BroadcastToTeam(a.TeamID, msg_bytes)
+}
+
+// recordTaskChanges records the moves and reassignments between two versions of a task
+func recordTaskChanges(s *store.Store, hub *ws.Hub, actorID int, actorName string, before, after *models.Task) {
+	if before.Status != after.Status {
+		recordActivity(s, hub, models.Activity{
+			TeamID:    before.TeamID,
+			ActorID:   actorID,
+			ActorName: actorName,
+			Type:      models.ActivityTaskMoved,
+			TaskID:    before.TaskID,
+			Data: map[string]interface{}{
+				"title": after.Title,
+				"from":  before.Status,
+				"to":    after.Status,
+			},
+		})
+	}
+
+	if before.AssigneeID != after.AssigneeID {
+		data := map[string]interface{}{"title": after.Title, "from": nil, "to": nil}
+		if before.AssigneeID.Valid {
+			data["from"] = before.AssigneeID.Int64
+		}
+		if after.AssigneeID.Valid {
+			data["to"] = after.AssigneeID.Int64
+		}
+		recordActivity(s, hub, models.Activity{
+			TeamID:    before.TeamID,
+			ActorID:   actorID,
+			ActorName: actorName,
+			Type:      models.ActivityTaskAssigned,
+			TaskID:    before.TaskID,
+			Data:      data,
+		})
+	}
+}
+
+/*
+GetTeamActivity returns the team's activity feed, newest first. Optional
+filters: types (comma-separated), actor_id and task_id. Pages hold limit
+events; pass next_before as before to fetch the following page.
+*/
+func (h *ActivityHandler) GetTeamActivity(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	team_id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid team_id", http.StatusBadRequest)
+		return
+	}
+
+	isMember, err := h.store.IsTeamMember(requester_id, team_id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !isMember {
+		http.Error(w, "Forbidden: you are not a member of this team", http.StatusForbidden)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := models.ActivityFilter{TeamID: team_id, Limit: defaultActivityLimit}
+
+	if v := query.Get("types"); v != "" {
+		for _, t := range strings.Split(v, ",") {
+			t = strings.TrimSpace(t)
+			if !slices.Contains(models.ActivityTypes, t) {
+				http.Error(w, "Invalid activity type: "+t, http.StatusBadRequest)
+				return
+			}
+			filter.Types = append(filter.Types, t)
+		}
+	}
+	if v := query.Get("actor_id"); v != "" {
+		if filter.ActorID, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "Invalid actor_id", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := query.Get("task_id"); v != "" {
+		if filter.TaskID, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "Invalid task_id", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := query.Get("before"); v != "" {
+		if filter.Before, err = strconv.ParseInt(v, 10, 64); err != nil || filter.Before < 1 {
+			http.Error(w, "Invalid before", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit < 1 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		if filter.Limit > maxActivityLimit {
+			filter.Limit = maxActivityLimit
+		}
+	}
+
+	page, err := h.store.GetTeamActivity(filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
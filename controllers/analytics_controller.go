@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/drumilbhati/teamsync/middleware"
+	"github.com/drumilbhati/teamsync/store"
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultAnalyticsDays = 28
+	maxAnalyticsDays     = 366
+)
+
+type AnalyticsHandler struct {
+	store *store.Store
+}
+
+func NewAnalyticsHandler(s *store.Store) *AnalyticsHandler {
+	return &AnalyticsHandler{store: s}
+}
+
+/*
+GetTeamAnalytics returns the team's throughput, cycle time, time in status,
+burndown and workload for the days from from to to (YYYY-MM-DD, both
+included, in UTC). The range defaults to the last four weeks.
+*/
+func (h *AnalyticsHandler) GetTeamAnalytics(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	team_id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid team_id", http.StatusBadRequest)
+		return
+	}
+
+	team, err := h.store.GetTeamByID(team_id)
+	if err != nil {
+		http.Error(w, "Team not found", http.StatusNotFound)
+		return
+	}
+	if team.TeamLeaderID != requester_id {
+		http.Error(w, "Forbidden: only the team leader can view analytics", http.StatusForbidden)
+		return
+	}
+
+	query := r.URL.Query()
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	if v := query.Get("to"); v != "" {
+		if to, err = time.Parse("2006-01-02", v); err != nil {
+			http.Error(w, "Invalid to date", http.StatusBadRequest)
+			return
+		}
+	}
+	from := to.AddDate(0, 0, 1-defaultAnalyticsDays)
+	if v := query.Get("from"); v != "" {
+		if from, err = time.Parse("2006-01-02", v); err != nil {
+			http.Error(w, "Invalid from date", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// The range ends after the whole of the to day
+	to = to.AddDate(0, 0, 1)
+	if !from.Before(to) {
+		http.Error(w, "from must not be after to", http.StatusBadRequest)
+		return
+	}
+	if to.Sub(from) > maxAnalyticsDays*24*time.Hour {
+		http.Error(w, "The range can span at most 366 days", http.StatusBadRequest)
+		return
+	}
+
+	analytics, err := h.store.GetTeamAnalytics(r.Context(), team_id, from, to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(analytics)
+}
@@ -0,0 +1,473 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/drumilbhati/teamsync/logs"
+	"github.com/drumilbhati/teamsync/middleware"
+	"github.com/drumilbhati/teamsync/models"
+	"github.com/drumilbhati/teamsync/storage"
+	"github.com/drumilbhati/teamsync/store"
+	"github.com/drumilbhati/teamsync/worker"
+	"github.com/gorilla/mux"
+	"github.com/hibiken/asynq"
+)
+
+const (
+	defaultAttachmentMaxBytes   = 10 << 20 // 10 MB
+	defaultAttachmentTeamQuota  = 1 << 30  // 1 GB
+	defaultAttachmentAllowTypes = "image/png,image/jpeg,image/gif,image/webp,application/pdf,text/plain,text/csv,application/zip"
+)
+
+// Image types the thumbnail worker can decode
+var thumbnailTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+}
+
+type AttachmentHandler struct {
+	store        *store.Store
+	storage      storage.Storage
+	client       *asynq.Client
+	maxBytes     int64
+	quotaBytes   int64
+	allowedTypes map[string]bool
+}
+
+/*
+NewAttachmentHandler reads its limits from ATTACHMENT_MAX_BYTES,
+ATTACHMENT_TEAM_QUOTA_BYTES and ATTACHMENT_ALLOWED_TYPES (comma separated MIME types)
+*/
+func NewAttachmentHandler(s *store.Store, st storage.Storage, c *asynq.Client) *AttachmentHandler {
+	allowed := os.Getenv("ATTACHMENT_ALLOWED_TYPES")
+	if allowed == "" {
+		allowed = defaultAttachmentAllowTypes
+	}
+
+	allowedTypes := make(map[string]bool)
+	for _, t := range strings.Split(allowed, ",") {
+		allowedTypes[strings.TrimSpace(t)] = true
+	}
+
+	return &AttachmentHandler{
+		store:        s,
+		storage:      st,
+		client:       c,
+		maxBytes:     envInt64("ATTACHMENT_MAX_BYTES", defaultAttachmentMaxBytes),
+		quotaBytes:   envInt64("ATTACHMENT_TEAM_QUOTA_BYTES", defaultAttachmentTeamQuota),
+		allowedTypes: allowedTypes,
+	}
+}
+
+/*
+UploadAttachment accepts a multipart form with a "file" field and exactly one of
+task_id, comment_id or message_id
+*/
+func (a *AttachmentHandler) UploadAttachment(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Leave room for the multipart envelope around the file itself
+	r.Body = http.MaxBytesReader(w, r.Body, a.maxBytes+1<<20)
+	if err := r.ParseMultipartForm(8 << 20); err != nil {
+		http.Error(w, "Invalid upload or file too large", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	attachment := models.Attachment{UploaderID: requester_id}
+
+	var err error
+	for field, target := range map[string]*int{
+		"task_id":    &attachment.TaskID,
+		"comment_id": &attachment.CommentID,
+		"message_id": &attachment.MessageID,
+	} {
+		if v := r.FormValue(field); v != "" {
+			if *target, err = strconv.Atoi(v); err != nil {
+				http.Error(w, "Invalid "+field, http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	if !a.resolveTeam(w, &attachment, requester_id) {
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	if header.Size > a.maxBytes {
+		http.Error(w, fmt.Sprintf("File exceeds the %d byte limit", a.maxBytes), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// Trust the file's content rather than the client supplied Content-Type
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(file, sniff)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(sniff[:n]))
+	if ext := strings.ToLower(filepath.Ext(header.Filename)); contentType == "text/plain" && ext == ".csv" {
+		contentType = "text/csv"
+	}
+	if !a.allowedTypes[contentType] {
+		http.Error(w, "File type not allowed: "+contentType, http.StatusUnsupportedMediaType)
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	used, err := a.store.GetTeamAttachmentUsage(attachment.TeamID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if used+header.Size > a.quotaBytes {
+		http.Error(w, "Team attachment quota exceeded", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	attachment.FileName = filepath.Base(header.Filename)
+	attachment.ContentType = contentType
+	attachment.SizeBytes = header.Size
+	attachment.StorageKey, err = newStorageKey(attachment.TeamID, attachment.FileName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := a.storage.Put(r.Context(), attachment.StorageKey, file, header.Size, contentType); err != nil {
+		logs.Log.Errorf("Error storing attachment: %v", err)
+		http.Error(w, "Error storing attachment", http.StatusInternalServerError)
+		return
+	}
+
+	if err := a.store.CreateAttachment(&attachment, a.quotaBytes); err != nil {
+		a.storage.Delete(r.Context(), attachment.StorageKey)
+		if err == store.ErrQuotaExceeded {
+			http.Error(w, "Team attachment quota exceeded", http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if thumbnailTypes[contentType] {
+		task, err := worker.NewAttachmentThumbnailTask(attachment.AttachmentID)
+		if err == nil {
+			_, err = a.client.Enqueue(task)
+		}
+		if err != nil {
+			logs.Log.Errorf("Failed to enqueue thumbnail for attachment %d: %v", attachment.AttachmentID, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(attachment)
+}
+
+// GetAttachments lists the attachments of a task, comment or message given as a query param
+func (a *AttachmentHandler) GetAttachments(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var target models.Attachment
+	var err error
+	query := r.URL.Query()
+	for field, id := range map[string]*int{
+		"task_id":    &target.TaskID,
+		"comment_id": &target.CommentID,
+		"message_id": &target.MessageID,
+	} {
+		if v := query.Get(field); v != "" {
+			if *id, err = strconv.Atoi(v); err != nil {
+				http.Error(w, "Invalid "+field, http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	if !a.resolveTeam(w, &target, requester_id) {
+		return
+	}
+
+	var attachments []models.Attachment
+	switch {
+	case target.TaskID != 0:
+		attachments, err = a.store.GetAttachmentsByTaskID(target.TaskID)
+	case target.CommentID != 0:
+		attachments, err = a.store.GetAttachmentsByCommentID(target.CommentID)
+	default:
+		attachments, err = a.store.GetAttachmentsByMessageID(target.MessageID)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(attachments)
+}
+
+func (a *AttachmentHandler) GetAttachmentUsage(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	team_id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid team_id", http.StatusBadRequest)
+		return
+	}
+
+	isMember, err := a.store.IsTeamMember(requester_id, team_id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !isMember {
+		http.Error(w, "Forbidden: you are not a member of this team", http.StatusForbidden)
+		return
+	}
+
+	used, err := a.store.GetTeamAttachmentUsage(team_id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{
+		"team_id":     int64(team_id),
+		"used_bytes":  used,
+		"quota_bytes": a.quotaBytes,
+		"max_bytes":   a.maxBytes,
+	})
+}
+
+func (a *AttachmentHandler) DownloadAttachment(w http.ResponseWriter, r *http.Request) {
+	a.serve(w, r, false)
+}
+
+func (a *AttachmentHandler) DownloadThumbnail(w http.ResponseWriter, r *http.Request) {
+	a.serve(w, r, true)
+}
+
+func (a *AttachmentHandler) DeleteAttachmentByID(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	attachment, ok := a.authorizeAttachment(w, r, requester_id)
+	if !ok {
+		return
+	}
+
+	if attachment.UploaderID != requester_id {
+		team, err := a.store.GetTeamByID(attachment.TeamID)
+		if err != nil {
+			http.Error(w, "Error getting team details", http.StatusInternalServerError)
+			return
+		}
+		if team.TeamLeaderID != requester_id {
+			http.Error(w, "Unauthorized: only the uploader or team leader can delete this attachment", http.StatusForbidden)
+			return
+		}
+	}
+
+	if err := a.store.DeleteAttachmentByID(attachment.AttachmentID); err != nil {
+		http.Error(w, "Error deleting attachment", http.StatusInternalServerError)
+		return
+	}
+
+	for _, key := range []string{attachment.StorageKey, attachment.ThumbnailKey} {
+		if key == "" {
+			continue
+		}
+		if err := a.storage.Delete(r.Context(), key); err != nil {
+			logs.Log.Warnf("Failed to delete stored object %s: %v", key, err)
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *AttachmentHandler) serve(w http.ResponseWriter, r *http.Request, thumbnail bool) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	attachment, ok := a.authorizeAttachment(w, r, requester_id)
+	if !ok {
+		return
+	}
+
+	key, contentType, disposition := attachment.StorageKey, attachment.ContentType, "attachment"
+	if thumbnail {
+		if !attachment.HasThumbnail {
+			http.Error(w, "No thumbnail available for this attachment", http.StatusNotFound)
+			return
+		}
+		key, contentType, disposition = attachment.ThumbnailKey, "image/jpeg", "inline"
+	}
+
+	body, err := a.storage.Get(r.Context(), key)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			http.Error(w, "Attachment content not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Error reading attachment", http.StatusInternalServerError)
+		}
+		return
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.FileName}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if !thumbnail {
+		w.Header().Set("Content-Length", strconv.FormatInt(attachment.SizeBytes, 10))
+	}
+	io.Copy(w, body)
+}
+
+// authorizeAttachment loads the attachment from the route and checks the
+// requester can see its parent, writing the error response otherwise
+func (a *AttachmentHandler) authorizeAttachment(w http.ResponseWriter, r *http.Request, requester_id int) (*models.Attachment, bool) {
+	attachment_id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid attachment id", http.StatusBadRequest)
+		return nil, false
+	}
+
+	attachment, err := a.store.GetAttachmentByID(attachment_id)
+	if err != nil {
+		http.Error(w, "No attachment found with given id", http.StatusNotFound)
+		return nil, false
+	}
+
+	if !a.resolveTeam(w, attachment, requester_id) {
+		return nil, false
+	}
+	return attachment, true
+}
+
+// resolveTeam sets the attachment's team from its task, comment or message
+// and checks the requester's access to it, writing the error response otherwise
+func (a *AttachmentHandler) resolveTeam(w http.ResponseWriter, attachment *models.Attachment, requester_id int) bool {
+	targets := 0
+	for _, id := range []int{attachment.TaskID, attachment.CommentID, attachment.MessageID} {
+		if id != 0 {
+			targets++
+		}
+	}
+	if targets != 1 {
+		http.Error(w, "Exactly one of task_id, comment_id or message_id is required", http.StatusBadRequest)
+		return false
+	}
+
+	var hasAccess bool
+	var err error
+	switch {
+	case attachment.TaskID != 0:
+		var task *models.Task
+		if task, err = a.store.GetTaskByTaskID(attachment.TaskID); err != nil {
+			http.Error(w, "No task found with given id", http.StatusNotFound)
+			return false
+		}
+		attachment.TeamID = task.TeamID
+		hasAccess, err = a.store.IsTeamMember(requester_id, task.TeamID)
+	case attachment.CommentID != 0:
+		var comment models.Comment
+		if comment, err = a.store.GetCommentbyID(attachment.CommentID); err != nil {
+			http.Error(w, "No comment found with given id", http.StatusNotFound)
+			return false
+		}
+		var task *models.Task
+		if task, err = a.store.GetTaskByTaskID(comment.TaskID); err != nil {
+			http.Error(w, "No task found for given comment", http.StatusNotFound)
+			return false
+		}
+		attachment.TeamID = task.TeamID
+		hasAccess, err = a.store.IsTeamMember(requester_id, task.TeamID)
+	default:
+		var msg *models.Message
+		if msg, err = a.store.GetMessageByID(attachment.MessageID); err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "No message found with given id", http.StatusNotFound)
+			} else {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return false
+		}
+		attachment.TeamID = msg.TeamID
+		hasAccess, err = a.store.CanAccessChannel(requester_id, msg.ChannelID)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+
+	if !hasAccess {
+		http.Error(w, "Forbidden: you are not a member of the team this attachment belongs to", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// newStorageKey returns a unique key for a team's upload, keeping the file extension
+func newStorageKey(teamID int, fileName string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	ext := strings.ToLower(filepath.Ext(fileName))
+	if len(ext) > 10 || strings.ContainsAny(ext, `/\`) {
+		ext = ""
+	}
+	return fmt.Sprintf("teams/%d/%s%s", teamID, hex.EncodeToString(b), ext), nil
+}
+
+func envInt64(name string, fallback int64) int64 {
+	v, err := strconv.ParseInt(os.Getenv(name), 10, 64)
+	if err != nil || v <= 0 {
+		return fallback
+	}
+	return v
+}
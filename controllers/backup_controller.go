@@ -0,0 +1,244 @@
+package controllers
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/drumilbhati/teamsync/logs"
+	"github.com/drumilbhati/teamsync/middleware"
+	"github.com/drumilbhati/teamsync/models"
+	"github.com/drumilbhati/teamsync/storage"
+	"github.com/drumilbhati/teamsync/store"
+	"github.com/drumilbhati/teamsync/worker"
+	"github.com/drumilbhati/teamsync/ws"
+	"github.com/gorilla/mux"
+	"github.com/hibiken/asynq"
+)
+
+type BackupHandler struct {
+	store   *store.Store
+	storage storage.Storage
+	client  *asynq.Client
+	wsHub   *ws.Hub
+}
+
+func NewBackupHandler(s *store.Store, st storage.Storage, c *asynq.Client, wsHub *ws.Hub) *BackupHandler {
+	return &BackupHandler{store: s, storage: st, client: c, wsHub: wsHub}
+}
+
+// CreateBackup queues a backup of the team; poll GetBackupByID until it completes
+func (h *BackupHandler) CreateBackup(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	team_id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid team_id", http.StatusBadRequest)
+		return
+	}
+
+	team, err := h.store.GetTeamByID(team_id)
+	if err != nil {
+		http.Error(w, "Team not found", http.StatusNotFound)
+		return
+	}
+	if team.TeamLeaderID != requester_id {
+		http.Error(w, "Forbidden: only the team leader can back up this team", http.StatusForbidden)
+		return
+	}
+
+	backup := models.Backup{
+		TeamID:      team.TeamID,
+		TeamName:    team.TeamName,
+		LeaderID:    team.TeamLeaderID,
+		RequestedBy: requester_id,
+	}
+	if err := h.store.CreateBackup(&backup); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	task, err := worker.NewTeamBackupTask(backup.BackupID)
+	if err == nil {
+		_, err = h.client.Enqueue(task)
+	}
+	if err != nil {
+		logs.Log.Errorf("Failed to enqueue backup %d: %v", backup.BackupID, err)
+		h.store.FinishBackup(backup.BackupID, models.BackupFailed, "", 0, "failed to queue the backup")
+		http.Error(w, "Failed to queue backup", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(backup)
+}
+
+// GetBackups lists the backups the requester may download, including those of deleted teams
+func (h *BackupHandler) GetBackups(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	backups, err := h.store.GetBackupsForUser(requester_id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(backups)
+}
+
+func (h *BackupHandler) GetBackupByID(w http.ResponseWriter, r *http.Request) {
+	backup, ok := h.authorizeBackup(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(backup)
+}
+
+// DownloadBackup streams the gzipped JSON archive of a completed backup
+func (h *BackupHandler) DownloadBackup(w http.ResponseWriter, r *http.Request) {
+	backup, ok := h.authorizeBackup(w, r)
+	if !ok {
+		return
+	}
+
+	body, ok := h.openArchive(w, r, backup)
+	if !ok {
+		return
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="teamsync-backup-%d.json.gz"`, backup.BackupID))
+	w.Header().Set("Content-Length", strconv.FormatInt(backup.SizeBytes, 10))
+	io.Copy(w, body)
+}
+
+/*
+RestoreBackup recreates the backed up team as a new team led by the
+requester. The original team, if it still exists, is left untouched.
+*/
+func (h *BackupHandler) RestoreBackup(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	backup, ok := h.authorizeBackup(w, r)
+	if !ok {
+		return
+	}
+
+	body, ok := h.openArchive(w, r, backup)
+	if !ok {
+		return
+	}
+	defer body.Close()
+
+	zr, err := gzip.NewReader(body)
+	if err != nil {
+		http.Error(w, "Backup archive is corrupt", http.StatusInternalServerError)
+		return
+	}
+	var archive models.TeamBackup
+	if err := json.NewDecoder(zr).Decode(&archive); err != nil {
+		http.Error(w, "Backup archive is corrupt", http.StatusInternalServerError)
+		return
+	}
+	if archive.Version != models.TeamBackupVersion {
+		http.Error(w, fmt.Sprintf("Unsupported backup version %d", archive.Version), http.StatusUnprocessableEntity)
+		return
+	}
+
+	result, err := h.store.RestoreTeamBackup(&archive, requester_id)
+	if err != nil {
+		logs.Log.Errorf("Error restoring backup %d: %v", backup.BackupID, err)
+		http.Error(w, "Error restoring backup", http.StatusInternalServerError)
+		return
+	}
+
+	// Connected members see the restored team without reconnecting
+	members, err := h.store.GetMembersByTeamID(result.Team.TeamID)
+	if err != nil {
+		logs.Log.Errorf("Error fetching members of restored team: %v", err)
+	}
+	for _, m := range members {
+		channelIDs, err := h.store.GetTeamChannelIDsForUser(m.TeamID, m.UserID)
+		if err != nil {
+			logs.Log.Errorf("Error fetching channels for restored member: %v", err)
+			continue
+		}
+		h.wsHub.JoinTeam(m.TeamID, m.UserID, channelIDs)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(result)
+}
+
+// authorizeBackup loads the backup from the route and checks the requester
+// led the team when it was taken or leads it now
+func (h *BackupHandler) authorizeBackup(w http.ResponseWriter, r *http.Request) (*models.Backup, bool) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	backup_id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid backup_id", http.StatusBadRequest)
+		return nil, false
+	}
+
+	backup, err := h.store.GetBackupByID(backup_id)
+	if err != nil {
+		http.Error(w, "Backup not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	if backup.LeaderID == requester_id {
+		return backup, true
+	}
+	if backup.TeamID != 0 {
+		if team, err := h.store.GetTeamByID(backup.TeamID); err == nil && team.TeamLeaderID == requester_id {
+			return backup, true
+		}
+	}
+
+	http.Error(w, "Forbidden: only the team leader can access this backup", http.StatusForbidden)
+	return nil, false
+}
+
+func (h *BackupHandler) openArchive(w http.ResponseWriter, r *http.Request, backup *models.Backup) (io.ReadCloser, bool) {
+	if backup.Status != models.BackupCompleted {
+		http.Error(w, "Backup is not completed", http.StatusConflict)
+		return nil, false
+	}
+
+	body, err := h.storage.Get(r.Context(), backup.StorageKey)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			http.Error(w, "Backup archive not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Error reading backup archive", http.StatusInternalServerError)
+		}
+		return nil, false
+	}
+	return body, true
+}
@@ -0,0 +1,259 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	// Feeds may name any IANA timezone, even where the host has no zoneinfo
+	_ "time/tzdata"
+
+	"github.com/drumilbhati/teamsync/logs"
+	"github.com/drumilbhati/teamsync/middleware"
+	"github.com/drumilbhati/teamsync/models"
+	"github.com/drumilbhati/teamsync/store"
+	"github.com/drumilbhati/teamsync/utils"
+	"github.com/gorilla/mux"
+)
+
+// Timed due dates are shown as short events ending half an hour after the due time
+const calendarEventDuration = 30 * time.Minute
+
+type CalendarHandler struct {
+	store *store.Store
+}
+
+func NewCalendarHandler(s *store.Store) *CalendarHandler {
+	return &CalendarHandler{store: s}
+}
+
+func hashFeedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// feedURL builds the public URL of a feed from PUBLIC_URL, or from the request when unset
+func feedURL(r *http.Request, token string) string {
+	base := strings.TrimSuffix(os.Getenv("PUBLIC_URL"), "/")
+	if base == "" {
+		scheme := "http"
+		if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		base = scheme + "://" + r.Host
+	}
+	return fmt.Sprintf("%s/calendar/%s.ics", base, token)
+}
+
+/*
+CreateCalendarFeed creates a feed of the requester's assigned tasks, or of
+all tasks of team_id when given. timezone (an IANA name, default UTC)
+decides which due dates are all-day. The URL embeds the token and is only
+returned here; a lost URL is replaced by deleting the feed and creating a new one.
+*/
+func (c *CalendarHandler) CreateCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var feed models.CalendarFeed
+	if err := json.NewDecoder(r.Body).Decode(&feed); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if feed.Timezone == "" {
+		feed.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(feed.Timezone); err != nil {
+		http.Error(w, "Invalid timezone", http.StatusBadRequest)
+		return
+	}
+
+	if feed.TeamID != 0 {
+		isMember, err := c.store.IsTeamMember(requester_id, feed.TeamID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if !isMember {
+			http.Error(w, "Forbidden: you are not a member of this team", http.StatusForbidden)
+			return
+		}
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	feed.UserID = requester_id
+	if err := c.store.CreateCalendarFeed(&feed, hashFeedToken(token)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	feed.URL = feedURL(r, token)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(feed)
+}
+
+func (c *CalendarHandler) GetCalendarFeeds(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	feeds, err := c.store.GetCalendarFeedsByUserID(requester_id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(feeds)
+}
+
+// DeleteCalendarFeed revokes a feed; its URL stops working immediately
+func (c *CalendarHandler) DeleteCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	feed_id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid feed_id", http.StatusBadRequest)
+		return
+	}
+
+	feed, err := c.store.GetCalendarFeedByID(feed_id)
+	if err != nil || feed.UserID != requester_id {
+		http.Error(w, "Feed not found", http.StatusNotFound)
+		return
+	}
+
+	if err := c.store.DeleteCalendarFeedByID(feed_id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/*
+ServeCalendarFeed is the public .ics endpoint calendar clients subscribe to.
+It is generated on every request so changes show up on the client's next
+refresh. A team feed stops working once its owner leaves the team.
+*/
+func (c *CalendarHandler) ServeCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	feed, err := c.store.GetCalendarFeedByTokenHash(hashFeedToken(mux.Vars(r)["token"]))
+	if err != nil {
+		http.Error(w, "Feed not found", http.StatusNotFound)
+		return
+	}
+
+	name := "TeamSync: My tasks"
+	if feed.TeamID != 0 {
+		isMember, err := c.store.IsTeamMember(feed.UserID, feed.TeamID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !isMember {
+			http.Error(w, "Feed not found", http.StatusNotFound)
+			return
+		}
+
+		team, err := c.store.GetTeamByID(feed.TeamID)
+		if err != nil {
+			http.Error(w, "Feed not found", http.StatusNotFound)
+			return
+		}
+		name = "TeamSync: " + team.TeamName
+	}
+
+	loc, err := time.LoadLocation(feed.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	tasks, err := c.store.GetCalendarTasks(feed.UserID, feed.TeamID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	events := make([]utils.ICalEvent, 0, len(tasks))
+	for _, task := range tasks {
+		events = append(events, taskCalendarEvent(task, loc))
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="teamsync.ics"`)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if err := utils.WriteICalendar(w, name, loc.String(), events); err != nil {
+		logs.Log.Errorf("Failed to write calendar feed %d: %v", feed.FeedID, err)
+	}
+}
+
+/*
+taskCalendarEvent turns a task's due date into an event. A due date at
+midnight, in the feed's timezone or in UTC where date-only due dates are
+stored, is an all-day event on that date; any other due date is a timed
+event at that instant.
+*/
+func taskCalendarEvent(task models.Task, loc *time.Location) utils.ICalEvent {
+	event := utils.ICalEvent{
+		UID:          fmt.Sprintf("task-%d@teamsync", task.TaskID),
+		Summary:      task.Title,
+		Categories:   []string{string(task.Status), string(task.Priority)},
+		Duration:     calendarEventDuration,
+		LastModified: task.CreatedAt,
+	}
+	if task.UpdatedAt.Valid {
+		event.LastModified = task.UpdatedAt.Time
+	}
+	if task.Status == models.TaskStatusDone {
+		event.Summary = "✓ " + task.Title
+	}
+
+	details := []string{fmt.Sprintf("Status: %s", task.Status), fmt.Sprintf("Priority: %s", task.Priority)}
+	if task.AssigneeName != "" {
+		details = append(details, "Assignee: "+task.AssigneeName)
+	}
+	if task.Description.String != "" {
+		details = append(details, "", task.Description.String)
+	}
+	event.Description = strings.Join(details, "\n")
+
+	due := task.DueDate.Time
+	isMidnight := func(t time.Time) bool {
+		return t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0
+	}
+	switch {
+	case isMidnight(due.In(loc)):
+		event.Start, event.AllDay = due.In(loc), true
+	case isMidnight(due.UTC()):
+		event.Start, event.AllDay = due.UTC(), true
+	default:
+		event.Start = due
+	}
+	return event
+}
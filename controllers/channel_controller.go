@@ -0,0 +1,367 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/drumilbhati/teamsync/middleware"
+	"github.com/drumilbhati/teamsync/models"
+	"github.com/drumilbhati/teamsync/store"
+	"github.com/drumilbhati/teamsync/ws"
+	"github.com/gorilla/mux"
+)
+
+type ChannelHandler struct {
+	store *store.Store
+	wsHub *ws.Hub
+}
+
+func NewChannelHandler(s *store.Store, wsHub *ws.Hub) *ChannelHandler {
+	return &ChannelHandler{store: s, wsHub: wsHub}
+}
+
+func (c *ChannelHandler) GetChannelsByTeamID(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	team_id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid team_id", http.StatusBadRequest)
+		return
+	}
+
+	isMember, err := c.store.IsTeamMember(requester_id, team_id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !isMember {
+		http.Error(w, "Forbidden: you are not a member of this team", http.StatusForbidden)
+		return
+	}
+
+	channels, err := c.store.GetChannelsByTeamID(team_id, requester_id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(channels)
+}
+
+func (c *ChannelHandler) CreateChannel(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var channel models.Channel
+	if err := json.NewDecoder(r.Body).Decode(&channel); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	channel.Name = normalizeChannelName(channel.Name)
+	if channel.Name == "" {
+		http.Error(w, "Channel name cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	isMember, err := c.store.IsTeamMember(requester_id, channel.TeamID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !isMember {
+		http.Error(w, "Unauthorized: you must be a member of the team to create channels", http.StatusForbidden)
+		return
+	}
+
+	channel.CreatedBy = requester_id
+	channel.IsDefault = false
+
+	err = c.store.CreateChannel(&channel)
+	if errors.Is(err, store.ErrChannelNameTaken) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if channel.IsPrivate {
+		c.wsHub.SubscribeUsersToChannel(channel.ChannelID, channel.MemberIDs)
+	} else {
+		c.wsHub.SubscribeTeamToChannel(channel.TeamID, channel.ChannelID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(channel)
+}
+
+func (c *ChannelHandler) GetChannelByID(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	channel, ok := c.authorizeChannel(w, r, requester_id)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(channel)
+}
+
+func (c *ChannelHandler) GetChannelMessages(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	channel, ok := c.authorizeChannel(w, r, requester_id)
+	if !ok {
+		return
+	}
+
+	messages, err := c.store.GetMessagesByChannelID(channel.ChannelID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(messages)
+}
+
+func (c *ChannelHandler) UpdateChannelByID(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	channel, ok := c.authorizeChannel(w, r, requester_id)
+	if !ok {
+		return
+	}
+
+	if !c.canManage(w, channel, requester_id) {
+		return
+	}
+
+	var updated_channel models.Channel
+	if err := json.NewDecoder(r.Body).Decode(&updated_channel); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	updated_channel.Name = normalizeChannelName(updated_channel.Name)
+	if updated_channel.Name == "" {
+		http.Error(w, "Channel name cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	err := c.store.UpdateChannelByID(channel.ChannelID, &updated_channel)
+	if errors.Is(err, store.ErrChannelNameTaken) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	channel.Name = updated_channel.Name
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(channel)
+}
+
+func (c *ChannelHandler) DeleteChannelByID(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	channel, ok := c.authorizeChannel(w, r, requester_id)
+	if !ok {
+		return
+	}
+
+	if !c.canManage(w, channel, requester_id) {
+		return
+	}
+
+	if channel.IsDefault {
+		http.Error(w, "The default channel cannot be deleted", http.StatusBadRequest)
+		return
+	}
+
+	if err := c.store.DeleteChannelByID(channel.ChannelID); err != nil {
+		http.Error(w, "Error deleting channel", http.StatusInternalServerError)
+		return
+	}
+
+	c.wsHub.RemoveChannel(channel.ChannelID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *ChannelHandler) AddChannelMember(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	channel, ok := c.authorizeChannel(w, r, requester_id)
+	if !ok {
+		return
+	}
+
+	if !channel.IsPrivate {
+		http.Error(w, "Public channels are open to every team member", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		UserID int `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	isMember, err := c.store.IsTeamMember(req.UserID, channel.TeamID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !isMember {
+		http.Error(w, "Only members of the team can join its channels", http.StatusBadRequest)
+		return
+	}
+
+	if err := c.store.AddChannelMember(channel.ChannelID, req.UserID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	c.wsHub.SubscribeUsersToChannel(channel.ChannelID, []int{req.UserID})
+
+	updated, err := c.store.GetChannelByID(channel.ChannelID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+func (c *ChannelHandler) RemoveChannelMember(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	channel, ok := c.authorizeChannel(w, r, requester_id)
+	if !ok {
+		return
+	}
+
+	user_id, err := strconv.Atoi(mux.Vars(r)["user_id"])
+	if err != nil {
+		http.Error(w, "Invalid user_id", http.StatusBadRequest)
+		return
+	}
+
+	if user_id != requester_id && !c.canManage(w, channel, requester_id) {
+		return
+	}
+
+	if err := c.store.RemoveChannelMember(channel.ChannelID, user_id); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "User is not a member of this channel", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	c.wsHub.UnsubscribeUsersFromChannel(channel.ChannelID, []int{user_id})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authorizeChannel loads the channel from the route and checks that the requester
+// can read it, writing the error response otherwise
+func (c *ChannelHandler) authorizeChannel(w http.ResponseWriter, r *http.Request, requester_id int) (*models.Channel, bool) {
+	channel_id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid channel id", http.StatusBadRequest)
+		return nil, false
+	}
+
+	channel, err := c.store.GetChannelByID(channel_id)
+	if err != nil {
+		http.Error(w, "No channel found with given id", http.StatusNotFound)
+		return nil, false
+	}
+
+	canAccess, err := c.store.CanAccessChannel(requester_id, channel_id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+
+	if !canAccess {
+		http.Error(w, "Forbidden: you do not have access to this channel", http.StatusForbidden)
+		return nil, false
+	}
+
+	return channel, true
+}
+
+// canManage reports whether the requester created the channel or leads its team
+func (c *ChannelHandler) canManage(w http.ResponseWriter, channel *models.Channel, requester_id int) bool {
+	if channel.CreatedBy == requester_id {
+		return true
+	}
+
+	team, err := c.store.GetTeamByID(channel.TeamID)
+	if err != nil {
+		http.Error(w, "Error getting team details", http.StatusInternalServerError)
+		return false
+	}
+
+	if team.TeamLeaderID != requester_id {
+		http.Error(w, "Unauthorized: only the channel creator or team leader can manage this channel", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func normalizeChannelName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), "-"))
+}
@@ -8,15 +8,17 @@ import (
 	"github.com/drumilbhati/teamsync/middleware"
 	"github.com/drumilbhati/teamsync/models"
 	"github.com/drumilbhati/teamsync/store"
+	"github.com/drumilbhati/teamsync/ws"
 	"github.com/gorilla/mux"
 )
 
 type CommentHandler struct {
 	store *store.Store
+	wsHub *ws.Hub
 }
 
-func NewCommentHandler(s *store.Store) *CommentHandler {
-	return &CommentHandler{store: s}
+func NewCommentHandler(s *store.Store, wsHub *ws.Hub) *CommentHandler {
+	return &CommentHandler{store: s, wsHub: wsHub}
 }
 
 func (c *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
@@ -69,6 +71,23 @@ func (c *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	excerpt := []rune(comment.Content)
+	if len(excerpt) > 100 {
+		excerpt = excerpt[:100]
+	}
+	recordActivity(c.store, c.wsHub, models.Activity{
+		TeamID:    task.TeamID,
+		ActorID:   requester_id,
+		ActorName: user.UserName,
+		Type:      models.ActivityCommentPosted,
+		TaskID:    task.TaskID,
+		Data: map[string]interface{}{
+			"title":      task.Title,
+			"comment_id": comment.CommentID,
+			"excerpt":    string(excerpt),
+		},
+	})
+
 	w.WriteHeader(http.StatusCreated)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(comment)
@@ -191,7 +210,7 @@ func (c *CommentHandler) DeleteCommentByID(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = c.store.DeleteCommentByID(comment_id)
+	err = c.store.DeleteCommentByID(comment_id, requester_id)
 	if err != nil {
 		http.Error(w, "Error deleting comment", http.StatusInternalServerError)
 		return
@@ -0,0 +1,352 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/drumilbhati/teamsync/middleware"
+	"github.com/drumilbhati/teamsync/models"
+	"github.com/drumilbhati/teamsync/store"
+	"github.com/drumilbhati/teamsync/ws"
+	"github.com/gorilla/mux"
+)
+
+type ConversationHandler struct {
+	store *store.Store
+	wsHub *ws.Hub
+}
+
+func NewConversationHandler(s *store.Store, wsHub *ws.Hub) *ConversationHandler {
+	return &ConversationHandler{store: s, wsHub: wsHub}
+}
+
+func (c *ConversationHandler) CreateConversation(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Name           string `json:"name"`
+		ParticipantIDs []int  `json:"participant_ids"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Deduplicate participants and always include the requester
+	seen := map[int]bool{requester_id: true}
+	participantIDs := []int{requester_id}
+	for _, id := range req.ParticipantIDs {
+		if seen[id] {
+			continue
+		}
+		if _, err := c.store.GetUserByID(id); err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "No user with given user_id found", http.StatusBadRequest)
+			} else {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		seen[id] = true
+		participantIDs = append(participantIDs, id)
+	}
+
+	if len(participantIDs) < 2 {
+		http.Error(w, "At least one other participant is required", http.StatusBadRequest)
+		return
+	}
+
+	conversation := models.Conversation{
+		Name:      strings.TrimSpace(req.Name),
+		IsGroup:   len(participantIDs) > 2 || strings.TrimSpace(req.Name) != "",
+		CreatedBy: requester_id,
+	}
+
+	// A 1:1 conversation is reused if it already exists
+	if !conversation.IsGroup {
+		existing, err := c.store.GetDirectConversation(requester_id, participantIDs[1])
+		if err == nil {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(existing)
+			return
+		}
+		if err != sql.ErrNoRows {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := c.store.CreateConversation(&conversation, participantIDs); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	created, err := c.store.GetConversationByID(conversation.ConversationID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+func (c *ConversationHandler) GetConversations(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	conversations, err := c.store.GetConversationsByUserID(requester_id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(conversations)
+}
+
+func (c *ConversationHandler) GetConversationByID(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	conversation_id, ok := c.authorizeParticipant(w, r, requester_id)
+	if !ok {
+		return
+	}
+
+	conversation, err := c.store.GetConversationByID(conversation_id)
+	if err != nil {
+		http.Error(w, "No conversation found with given id", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(conversation)
+}
+
+func (c *ConversationHandler) GetConversationMessages(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	conversation_id, ok := c.authorizeParticipant(w, r, requester_id)
+	if !ok {
+		return
+	}
+
+	messages, err := c.store.GetMessagesByConversationID(conversation_id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(messages)
+}
+
+func (c *ConversationHandler) CreateConversationMessage(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	conversation_id, ok := c.authorizeParticipant(w, r, requester_id)
+	if !ok {
+		return
+	}
+
+	var msg models.ConversationMessage
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if strings.TrimSpace(msg.Content) == "" {
+		http.Error(w, "Content cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	user, err := c.store.GetUserByID(requester_id)
+	if err != nil {
+		http.Error(w, "No user for given id found", http.StatusNotFound)
+		return
+	}
+
+	msg.ConversationID = conversation_id
+	msg.UserID = requester_id
+	msg.UserName = user.UserName
+
+	if err := c.Deliver(&msg); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(msg)
+}
+
+func (c *ConversationHandler) AddParticipant(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	conversation_id, ok := c.authorizeParticipant(w, r, requester_id)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		UserID int `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	conversation, err := c.store.GetConversationByID(conversation_id)
+	if err != nil {
+		http.Error(w, "No conversation found with given id", http.StatusNotFound)
+		return
+	}
+
+	if !conversation.IsGroup {
+		http.Error(w, "Participants cannot be added to a direct conversation", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := c.store.GetUserByID(req.UserID); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "No user with given user_id found", http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := c.store.AddConversationParticipant(conversation_id, req.UserID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	updated, err := c.store.GetConversationByID(conversation_id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+func (c *ConversationHandler) RemoveParticipant(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	conversation_id, ok := c.authorizeParticipant(w, r, requester_id)
+	if !ok {
+		return
+	}
+
+	user_id, err := strconv.Atoi(mux.Vars(r)["user_id"])
+	if err != nil {
+		http.Error(w, "Invalid user_id", http.StatusBadRequest)
+		return
+	}
+
+	conversation, err := c.store.GetConversationByID(conversation_id)
+	if err != nil {
+		http.Error(w, "No conversation found with given id", http.StatusNotFound)
+		return
+	}
+
+	if !conversation.IsGroup {
+		http.Error(w, "Participants cannot be removed from a direct conversation", http.StatusBadRequest)
+		return
+	}
+
+	if user_id != requester_id && conversation.CreatedBy != requester_id {
+		http.Error(w, "Unauthorized: only the creator can remove other participants", http.StatusForbidden)
+		return
+	}
+
+	if err := c.store.RemoveConversationParticipant(conversation_id, user_id); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "User is not a participant of this conversation", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Deliver persists a conversation message and pushes it to every participant's open connections
+func (c *ConversationHandler) Deliver(msg *models.ConversationMessage) error {
+	if err := c.store.CreateConversationMessage(msg); err != nil {
+		return err
+	}
+
+	participantIDs, err := c.store.GetConversationParticipantIDs(msg.ConversationID)
+	if err != nil {
+		return err
+	}
+
+	msg_bytes, err := json.Marshal(Message{
+		Type: "CONVERSATION_MESSAGE",
+		Data: msg,
+	})
+	if err != nil {
+		return err
+	}
+
+	c.wsHub.BroadcastToUsers(participantIDs, msg_bytes)
+	return nil
+}
+
+// authorizeParticipant parses the conversation id from the route and checks
+// that the requester participates in it, writing the error response otherwise
+func (c *ConversationHandler) authorizeParticipant(w http.ResponseWriter, r *http.Request, requester_id int) (int, bool) {
+	conversation_id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid conversation id", http.StatusBadRequest)
+		return 0, false
+	}
+
+	isParticipant, err := c.store.IsConversationParticipant(requester_id, conversation_id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return 0, false
+	}
+
+	if !isParticipant {
+		http.Error(w, "Forbidden: you are not a participant of this conversation", http.StatusForbidden)
+		return 0, false
+	}
+
+	return conversation_id, true
+}
@@ -2,62 +2,678 @@ package controllers
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/drumilbhati/teamsync/ai"
+	"github.com/drumilbhati/teamsync/logs"
+	"github.com/drumilbhati/teamsync/middleware"
 	"github.com/drumilbhati/teamsync/models"
-	"google.golang.org/genai"
+	"github.com/drumilbhati/teamsync/store"
+	"github.com/drumilbhati/teamsync/worker"
+	"github.com/drumilbhati/teamsync/ws"
+	"github.com/gorilla/mux"
+	"github.com/hibiken/asynq"
 )
 
-func Describe(w http.ResponseWriter, r *http.Request) {
+const (
+	maxPlanGoalLength   = 1000
+	maxAppliedPlanTasks = 50
+
+	defaultCopilotUserDailyLimit = 50
+	defaultCopilotTeamDailyLimit = 500
+)
+
+/*
+Copilot wraps the AI provider with the checks every copilot feature goes
+through: the team's AI switch, per-user and per-team daily quotas
+(COPILOT_USER_DAILY_LIMIT, default 50, and COPILOT_TEAM_DAILY_LIMIT,
+default 500) and the audit log
+*/
+type Copilot struct {
+	store          *store.Store
+	provider       ai.Provider
+	userDailyLimit int
+	teamDailyLimit int
+}
+
+func NewCopilot(s *store.Store, p ai.Provider) *Copilot {
+	return &Copilot{
+		store:          s,
+		provider:       p,
+		userDailyLimit: int(envInt64("COPILOT_USER_DAILY_LIMIT", defaultCopilotUserDailyLimit)),
+		teamDailyLimit: int(envInt64("COPILOT_TEAM_DAILY_LIMIT", defaultCopilotTeamDailyLimit)),
+	}
+}
+
+// checkEnabled rejects the request when the team has turned AI features off
+func (c *Copilot) checkEnabled(w http.ResponseWriter, teamID int) bool {
+	enabled, err := c.store.IsTeamAIEnabled(teamID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+
+	if !enabled {
+		http.Error(w, "AI features are disabled for this team", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// reserve counts one call against the daily quotas
+func (c *Copilot) reserve(w http.ResponseWriter, userID, teamID int) bool {
+	err := c.store.ReserveCopilotQuota(userID, teamID, c.userDailyLimit, c.teamDailyLimit)
+	if errors.Is(err, store.ErrCopilotUserQuotaExceeded) || errors.Is(err, store.ErrCopilotTeamQuotaExceeded) {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+// copilotCall is one metered use of the provider, written to the audit log by finish
+type copilotCall struct {
+	store  *store.Store
+	meter  *ai.Meter
+	record models.CopilotCall
+	start  time.Time
+}
+
+/*
+start checks the team switch and quotas before a synchronous copilot call.
+Calls spanning several teams pass teamID 0; the caller is then responsible
+for leaving teams with AI disabled out of the prompt.
+*/
+func (c *Copilot) start(w http.ResponseWriter, userID, teamID, taskID int, feature string) (*copilotCall, bool) {
+	if teamID != 0 && !c.checkEnabled(w, teamID) {
+		return nil, false
+	}
+	if !c.reserve(w, userID, teamID) {
+		return nil, false
+	}
+	return c.newCall(userID, teamID, taskID, feature), true
+}
+
+var errCopilotDisabled = errors.New("AI features are disabled for this team")
+
+// begin is start for calls made on the side of another request, reporting
+// a disabled team or an exhausted quota as an error instead of a response
+func (c *Copilot) begin(userID, teamID, taskID int, feature string) (*copilotCall, error) {
+	enabled, err := c.store.IsTeamAIEnabled(teamID)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, errCopilotDisabled
+	}
+	if err := c.store.ReserveCopilotQuota(userID, teamID, c.userDailyLimit, c.teamDailyLimit); err != nil {
+		return nil, err
+	}
+	return c.newCall(userID, teamID, taskID, feature), nil
+}
+
+func (c *Copilot) newCall(userID, teamID, taskID int, feature string) *copilotCall {
+	return &copilotCall{
+		store: c.store,
+		meter: ai.NewMeter(c.provider),
+		record: models.CopilotCall{
+			UserID:  userID,
+			TeamID:  teamID,
+			TaskID:  taskID,
+			Feature: feature,
+			Model:   c.provider.Name(),
+		},
+		start: time.Now(),
+	}
+}
+
+func (cc *copilotCall) finish(err error) {
+	cc.record.LatencyMs = int(time.Since(cc.start).Milliseconds())
+	cc.record.PromptTokens, cc.record.CompletionTokens = cc.meter.Tokens()
+	cc.record.Outcome = store.CopilotOutcome(err)
+	if err != nil {
+		cc.record.Error = err.Error()
+	}
+
+	if err := cc.store.CreateCopilotCall(&cc.record); err != nil {
+		logs.Log.Errorf("Failed to record copilot call: %v", err)
+	}
+}
+
+type CopilotHandler struct {
+	store   *store.Store
+	wsHub   *ws.Hub
+	copilot *Copilot
+	client  *asynq.Client
+}
+
+func NewCopilotHandler(s *store.Store, wsHub *ws.Hub, cp *Copilot, c *asynq.Client) *CopilotHandler {
+	return &CopilotHandler{store: s, wsHub: wsHub, copilot: cp, client: c}
+}
+
+// Describe enhances a draft task that has not been saved yet. The draft must carry its team_id.
+func (c *CopilotHandler) Describe(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
 	var input models.Task
 	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	isMember, err := c.store.IsTeamMember(requester_id, input.TeamID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	inputBytes, err := json.Marshal(input)
+	if !isMember {
+		http.Error(w, "Unauthorized: You must be a member of the team to create tasks", http.StatusForbidden)
+		return
+	}
+
+	call, ok := c.copilot.start(w, requester_id, input.TeamID, 0, models.CopilotFeatureDescribe)
+	if !ok {
+		return
+	}
+
+	enhanced, err := store.EnhanceTask(r.Context(), call.meter, &input)
+	call.finish(err)
+	if err != nil {
+		copilotError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(enhanced.Task)
+}
+
+// PlanTasks drafts a set of tasks for a goal. Body: {"team_id", "goal"}
+func (c *CopilotHandler) PlanTasks(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var input models.TaskPlan
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	input.Goal = strings.TrimSpace(input.Goal)
+	if input.Goal == "" {
+		http.Error(w, "goal is required", http.StatusBadRequest)
+		return
+	}
+	if len(input.Goal) > maxPlanGoalLength {
+		http.Error(w, "goal is too long", http.StatusBadRequest)
+		return
+	}
+
+	members, ok := c.planMembers(w, r, input.TeamID)
+	if !ok {
+		return
+	}
+
+	call, ok := c.copilot.start(w, requester_id, input.TeamID, 0, models.CopilotFeaturePlan)
+	if !ok {
+		return
+	}
+
+	tasks, err := store.PlanTasks(r.Context(), call.meter, input.Goal, members)
+	call.finish(err)
 	if err != nil {
+		copilotError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.TaskPlan{
+		TeamID: input.TeamID,
+		Goal:   input.Goal,
+		Tasks:  tasks,
+	})
+}
+
+// ApplyPlan creates the accepted tasks of a draft plan in one transaction
+func (c *CopilotHandler) ApplyPlan(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var plan models.TaskPlan
+	if err := json.NewDecoder(r.Body).Decode(&plan); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if len(plan.Tasks) == 0 {
+		http.Error(w, "tasks are required", http.StatusBadRequest)
+		return
+	}
+	if len(plan.Tasks) > maxAppliedPlanTasks {
+		http.Error(w, "Too many tasks", http.StatusBadRequest)
+		return
+	}
+
+	members, ok := c.planMembers(w, r, plan.TeamID)
+	if !ok {
+		return
+	}
+
+	tasks := make([]models.Task, 0, len(plan.Tasks))
+	for i := range plan.Tasks {
+		planned := &plan.Tasks[i]
+		if err := store.ValidatePlannedTask(planned, members); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		task := models.Task{
+			TeamID:       plan.TeamID,
+			CreatorID:    requester_id,
+			AssigneeName: planned.AssigneeName,
+			Title:        planned.Title,
+			Description:  sql.NullString{String: planned.Description, Valid: planned.Description != ""},
+			Status:       models.TaskStatusTodo,
+			Priority:     planned.Priority,
+		}
+		if planned.AssigneeID != 0 {
+			task.AssigneeID = sql.NullInt64{Int64: int64(planned.AssigneeID), Valid: true}
+		}
+		tasks = append(tasks, task)
+	}
+
+	if err := c.store.CreateTasks(tasks); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	ctx := context.Background()
-	client, err := genai.NewClient(ctx, nil)
+	for _, task := range tasks {
+		msg := Message{
+			Type: "TASK_CREATED",
+			Data: task,
+		}
+		msgBytes, _ := json.Marshal(msg)
+		c.wsHub.BroadcastToTeam(task.TeamID, msgBytes)
+
+		recordActivity(c.store, c.wsHub, models.Activity{
+			TeamID:  task.TeamID,
+			ActorID: requester_id,
+			Type:    models.ActivityTaskCreated,
+			TaskID:  task.TaskID,
+			Data:    map[string]interface{}{"title": task.Title, "status": task.Status, "priority": task.Priority},
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(tasks)
+}
+
+// planMembers checks the requester belongs to the team and returns its members
+func (c *CopilotHandler) planMembers(w http.ResponseWriter, r *http.Request, teamID int) ([]models.Member, bool) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	isMember, err := c.store.IsTeamMember(requester_id, teamID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+
+	if !isMember {
+		http.Error(w, "Unauthorized: You must be a member of the team to plan tasks", http.StatusForbidden)
+		return nil, false
+	}
+
+	members, err := c.store.GetMembersByTeamID(teamID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	return members, true
+}
+
+/*
+SummarizeTask returns a catch-up summary of a task's comment thread.
+
+Summaries are generated in the background: the first call queues a job and
+answers 202 with status "pending", later calls return the cached summary
+until the thread changes.
+*/
+func (c *CopilotHandler) SummarizeTask(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	prompt := fmt.Sprintf(`You are an AI assistant for a task management platform.
-You will receive a JSON object representing a task.
-Your goal is to enhance the task details to be more clear, professional, and actionable.
+	params := mux.Vars(r)
+	task_id, err := strconv.Atoi(params["id"])
+	if err != nil {
+		http.Error(w, "Invalid task_id", http.StatusBadRequest)
+		return
+	}
 
-Input Task (JSON):
-%s
+	task, err := c.store.GetTaskByTaskID(task_id)
+	if err != nil {
+		http.Error(w, "Not task found with given id", http.StatusNotFound)
+		return
+	}
 
-Enhancement Rules:
-1. **Title:** Make it concise but descriptive.
-2. **Description:** Expand on the description to provide context, potential steps, or necessary details based on the title and existing description. Ensure it is well-formatted.
-3. **Consistency:** Ensure the 'status' and 'priority' match the context of the task. If the text implies urgency, ensure priority is 'high'.
-4. **Structure:** Return the EXACT same JSON structure (fields and types) as the input. Do not add or remove fields. Only modify the values of 'title', 'description' (including 'String' and 'Valid' fields inside it), 'status', and 'priority' if needed. Preserve all IDs and dates.
+	isMember, err := c.store.IsTeamMember(requester_id, task.TeamID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-Return ONLY the raw JSON string. No markdown formatting.`, string(inputBytes))
+	if !isMember {
+		http.Error(w, "Forbidden: you are not a member of the team this task belongs to", http.StatusForbidden)
+		return
+	}
 
-	result, err := client.Models.GenerateContent(
-		ctx,
-		"gemini-3-flash-preview",
-		genai.Text(prompt),
-		nil,
-	)
+	transcript, count, err := c.store.BuildTaskThreadTranscript(task_id)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	c.serveSummary(w, worker.CopilotSummaryPayload{
+		Kind:        models.SummaryKindTask,
+		TaskID:      task_id,
+		TeamID:      task.TeamID,
+		RequestedBy: requester_id,
+	}, transcript, count)
+}
+
+/*
+SummarizeChat returns a catch-up summary of a team's chat.
+
+Query params: since (RFC3339, default 24 hours ago), channel_id (default: the team's general channel)
+*/
+func (c *CopilotHandler) SummarizeChat(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	params := mux.Vars(r)
+	team_id, err := strconv.Atoi(params["id"])
+	if err != nil {
+		http.Error(w, "Invalid team_id", http.StatusBadRequest)
+		return
+	}
+
+	// Rounded so repeated polls queue the same job
+	since := time.Now().Add(-24 * time.Hour).Truncate(time.Minute)
+	if v := r.URL.Query().Get("since"); v != "" {
+		if since, err = time.Parse(time.RFC3339, v); err != nil {
+			http.Error(w, "Invalid since, expected RFC3339", http.StatusBadRequest)
+			return
+		}
+	}
+
+	isMember, err := c.store.IsTeamMember(requester_id, team_id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !isMember {
+		http.Error(w, "Forbidden: you are not a member of this team", http.StatusForbidden)
+		return
+	}
+
+	var channel_id int
+	if v := r.URL.Query().Get("channel_id"); v != "" {
+		if channel_id, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "Invalid channel_id", http.StatusBadRequest)
+			return
+		}
+
+		channel, err := c.store.GetChannelByID(channel_id)
+		if err != nil || channel.TeamID != team_id {
+			http.Error(w, "Channel not found", http.StatusNotFound)
+			return
+		}
+
+		canAccess, err := c.store.CanAccessChannel(requester_id, channel_id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if !canAccess {
+			http.Error(w, "Forbidden: you cannot access this channel", http.StatusForbidden)
+			return
+		}
+	} else {
+		if channel_id, err = c.store.GetDefaultChannelID(team_id); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	transcript, count, err := c.store.BuildChatTranscript(channel_id, since)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	c.serveSummary(w, worker.CopilotSummaryPayload{
+		Kind:        models.SummaryKindChat,
+		ChannelID:   channel_id,
+		Since:       since.UTC(),
+		TeamID:      team_id,
+		RequestedBy: requester_id,
+	}, transcript, count)
+}
+
+// serveSummary answers from the cache or queues a job to generate the summary
+func (c *CopilotHandler) serveSummary(w http.ResponseWriter, payload worker.CopilotSummaryPayload, transcript string, count int) {
+	summary := models.Summary{
+		Status:      models.SummaryStatusReady,
+		ContentHash: store.SummaryContentHash(payload.Kind, transcript),
+		ItemCount:   count,
+	}
+
+	if !c.copilot.checkEnabled(w, payload.TeamID) {
+		return
+	}
+
+	// Nothing to summarise
+	if count == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(summary)
+		return
+	}
+
+	cached, ok, err := c.store.GetCachedSummary(summary.ContentHash)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if ok {
+		summary.Summary = cached
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(summary)
+		return
+	}
+
+	payload.ContentHash = summary.ContentHash
+	task, err := worker.NewCopilotSummaryTask(payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Only a newly queued job counts against the quota
+	if !c.copilot.reserve(w, payload.RequestedBy, payload.TeamID) {
+		return
+	}
+
+	if _, err := c.client.Enqueue(task); err != nil {
+		c.store.ReleaseCopilotQuota(payload.RequestedBy, payload.TeamID)
+
+		// A job for the same content is already queued
+		if !errors.Is(err, asynq.ErrDuplicateTask) {
+			logs.Log.Errorf("Failed to enqueue summary task: %v", err)
+			http.Error(w, "Failed to queue summary", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	summary.Status = models.SummaryStatusPending
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(summary)
+}
+
+/*
+QueryTasks answers a natural-language question about tasks. Body: {"question"}
+or, to refine a previous answer, {"filter"} with the returned filter edited.
+
+The question is translated into a validated filter over the requester's teams
+(teams with AI disabled are never sent to the model) and executed through the
+store; the response carries both the tasks and the interpreted filter.
+*/
+func (c *CopilotHandler) QueryTasks(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var input struct {
+		Question string             `json:"question"`
+		Filter   *models.TaskFilter `json:"filter"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if (input.Question == "") == (input.Filter == nil) {
+		http.Error(w, "Provide either question or filter", http.StatusBadRequest)
+		return
+	}
+
+	teams, err := c.store.GetTeamsByUserID(requester_id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	result := models.TaskQueryResult{Tasks: []models.Task{}}
+
+	if input.Filter != nil {
+		if err := c.loadMembers(teams); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if err := store.ValidateTaskFilter(input.Filter, teams); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		result.Filter = *input.Filter
+	} else {
+		question, err := store.ValidateTaskQuestion(input.Question)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		result.Question = question
+
+		var aiTeams []models.Team
+		for _, team := range teams {
+			if team.AIEnabled {
+				aiTeams = append(aiTeams, team)
+			}
+		}
+
+		if len(aiTeams) == 0 {
+			http.Error(w, "AI features are disabled for all of your teams", http.StatusForbidden)
+			return
+		}
+
+		if err := c.loadMembers(aiTeams); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		call, ok := c.copilot.start(w, requester_id, 0, 0, models.CopilotFeatureQuery)
+		if !ok {
+			return
+		}
+
+		filter, err := store.InterpretTaskQuery(r.Context(), call.meter, question, aiTeams, time.Now())
+		call.finish(err)
+		if err != nil {
+			copilotError(w, err)
+			return
+		}
+		result.Filter = *filter
+	}
+
+	// Refining with no teams left to search returns nothing rather than everything
+	if len(result.Filter.TeamIDs) > 0 {
+		if result.Tasks, err = c.store.QueryTasks(result.Filter); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	// The result.Text() should be the JSON string. We write it directly.
-	w.Write([]byte(result.Text()))
+	json.NewEncoder(w).Encode(result)
+}
+
+// loadMembers fills in the members of each team
+func (c *CopilotHandler) loadMembers(teams []models.Team) error {
+	for i := range teams {
+		members, err := c.store.GetMembersByTeamID(teams[i].TeamID)
+		if err != nil {
+			return err
+		}
+		teams[i].Members = members
+	}
+	return nil
+}
+
+// copilotError reports provider timeouts as 504, unusable answers as 502
+// and other failures as 500
+func copilotError(w http.ResponseWriter, err error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		http.Error(w, "AI provider timed out", http.StatusGatewayTimeout)
+		return
+	}
+	if errors.Is(err, store.ErrInvalidSuggestion) {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
 }
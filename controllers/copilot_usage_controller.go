@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/drumilbhati/teamsync/middleware"
+	"github.com/drumilbhati/teamsync/models"
+	"github.com/drumilbhati/teamsync/store"
+)
+
+const defaultCopilotUsageDays = 30
+
+/*
+CopilotUsageHandler serves copilot usage to the administrators listed in
+ADMIN_USER_IDS (comma separated). Costs are estimated from
+AI_PROMPT_COST_PER_MTOK and AI_COMPLETION_COST_PER_MTOK, the price per
+million prompt and completion tokens.
+*/
+type CopilotUsageHandler struct {
+	store          *store.Store
+	adminIDs       map[int]bool
+	promptCost     float64
+	completionCost float64
+}
+
+func NewCopilotUsageHandler(s *store.Store) *CopilotUsageHandler {
+	adminIDs := make(map[int]bool)
+	for _, v := range strings.Split(os.Getenv("ADMIN_USER_IDS"), ",") {
+		if id, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+			adminIDs[id] = true
+		}
+	}
+
+	return &CopilotUsageHandler{
+		store:          s,
+		adminIDs:       adminIDs,
+		promptCost:     envFloat64("AI_PROMPT_COST_PER_MTOK"),
+		completionCost: envFloat64("AI_COMPLETION_COST_PER_MTOK"),
+	}
+}
+
+/*
+GetCopilotUsage returns copilot calls aggregated per day, team, user, feature and model.
+
+Query params: from, to (RFC3339 or YYYY-MM-DD, default the last 30 days), team_id, user_id
+*/
+func (h *CopilotUsageHandler) GetCopilotUsage(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if !h.adminIDs[requester_id] {
+		http.Error(w, "Forbidden: admin access required", http.StatusForbidden)
+		return
+	}
+
+	query := r.URL.Query()
+	now := time.Now()
+	filter := models.CopilotUsageFilter{
+		From: now.AddDate(0, 0, -defaultCopilotUsageDays),
+		To:   now,
+	}
+
+	var err error
+	if v := query.Get("from"); v != "" {
+		if filter.From, err = parseSearchDate(v); err != nil {
+			http.Error(w, "Invalid from date", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if v := query.Get("to"); v != "" {
+		if filter.To, err = parseSearchDate(v); err != nil {
+			http.Error(w, "Invalid to date", http.StatusBadRequest)
+			return
+		}
+		// A bare date includes the whole day
+		if len(v) == len("2006-01-02") {
+			filter.To = filter.To.Add(24 * time.Hour)
+		}
+	}
+
+	if v := query.Get("team_id"); v != "" {
+		if filter.TeamID, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "Invalid team_id", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if v := query.Get("user_id"); v != "" {
+		if filter.UserID, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "Invalid user_id", http.StatusBadRequest)
+			return
+		}
+	}
+
+	usage, err := h.store.GetCopilotUsage(filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var totals struct {
+		Calls            int     `json:"calls"`
+		Failures         int     `json:"failures"`
+		PromptTokens     int     `json:"prompt_tokens"`
+		CompletionTokens int     `json:"completion_tokens"`
+		EstimatedCost    float64 `json:"estimated_cost"`
+	}
+	for i := range usage {
+		u := &usage[i]
+		u.EstimatedCost = (float64(u.PromptTokens)*h.promptCost + float64(u.CompletionTokens)*h.completionCost) / 1e6
+
+		totals.Calls += u.Calls
+		totals.Failures += u.Failures
+		totals.PromptTokens += u.PromptTokens
+		totals.CompletionTokens += u.CompletionTokens
+		totals.EstimatedCost += u.EstimatedCost
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"from":   filter.From,
+		"to":     filter.To,
+		"totals": totals,
+		"usage":  usage,
+	})
+}
+
+func envFloat64(name string) float64 {
+	v, err := strconv.ParseFloat(os.Getenv(name), 64)
+	if err != nil || v < 0 {
+		return 0
+	}
+	return v
+}
@@ -0,0 +1,204 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/drumilbhati/teamsync/logs"
+	"github.com/drumilbhati/teamsync/middleware"
+	"github.com/drumilbhati/teamsync/models"
+	"github.com/gorilla/mux"
+)
+
+const (
+	maxDuplicateCandidates = 5
+
+	// Duplicate detection runs inline with task creation, so it gets far
+	// less time than a copilot call
+	duplicateEmbeddingTimeout = 5 * time.Second
+)
+
+/*
+findDuplicates returns existing tasks of the team that look like task, as
+selected by DUPLICATE_DETECTION:
+
+	trigram:   title similarity with pg_trgm (default)
+	embedding: semantic similarity through the AI provider's embeddings,
+	           counted against userID's copilot quota and audited like any
+	           copilot call. Falls back to trigram when the team has AI
+	           turned off, the quota is used up or the provider fails.
+	off:       no detection
+*/
+func (c *Copilot) findDuplicates(ctx context.Context, userID int, task *models.Task) ([]models.DuplicateCandidate, error) {
+	switch strings.ToLower(os.Getenv("DUPLICATE_DETECTION")) {
+	case "off":
+		return []models.DuplicateCandidate{}, nil
+	case "embedding":
+		candidates, err := c.findDuplicatesByEmbedding(ctx, userID, task)
+		if err == nil {
+			return candidates, nil
+		}
+		if !errors.Is(err, errCopilotDisabled) {
+			logs.Log.Warnf("Embedding duplicate detection failed, using trigram similarity: %v", err)
+		}
+	}
+
+	return c.store.FindSimilarTasks(task.TeamID, task.Title, task.Description.String, task.TaskID, maxDuplicateCandidates)
+}
+
+func (c *Copilot) findDuplicatesByEmbedding(ctx context.Context, userID int, task *models.Task) ([]models.DuplicateCandidate, error) {
+	call, err := c.begin(userID, task.TeamID, task.TaskID, models.CopilotFeatureDuplicates)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, duplicateEmbeddingTimeout)
+	defer cancel()
+
+	candidates, err := c.store.FindSimilarTasksByEmbedding(ctx, call.meter, task, maxDuplicateCandidates)
+	call.finish(err)
+	return candidates, err
+}
+
+// GetDuplicates lists tasks of the same team that look like duplicates of the task
+func (t *TaskHandler) GetDuplicates(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	task_id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid task_id", http.StatusBadRequest)
+		return
+	}
+
+	task, err := t.store.GetTaskByTaskID(task_id)
+	if err != nil {
+		http.Error(w, "Not task found with given id", http.StatusNotFound)
+		return
+	}
+
+	isMember, err := t.store.IsTeamMember(requester_id, task.TeamID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !isMember {
+		http.Error(w, "Forbidden: you are not a member of the team this task belongs to", http.StatusForbidden)
+		return
+	}
+
+	candidates, err := t.copilot.findDuplicates(r.Context(), requester_id, task)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(candidates)
+}
+
+/*
+MergeTasks folds the task given as duplicate_id into the task in the route.
+The duplicate's comments, attachments and links move to the surviving task
+and the duplicate is moved to the trash. Both tasks must belong to the same
+team, and the requester must be the team leader or the creator of both.
+*/
+func (t *TaskHandler) MergeTasks(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	survivor_id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid task_id", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		DuplicateID int `json:"duplicate_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.DuplicateID == 0 {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.DuplicateID == survivor_id {
+		http.Error(w, "A task cannot be merged into itself", http.StatusBadRequest)
+		return
+	}
+
+	survivor, err := t.store.GetTaskByTaskID(survivor_id)
+	if err != nil {
+		http.Error(w, "Not task found with given id", http.StatusNotFound)
+		return
+	}
+
+	duplicate, err := t.store.GetTaskByTaskID(req.DuplicateID)
+	if err != nil {
+		http.Error(w, "Not task found with given duplicate_id", http.StatusNotFound)
+		return
+	}
+
+	if survivor.TeamID != duplicate.TeamID {
+		http.Error(w, "Only tasks of the same team can be merged", http.StatusBadRequest)
+		return
+	}
+
+	team, err := t.store.GetTeamByID(survivor.TeamID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	isCreator := survivor.CreatorID == requester_id && duplicate.CreatorID == requester_id
+	if !isCreator && team.TeamLeaderID != requester_id {
+		http.Error(w, "Forbidden: only the team leader or the creator of both tasks can merge them", http.StatusForbidden)
+		return
+	}
+
+	if err := t.store.MergeTasks(survivor.TaskID, duplicate.TaskID, requester_id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	merged, err := t.store.GetTaskByTaskID(survivor.TaskID)
+	if err != nil {
+		http.Error(w, "Unable to get task", http.StatusInternalServerError)
+		return
+	}
+
+	deleted, _ := json.Marshal(Message{
+		Type: "TASK_DELETED",
+		Data: map[string]int{"task_id": duplicate.TaskID},
+	})
+	t.wsHub.BroadcastToTeam(survivor.TeamID, deleted)
+
+	updated, _ := json.Marshal(Message{
+		Type: "TASK_UPDATED",
+		Data: merged,
+	})
+	t.wsHub.BroadcastToTeam(merged.TeamID, updated)
+
+	recordActivity(t.store, t.wsHub, models.Activity{
+		TeamID:  survivor.TeamID,
+		ActorID: requester_id,
+		Type:    models.ActivityTaskDeleted,
+		TaskID:  duplicate.TaskID,
+		Data:    map[string]interface{}{"title": duplicate.Title, "merged_into": survivor.TaskID},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(merged)
+}
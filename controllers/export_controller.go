@@ -0,0 +1,324 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/drumilbhati/teamsync/middleware"
+	"github.com/drumilbhati/teamsync/models"
+	"github.com/drumilbhati/teamsync/store"
+	"github.com/drumilbhati/teamsync/ws"
+	"github.com/gorilla/mux"
+)
+
+const (
+	maxImportBytes = 10 << 20
+	maxImportRows  = 5000
+)
+
+type ExportHandler struct {
+	store *store.Store
+	wsHub *ws.Hub
+}
+
+func NewExportHandler(s *store.Store, wsHub *ws.Hub) *ExportHandler {
+	return &ExportHandler{store: s, wsHub: wsHub}
+}
+
+/*
+ExportTeam downloads the team's data. format=json (default) returns tasks
+with their comments and the members in one document; format=csv returns one
+table chosen by resource: tasks (default), comments or members.
+*/
+func (e *ExportHandler) ExportTeam(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	team_id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid team_id", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	format := query.Get("format")
+	resource := query.Get("resource")
+	if resource == "" {
+		resource = "tasks"
+	}
+	if format != "" && format != "json" && format != "csv" {
+		http.Error(w, "format must be json or csv", http.StatusBadRequest)
+		return
+	}
+	if resource != "tasks" && resource != "comments" && resource != "members" {
+		http.Error(w, "resource must be tasks, comments or members", http.StatusBadRequest)
+		return
+	}
+
+	isMember, err := e.store.IsTeamMember(requester_id, team_id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !isMember {
+		http.Error(w, "Forbidden: you are not a member of this team", http.StatusForbidden)
+		return
+	}
+
+	export, err := e.store.GetTeamExport(team_id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if format != "csv" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="team-%d.json"`, team_id))
+		json.NewEncoder(w).Encode(export)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="team-%d-%s.csv"`, team_id, resource))
+	writeExportCSV(w, export, resource)
+}
+
+func formatExportTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// writeExportCSV writes one table of the export. The tasks table uses the
+// column names the importer reads, so it can be imported as-is.
+func writeExportCSV(w io.Writer, export *models.TeamExport, resource string) error {
+	cw := csv.NewWriter(w)
+
+	switch resource {
+	case "tasks":
+		cw.Write([]string{"task_id", "title", "description", "status", "priority", "assignee_email", "assignee_name",
+			"creator_email", "due_date", "created_at", "updated_at"})
+		for _, t := range export.Tasks {
+			cw.Write([]string{strconv.Itoa(t.TaskID), t.Title, t.Description, string(t.Status), string(t.Priority),
+				t.AssigneeEmail, t.AssigneeName, t.CreatorEmail, formatExportTime(t.DueDate),
+				formatExportTime(&t.CreatedAt), formatExportTime(t.UpdatedAt)})
+		}
+	case "comments":
+		cw.Write([]string{"comment_id", "task_id", "task_title", "author_email", "author_name", "content", "created_at"})
+		for _, t := range export.Tasks {
+			for _, c := range t.Comments {
+				cw.Write([]string{strconv.Itoa(c.CommentID), strconv.Itoa(c.TaskID), t.Title, c.AuthorEmail,
+					c.AuthorName, c.Content, formatExportTime(&c.CreatedAt)})
+			}
+		}
+	case "members":
+		cw.Write([]string{"user_id", "user_name", "email", "role", "joined_at"})
+		for _, m := range export.Members {
+			cw.Write([]string{strconv.Itoa(m.UserID), m.UserName, m.Email, m.Role, formatExportTime(&m.JoinedAt)})
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+/*
+ImportTasks imports tasks into the team from a CSV file (columns title,
+description, status, priority, assignee_email, due_date) or a JSON export
+(tasks with their comments). The file is sent as the request body or as the
+multipart field "file". With dry_run=true the rows are only validated. Any
+invalid row rejects the whole import with 422 and the row-level errors.
+*/
+func (e *ExportHandler) ImportTasks(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	team_id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid team_id", http.StatusBadRequest)
+		return
+	}
+
+	if !requireTeamLeader(w, e.store, requester_id, team_id) {
+		return
+	}
+
+	data, err := readImportFile(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+			format = "json"
+		}
+	}
+
+	var rows []models.ImportTask
+	switch format {
+	case "csv":
+		rows, err = parseImportCSV(data)
+	case "json":
+		rows, err = parseImportJSON(data)
+	default:
+		err = fmt.Errorf("format must be json or csv")
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if len(rows) == 0 {
+		http.Error(w, "The file contains no tasks", http.StatusBadRequest)
+		return
+	}
+	if len(rows) > maxImportRows {
+		http.Error(w, fmt.Sprintf("At most %d tasks can be imported at once", maxImportRows), http.StatusBadRequest)
+		return
+	}
+
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+	result, err := e.store.ImportTasks(team_id, requester_id, rows, dryRun)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusOK
+	if len(result.Errors) > 0 {
+		status = http.StatusUnprocessableEntity
+	} else if !dryRun {
+		status = http.StatusCreated
+
+		// One frame for the whole import; clients reload the team's tasks
+		msg, _ := json.Marshal(Message{
+			Type: "TASKS_IMPORTED",
+			Data: map[string]int{"team_id": team_id, "created_tasks": result.CreatedTasks},
+		})
+		e.wsHub.BroadcastToTeam(team_id, msg)
+
+		recordActivity(e.store, e.wsHub, models.Activity{
+			TeamID:  team_id,
+			ActorID: requester_id,
+			Type:    models.ActivityTasksImported,
+			Data:    map[string]interface{}{"format": format, "created_tasks": result.CreatedTasks},
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(result)
+}
+
+// readImportFile returns the uploaded file, from the multipart field "file" or the raw body
+func readImportFile(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			return nil, fmt.Errorf("file is required")
+		}
+		defer file.Close()
+		r.Body = io.NopCloser(file)
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("file is larger than %d bytes", maxImportBytes)
+	}
+	return data, nil
+}
+
+// parseImportCSV reads rows by header name; unknown columns are ignored and rows are numbered as in a spreadsheet
+func parseImportCSV(data []byte) ([]models.ImportTask, error) {
+	cr := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: missing header row")
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, fmt.Errorf("invalid CSV: a title column is required")
+	}
+
+	rows := []models.ImportTask{}
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %v", err)
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return record[i]
+			}
+			return ""
+		}
+
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		rows = append(rows, models.ImportTask{
+			Row:           line,
+			Title:         field("title"),
+			Description:   field("description"),
+			Status:        field("status"),
+			Priority:      field("priority"),
+			AssigneeEmail: field("assignee_email"),
+			DueDate:       field("due_date"),
+		})
+	}
+	return rows, nil
+}
+
+// parseImportJSON accepts a team export, or a bare array of tasks, numbering rows by position
+func parseImportJSON(data []byte) ([]models.ImportTask, error) {
+	var rows []models.ImportTask
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(data, &rows); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %v", err)
+		}
+	} else {
+		var doc struct {
+			Tasks []models.ImportTask `json:"tasks"`
+		}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %v", err)
+		}
+		rows = doc.Tasks
+	}
+
+	for i := range rows {
+		rows[i].Row = i + 1
+	}
+	return rows, nil
+}
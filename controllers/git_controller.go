@@ -0,0 +1,543 @@
+package controllers
+
+import (
+	"crypto/hmac"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/drumilbhati/teamsync/logs"
+	"github.com/drumilbhati/teamsync/middleware"
+	"github.com/drumilbhati/teamsync/models"
+	"github.com/drumilbhati/teamsync/store"
+	"github.com/drumilbhati/teamsync/worker"
+	"github.com/drumilbhati/teamsync/ws"
+	"github.com/gorilla/mux"
+)
+
+// Push payloads of large merges can be big; anything beyond this is rejected
+const maxGitWebhookBytes = 5 << 20
+
+type GitHandler struct {
+	store *store.Store
+	wsHub *ws.Hub
+}
+
+func NewGitHandler(s *store.Store, wsHub *ws.Hub) *GitHandler {
+	return &GitHandler{store: s, wsHub: wsHub}
+}
+
+/*
+CreateGitIntegration registers a GitHub or GitLab webhook for the team. The
+response carries the path to configure on the Git host and the secret, which
+is not shown again.
+*/
+func (g *GitHandler) CreateGitIntegration(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	team_id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid team_id", http.StatusBadRequest)
+		return
+	}
+
+	var integration models.GitIntegration
+	if err := json.NewDecoder(r.Body).Decode(&integration); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if integration.Provider != models.GitProviderGitHub && integration.Provider != models.GitProviderGitLab {
+		http.Error(w, "provider must be github or gitlab", http.StatusBadRequest)
+		return
+	}
+
+	if integration.Secret != "" && len(integration.Secret) < minWebhookSecretLength {
+		http.Error(w, "secret must be at least 16 characters", http.StatusBadRequest)
+		return
+	}
+
+	if !requireTeamLeader(w, g.store, requester_id, team_id) {
+		return
+	}
+
+	integration.TeamID = team_id
+	integration.CreatedBy = requester_id
+	if integration.Secret == "" {
+		if integration.Secret, err = newWebhookSecret(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := g.store.CreateGitIntegration(&integration); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(struct {
+		models.GitIntegration
+		Path string `json:"path"`
+	}{integration, fmt.Sprintf("/hooks/git/%d", integration.IntegrationID)})
+}
+
+func (g *GitHandler) GetGitIntegrations(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	team_id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid team_id", http.StatusBadRequest)
+		return
+	}
+
+	if !requireTeamLeader(w, g.store, requester_id, team_id) {
+		return
+	}
+
+	integrations, err := g.store.GetGitIntegrationsByTeamID(team_id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for i := range integrations {
+		integrations[i].Secret = ""
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(integrations)
+}
+
+func (g *GitHandler) DeleteGitIntegration(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	integration_id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid integration_id", http.StatusBadRequest)
+		return
+	}
+
+	integration, err := g.store.GetGitIntegrationByID(integration_id)
+	if err != nil {
+		http.Error(w, "Integration not found", http.StatusNotFound)
+		return
+	}
+
+	if !requireTeamLeader(w, g.store, requester_id, integration.TeamID) {
+		return
+	}
+
+	if err := g.store.DeleteGitIntegrationByID(integration_id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetTaskLinks lists the commits and pull requests that reference a task
+func (g *GitHandler) GetTaskLinks(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	task_id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid task_id", http.StatusBadRequest)
+		return
+	}
+
+	task, err := g.store.GetTaskByTaskID(task_id)
+	if err != nil {
+		http.Error(w, "Not task found with given id", http.StatusNotFound)
+		return
+	}
+
+	isMember, err := g.store.IsTeamMember(requester_id, task.TeamID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !isMember {
+		http.Error(w, "Forbidden: you are not a member of the team this task belongs to", http.StatusForbidden)
+		return
+	}
+
+	links, err := g.store.GetTaskLinksByTaskID(task_id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(links)
+}
+
+// gitEvent is a push or pull request event in a provider-neutral shape
+type gitEvent struct {
+	repository string
+	commits    []gitCommit
+	pull       *gitPullRequest
+}
+
+type gitCommit struct {
+	sha, message, url, author string
+}
+
+type gitPullRequest struct {
+	number                           int
+	title, body, branch, url, author string
+
+	// state is open, merged or closed; opened is set when the pull request
+	// was just opened or reopened
+	state  string
+	opened bool
+}
+
+/*
+ReceiveGitWebhook is the public endpoint Git hosts post to. GitHub requests
+are verified by their X-Hub-Signature-256 HMAC, GitLab requests by the
+X-Gitlab-Token secret. Tasks of the integration's team referenced as TS-123
+or "fixes #123" in commit messages, pull request titles, descriptions or
+branch names are linked to the commit or pull request.
+*/
+func (g *GitHandler) ReceiveGitWebhook(w http.ResponseWriter, r *http.Request) {
+	integration_id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid integration_id", http.StatusBadRequest)
+		return
+	}
+
+	integration, err := g.store.GetGitIntegrationByID(integration_id)
+	if err != nil {
+		http.Error(w, "Integration not found", http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxGitWebhookBytes))
+	if err != nil {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var event *gitEvent
+	switch integration.Provider {
+	case models.GitProviderGitHub:
+		signature := r.Header.Get("X-Hub-Signature-256")
+		if !hmac.Equal([]byte(signature), []byte(worker.SignWebhookPayload(integration.Secret, body))) {
+			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+			return
+		}
+		event, err = parseGitHubEvent(r.Header.Get("X-GitHub-Event"), body)
+	case models.GitProviderGitLab:
+		token := r.Header.Get("X-Gitlab-Token")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(integration.Secret)) != 1 {
+			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			return
+		}
+		event, err = parseGitLabEvent(r.Header.Get("X-Gitlab-Event"), body)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	linked, updated := 0, 0
+	if event != nil {
+		linked, updated = g.applyGitEvent(integration, event)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{
+		"linked":  linked,
+		"updated": updated,
+	})
+}
+
+// applyGitEvent links the referenced tasks and, when enabled, moves them along
+func (g *GitHandler) applyGitEvent(integration *models.GitIntegration, event *gitEvent) (linked, updated int) {
+	tasks := map[int]*models.Task{}
+	teamTask := func(id int) *models.Task {
+		if task, ok := tasks[id]; ok {
+			return task
+		}
+		task, err := g.store.GetTaskByTaskID(id)
+		// References to other teams' tasks are ignored
+		if err != nil || task.TeamID != integration.TeamID {
+			task = nil
+		}
+		tasks[id] = task
+		return task
+	}
+
+	link := func(task *models.Task, l models.TaskLink) {
+		l.TaskID = task.TaskID
+		l.Provider = integration.Provider
+		l.Repository = event.repository
+
+		created, err := g.store.UpsertTaskLink(&l)
+		if err != nil {
+			logs.Log.Errorf("Failed to link %s %s to task %d: %v", l.Kind, l.ExternalID, task.TaskID, err)
+			return
+		}
+
+		// Pull requests are re-announced as their state changes; repeated pushes are not
+		if created || l.Kind == models.TaskLinkPullRequest {
+			linked++
+			msg, _ := json.Marshal(Message{
+				Type: "TASK_LINKED",
+				Data: l,
+			})
+			g.wsHub.BroadcastToTeam(task.TeamID, msg)
+		}
+	}
+
+	for _, c := range event.commits {
+		title, _, _ := strings.Cut(c.message, "\n")
+		for _, id := range store.ParseTaskReferences(c.message) {
+			if task := teamTask(id); task != nil {
+				link(task, models.TaskLink{
+					Kind:       models.TaskLinkCommit,
+					ExternalID: c.sha,
+					URL:        c.url,
+					Title:      title,
+					Author:     c.author,
+				})
+			}
+		}
+	}
+
+	pr := event.pull
+	if pr == nil {
+		return linked, updated
+	}
+
+	for _, id := range store.ParseTaskReferences(pr.title, pr.body, pr.branch) {
+		task := teamTask(id)
+		if task == nil {
+			continue
+		}
+
+		link(task, models.TaskLink{
+			Kind:       models.TaskLinkPullRequest,
+			ExternalID: strconv.Itoa(pr.number),
+			URL:        pr.url,
+			Title:      pr.title,
+			Author:     pr.author,
+			State:      pr.state,
+		})
+
+		if !integration.AutoTransition {
+			continue
+		}
+
+		status := task.Status
+		switch {
+		case pr.state == models.PullRequestMerged:
+			status = models.TaskStatusDone
+		case pr.opened && (task.Status == models.TaskStatusTodo || task.Status == models.TaskStatusInProgress):
+			status = models.TaskStatusInReview
+		}
+		if status == task.Status {
+			continue
+		}
+
+		before := *task
+		task.Status = status
+		if err := g.store.UpdateTaskByID(task.TaskID, task); err != nil {
+			logs.Log.Errorf("Failed to move task %d to %s: %v", task.TaskID, status, err)
+			continue
+		}
+		updated++
+
+		msg, _ := json.Marshal(Message{
+			Type: "TASK_UPDATED",
+			Data: task,
+		})
+		g.wsHub.BroadcastToTeam(task.TeamID, msg)
+
+		// Moved by the pull request's author, who may not be a TeamSync user
+		recordTaskChanges(g.store, g.wsHub, 0, pr.author, &before, task)
+	}
+
+	return linked, updated
+}
+
+// parseGitHubEvent reads push and pull_request payloads; other events are acknowledged and ignored
+func parseGitHubEvent(eventType string, body []byte) (*gitEvent, error) {
+	switch eventType {
+	case "push":
+		var p struct {
+			Repository struct {
+				FullName string `json:"full_name"`
+			} `json:"repository"`
+			Commits []struct {
+				ID      string `json:"id"`
+				Message string `json:"message"`
+				URL     string `json:"url"`
+				Author  struct {
+					Name     string `json:"name"`
+					Username string `json:"username"`
+				} `json:"author"`
+			} `json:"commits"`
+		}
+		if err := json.Unmarshal(body, &p); err != nil {
+			return nil, errors.New("invalid push payload")
+		}
+
+		event := &gitEvent{repository: p.Repository.FullName}
+		for _, c := range p.Commits {
+			author := c.Author.Username
+			if author == "" {
+				author = c.Author.Name
+			}
+			event.commits = append(event.commits, gitCommit{sha: c.ID, message: c.Message, url: c.URL, author: author})
+		}
+		return event, nil
+
+	case "pull_request":
+		var p struct {
+			Action     string `json:"action"`
+			Repository struct {
+				FullName string `json:"full_name"`
+			} `json:"repository"`
+			PullRequest struct {
+				Number  int    `json:"number"`
+				Title   string `json:"title"`
+				Body    string `json:"body"`
+				HTMLURL string `json:"html_url"`
+				State   string `json:"state"`
+				Merged  bool   `json:"merged"`
+				Head    struct {
+					Ref string `json:"ref"`
+				} `json:"head"`
+				User struct {
+					Login string `json:"login"`
+				} `json:"user"`
+			} `json:"pull_request"`
+		}
+		if err := json.Unmarshal(body, &p); err != nil {
+			return nil, errors.New("invalid pull_request payload")
+		}
+
+		pr := p.PullRequest
+		state := models.PullRequestOpen
+		if pr.Merged {
+			state = models.PullRequestMerged
+		} else if pr.State == "closed" {
+			state = models.PullRequestClosed
+		}
+
+		return &gitEvent{
+			repository: p.Repository.FullName,
+			pull: &gitPullRequest{
+				number: pr.Number,
+				title:  pr.Title,
+				body:   pr.Body,
+				branch: pr.Head.Ref,
+				url:    pr.HTMLURL,
+				author: pr.User.Login,
+				state:  state,
+				opened: p.Action == "opened" || p.Action == "reopened" || p.Action == "ready_for_review",
+			},
+		}, nil
+	}
+	return nil, nil
+}
+
+// parseGitLabEvent reads push and merge request payloads; other events are acknowledged and ignored
+func parseGitLabEvent(eventType string, body []byte) (*gitEvent, error) {
+	var project struct {
+		Project struct {
+			PathWithNamespace string `json:"path_with_namespace"`
+		} `json:"project"`
+	}
+
+	switch eventType {
+	case "Push Hook":
+		var p struct {
+			Commits []struct {
+				ID      string `json:"id"`
+				Message string `json:"message"`
+				URL     string `json:"url"`
+				Author  struct {
+					Name string `json:"name"`
+				} `json:"author"`
+			} `json:"commits"`
+		}
+		if json.Unmarshal(body, &p) != nil || json.Unmarshal(body, &project) != nil {
+			return nil, errors.New("invalid push payload")
+		}
+
+		event := &gitEvent{repository: project.Project.PathWithNamespace}
+		for _, c := range p.Commits {
+			event.commits = append(event.commits, gitCommit{sha: c.ID, message: c.Message, url: c.URL, author: c.Author.Name})
+		}
+		return event, nil
+
+	case "Merge Request Hook":
+		var p struct {
+			User struct {
+				Username string `json:"username"`
+			} `json:"user"`
+			ObjectAttributes struct {
+				IID          int    `json:"iid"`
+				Title        string `json:"title"`
+				Description  string `json:"description"`
+				URL          string `json:"url"`
+				State        string `json:"state"`
+				Action       string `json:"action"`
+				SourceBranch string `json:"source_branch"`
+			} `json:"object_attributes"`
+		}
+		if json.Unmarshal(body, &p) != nil || json.Unmarshal(body, &project) != nil {
+			return nil, errors.New("invalid merge request payload")
+		}
+
+		mr := p.ObjectAttributes
+		state := models.PullRequestOpen
+		switch mr.State {
+		case "merged":
+			state = models.PullRequestMerged
+		case "closed":
+			state = models.PullRequestClosed
+		}
+
+		return &gitEvent{
+			repository: project.Project.PathWithNamespace,
+			pull: &gitPullRequest{
+				number: mr.IID,
+				title:  mr.Title,
+				body:   mr.Description,
+				branch: mr.SourceBranch,
+				url:    mr.URL,
+				author: p.User.Username,
+				state:  state,
+				opened: mr.Action == "open" || mr.Action == "reopen",
+			},
+		}, nil
+	}
+	return nil, nil
+}
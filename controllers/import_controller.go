@@ -0,0 +1,153 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/drumilbhati/teamsync/importer"
+	"github.com/drumilbhati/teamsync/logs"
+	"github.com/drumilbhati/teamsync/middleware"
+	"github.com/drumilbhati/teamsync/models"
+	"github.com/drumilbhati/teamsync/storage"
+	"github.com/drumilbhati/teamsync/store"
+	"github.com/drumilbhati/teamsync/worker"
+	"github.com/gorilla/mux"
+	"github.com/hibiken/asynq"
+)
+
+// Trello exports include the board's whole action history and get large
+const maxExternalImportBytes = 50 << 20
+
+type ImportHandler struct {
+	store   *store.Store
+	storage storage.Storage
+	client  *asynq.Client
+}
+
+func NewImportHandler(s *store.Store, st storage.Storage, c *asynq.Client) *ImportHandler {
+	return &ImportHandler{store: s, storage: st, client: c}
+}
+
+/*
+ImportExternal queues an import of a Trello board JSON or Jira CSV export,
+sent as the multipart field "file". The optional field "user_map" is a JSON
+object mapping source usernames, ids or names to emails of team members.
+The response is the queued job; poll GetImportJob for progress and the report.
+*/
+func (h *ImportHandler) ImportExternal(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	params := mux.Vars(r)
+	team_id, err := strconv.Atoi(params["id"])
+	if err != nil {
+		http.Error(w, "Invalid team_id", http.StatusBadRequest)
+		return
+	}
+
+	source := params["source"]
+	if source != importer.SourceTrello && source != importer.SourceJira {
+		http.Error(w, "source must be trello or jira", http.StatusBadRequest)
+		return
+	}
+
+	if !requireTeamLeader(w, h.store, requester_id, team_id) {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxExternalImportBytes)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		http.Error(w, "Invalid upload: file is required and must be at most 50 MB", http.StatusBadRequest)
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	job := models.ImportJob{
+		TeamID:      team_id,
+		RequestedBy: requester_id,
+		Source:      source,
+		UserMap:     map[string]string{},
+		DryRun:      r.URL.Query().Get("dry_run") == "true",
+	}
+	if v := r.FormValue("user_map"); v != "" {
+		if err := json.Unmarshal([]byte(v), &job.UserMap); err != nil {
+			http.Error(w, "user_map must be a JSON object of names to emails", http.StatusBadRequest)
+			return
+		}
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	job.StorageKey = fmt.Sprintf("imports/%d/%s", team_id, hex.EncodeToString(b))
+
+	if err := h.storage.Put(r.Context(), job.StorageKey, file, header.Size, header.Header.Get("Content-Type")); err != nil {
+		logs.Log.Errorf("Error storing import file: %v", err)
+		http.Error(w, "Error storing import file", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.store.CreateImportJob(&job); err != nil {
+		h.storage.Delete(r.Context(), job.StorageKey)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	task, err := worker.NewExternalImportTask(job.JobID)
+	if err == nil {
+		_, err = h.client.Enqueue(task)
+	}
+	if err != nil {
+		logs.Log.Errorf("Failed to enqueue import job %d: %v", job.JobID, err)
+		h.store.FinishImportJob(job.JobID, models.ImportJobFailed, nil, "failed to queue the import")
+		http.Error(w, "Failed to queue import", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// GetImportJob returns an import job's progress and, once finished, its report
+func (h *ImportHandler) GetImportJob(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	job_id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid job_id", http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.store.GetImportJobByID(job_id)
+	if err != nil {
+		http.Error(w, "Import job not found", http.StatusNotFound)
+		return
+	}
+
+	if job.RequestedBy != requester_id && !requireTeamLeader(w, h.store, requester_id, job.TeamID) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
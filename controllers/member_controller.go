@@ -6,18 +6,21 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/drumilbhati/teamsync/logs"
 	"github.com/drumilbhati/teamsync/middleware"
 	"github.com/drumilbhati/teamsync/models"
 	"github.com/drumilbhati/teamsync/store"
+	"github.com/drumilbhati/teamsync/ws"
 	"github.com/gorilla/mux"
 )
 
 type MemberHandler struct {
 	store *store.Store
+	wsHub *ws.Hub
 }
 
-func NewMemberHandler(s *store.Store) *MemberHandler {
-	return &MemberHandler{store: s}
+func NewMemberHandler(s *store.Store, wsHub *ws.Hub) *MemberHandler {
+	return &MemberHandler{store: s, wsHub: wsHub}
 }
 
 func (m *MemberHandler) GetMemberByID(w http.ResponseWriter, r *http.Request) {
@@ -159,6 +162,35 @@ func (m *MemberHandler) CreateMember(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Subscribe the new member's open connections before announcing them,
+	// so they receive their own MEMBER_ADDED event
+	channelIDs, err := m.store.GetTeamChannelIDsForUser(member.TeamID, member.UserID)
+	if err != nil {
+		logs.Log.Errorf("Error fetching channels for new member: %v", err)
+	}
+	m.wsHub.JoinTeam(member.TeamID, member.UserID, channelIDs)
+
+	if added, err := m.store.GetMemberByID(member.MemberID); err == nil {
+		member.UserName = added.UserName
+		member.Email = added.Email
+	}
+
+	msg_bytes, _ := json.Marshal(Message{
+		Type: "MEMBER_ADDED",
+		Data: member,
+	})
+	m.wsHub.BroadcastToTeam(member.TeamID, msg_bytes)
+
+	recordActivity(m.store, m.wsHub, models.Activity{
+		TeamID:  member.TeamID,
+		ActorID: requester_id,
+		Type:    models.ActivityMemberJoined,
+		Data: map[string]interface{}{
+			"user_id":   member.UserID,
+			"user_name": member.UserName,
+		},
+	})
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(member)
 }
@@ -238,15 +270,43 @@ func (m *MemberHandler) DeleteMemberByID(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if team.TeamLeaderID != requester_id && mem.MemberID != requester_id {
+	if team.TeamLeaderID != requester_id && mem.UserID != requester_id {
 		http.Error(w, "Unauthorized: only the team leader can delete member details", http.StatusForbidden)
 		return
 	}
 
+	channelIDs, err := m.store.GetChannelIDsByTeamID(mem.TeamID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	if err := m.store.DeleteMemberByID(member_id); err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
+	// Announce the removal before unsubscribing, so the removed member is told too
+	msg_bytes, _ := json.Marshal(Message{
+		Type: "MEMBER_REMOVED",
+		Data: mem,
+	})
+	m.wsHub.BroadcastToTeam(mem.TeamID, msg_bytes)
+
+	recordActivity(m.store, m.wsHub, models.Activity{
+		TeamID:  mem.TeamID,
+		ActorID: requester_id,
+		Type:    models.ActivityMemberLeft,
+		Data: map[string]interface{}{
+			"user_id":   mem.UserID,
+			"user_name": mem.UserName,
+		},
+	})
+
+	// The team leader keeps access through teams.team_leader_id
+	if mem.UserID != team.TeamLeaderID {
+		m.wsHub.LeaveTeam(mem.TeamID, mem.UserID, channelIDs)
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/drumilbhati/teamsync/middleware"
+	"github.com/drumilbhati/teamsync/models"
+	"github.com/drumilbhati/teamsync/store"
+)
+
+const (
+	maxMyWorkTasks    = 500
+	myWorkRecentDays  = 14
+	myWorkRecentLimit = 20
+)
+
+type MyWorkHandler struct {
+	store *store.Store
+}
+
+func NewMyWorkHandler(s *store.Store) *MyWorkHandler {
+	return &MyWorkHandler{store: s}
+}
+
+/*
+GetMyWork returns the requester's open tasks across all their teams, grouped
+by due date, with recent mentions of them and comments on their tasks. Days
+and weeks (starting on Monday) follow the optional tz query parameter, an
+IANA timezone defaulting to UTC.
+*/
+func (h *MyWorkHandler) GetMyWork(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	loc := time.UTC
+	if v := r.URL.Query().Get("tz"); v != "" {
+		var err error
+		if loc, err = time.LoadLocation(v); err != nil {
+			http.Error(w, "Invalid timezone", http.StatusBadRequest)
+			return
+		}
+	}
+
+	teams, err := h.store.GetTeamsByUserID(requester_id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	teamIDs := make([]int, len(teams))
+	for i, team := range teams {
+		teamIDs[i] = team.TeamID
+	}
+
+	tasks, err := h.store.QueryTasks(models.TaskFilter{
+		TeamIDs:     teamIDs,
+		Statuses:    []models.TaskStatus{models.TaskStatusTodo, models.TaskStatusInProgress, models.TaskStatusInReview},
+		AssigneeIDs: []int{requester_id},
+		Limit:       maxMyWorkTasks,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	work := groupMyWork(tasks, time.Now().In(loc))
+
+	since := time.Now().AddDate(0, 0, -myWorkRecentDays)
+	if work.Mentions, err = h.store.GetRecentMentions(requester_id, teamIDs, since, myWorkRecentLimit); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if work.Comments, err = h.store.GetRecentCommentsOnUserTasks(requester_id, teamIDs, since, myWorkRecentLimit); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(work)
+}
+
+/*
+groupMyWork sorts tasks, already ordered by due date, into the groups of the
+dashboard. A due date without a time of day is due until the end of that day
+in now's location, not overdue from its start.
+*/
+func groupMyWork(tasks []models.Task, now time.Time) *models.MyWork {
+	work := &models.MyWork{
+		Overdue:  []models.Task{},
+		DueToday: []models.Task{},
+		ThisWeek: []models.Task{},
+		Later:    []models.Task{},
+		NoDate:   []models.Task{},
+	}
+
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	endOfDay := startOfDay.AddDate(0, 0, 1)
+	daysToMonday := (7 - int(now.Weekday()) + int(time.Monday)) % 7
+	if daysToMonday == 0 {
+		daysToMonday = 7
+	}
+	endOfWeek := endOfDay.AddDate(0, 0, daysToMonday-1)
+
+	for _, task := range tasks {
+		if !task.DueDate.Valid {
+			work.NoDate = append(work.NoDate, task)
+			continue
+		}
+
+		due, allDay := myWorkDue(task.DueDate.Time, now.Location())
+		overdue := due.Before(now)
+		if allDay {
+			overdue = due.Before(startOfDay)
+		}
+
+		switch {
+		case overdue:
+			work.Overdue = append(work.Overdue, task)
+		case due.Before(endOfDay):
+			work.DueToday = append(work.DueToday, task)
+		case due.Before(endOfWeek):
+			work.ThisWeek = append(work.ThisWeek, task)
+		default:
+			work.Later = append(work.Later, task)
+		}
+	}
+	return work
+}
+
+/*
+myWorkDue returns when a task is due in loc and whether it is due on a whole
+day rather than at a time. As in the calendar feed, a due date at midnight in
+loc, or at midnight UTC where dates without a time are stored, stands for
+that calendar day.
+*/
+func myWorkDue(due time.Time, loc *time.Location) (time.Time, bool) {
+	isMidnight := func(t time.Time) bool {
+		return t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0
+	}
+	switch {
+	case isMidnight(due.In(loc)):
+		return due.In(loc), true
+	case isMidnight(due.UTC()):
+		d := due.UTC()
+		return time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, loc), true
+	}
+	return due.In(loc), false
+}
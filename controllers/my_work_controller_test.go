@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/drumilbhati/teamsync/models"
+)
+
+func TestGroupMyWork(t *testing.T) {
+	west := time.FixedZone("UTC-8", -8*60*60)
+	east := time.FixedZone("UTC+5:30", (5*60+30)*60)
+	// Wednesday morning west of UTC, when it is already afternoon in UTC
+	wednesday := time.Date(2024, 3, 6, 10, 0, 0, 0, west)
+	// Sunday, the last day of the week
+	sunday := time.Date(2024, 3, 10, 12, 0, 0, 0, west)
+	// Just after midnight east of UTC, when it is still the previous day in UTC
+	eastMorning := time.Date(2024, 3, 6, 1, 0, 0, 0, east)
+
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		due  time.Time
+		want string
+	}{
+		{"no due date", wednesday, time.Time{}, "no_date"},
+		{"date today", wednesday, date(2024, 3, 6), "due_today"},
+		{"date yesterday", wednesday, date(2024, 3, 5), "overdue"},
+		{"date tomorrow", wednesday, date(2024, 3, 7), "this_week"},
+		{"time earlier today", wednesday, time.Date(2024, 3, 6, 9, 0, 0, 0, west), "overdue"},
+		{"time later today", wednesday, time.Date(2024, 3, 6, 18, 0, 0, 0, west), "due_today"},
+		{"time at end of today", wednesday, time.Date(2024, 3, 6, 23, 59, 0, 0, west), "due_today"},
+		{"local midnight today", wednesday, time.Date(2024, 3, 6, 0, 0, 0, 0, west), "due_today"},
+		{"date on sunday", wednesday, date(2024, 3, 10), "this_week"},
+		{"time on sunday night", wednesday, time.Date(2024, 3, 10, 23, 0, 0, 0, west), "this_week"},
+		{"date next monday", wednesday, date(2024, 3, 11), "later"},
+		{"local midnight next monday", wednesday, time.Date(2024, 3, 11, 0, 0, 0, 0, west), "later"},
+		{"date today on sunday", sunday, date(2024, 3, 10), "due_today"},
+		{"date monday from sunday", sunday, date(2024, 3, 11), "later"},
+		{"date today east of utc", eastMorning, date(2024, 3, 6), "due_today"},
+		{"date yesterday east of utc", eastMorning, date(2024, 3, 5), "overdue"},
+		{"date tomorrow east of utc", eastMorning, date(2024, 3, 7), "this_week"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := models.Task{TaskID: 1}
+			if !tt.due.IsZero() {
+				task.DueDate = sql.NullTime{Time: tt.due, Valid: true}
+			}
+
+			work := groupMyWork([]models.Task{task}, tt.now)
+			groups := map[string][]models.Task{
+				"overdue":   work.Overdue,
+				"due_today": work.DueToday,
+				"this_week": work.ThisWeek,
+				"later":     work.Later,
+				"no_date":   work.NoDate,
+			}
+			for name, tasks := range groups {
+				if got := len(tasks) == 1; got != (name == tt.want) {
+					t.Errorf("task in %s = %v, want it in %s", name, got, tt.want)
+				}
+			}
+		})
+	}
+}
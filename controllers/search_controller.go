@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/drumilbhati/teamsync/middleware"
+	"github.com/drumilbhati/teamsync/models"
+	"github.com/drumilbhati/teamsync/store"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+type SearchHandler struct {
+	store *store.Store
+}
+
+func NewSearchHandler(s *store.Store) *SearchHandler {
+	return &SearchHandler{store: s}
+}
+
+/*
+Search runs a full-text search over team chat, task comments and tasks.
+
+Query params: q (required), team_id, type (comma separated: message, comment, task),
+author_id, from, to (RFC3339 or YYYY-MM-DD), limit, offset
+*/
+func (h *SearchHandler) Search(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+
+	filter := models.SearchFilter{
+		Query: strings.TrimSpace(query.Get("q")),
+		Types: []string{models.SearchTypeMessage, models.SearchTypeComment, models.SearchTypeTask},
+		Limit: defaultSearchLimit,
+	}
+
+	if filter.Query == "" {
+		http.Error(w, "q is required", http.StatusBadRequest)
+		return
+	}
+
+	if types := query.Get("type"); types != "" {
+		filter.Types = nil
+		for _, t := range strings.Split(types, ",") {
+			t = strings.TrimSpace(t)
+			switch t {
+			case models.SearchTypeMessage, models.SearchTypeComment, models.SearchTypeTask:
+				filter.Types = append(filter.Types, t)
+			default:
+				http.Error(w, "Invalid type: "+t, http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	var err error
+	if v := query.Get("author_id"); v != "" {
+		if filter.AuthorID, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "Invalid author_id", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if v := query.Get("from"); v != "" {
+		if filter.From, err = parseSearchDate(v); err != nil {
+			http.Error(w, "Invalid from date", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if v := query.Get("to"); v != "" {
+		if filter.To, err = parseSearchDate(v); err != nil {
+			http.Error(w, "Invalid to date", http.StatusBadRequest)
+			return
+		}
+		// A bare date includes the whole day
+		if len(v) == len("2006-01-02") {
+			filter.To = filter.To.Add(24*time.Hour - time.Nanosecond)
+		}
+	}
+
+	if v := query.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit < 1 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		if filter.Limit > maxSearchLimit {
+			filter.Limit = maxSearchLimit
+		}
+	}
+
+	if v := query.Get("offset"); v != "" {
+		if filter.Offset, err = strconv.Atoi(v); err != nil || filter.Offset < 0 {
+			http.Error(w, "Invalid offset", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Scope the search to one team or every team the requester belongs to
+	if v := query.Get("team_id"); v != "" {
+		team_id, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid team_id", http.StatusBadRequest)
+			return
+		}
+
+		isMember, err := h.store.IsTeamMember(requester_id, team_id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if !isMember {
+			http.Error(w, "Forbidden: you are not a member of this team", http.StatusForbidden)
+			return
+		}
+		filter.TeamIDs = []int{team_id}
+	} else {
+		teams, err := h.store.GetTeamsByUserID(requester_id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, team := range teams {
+			filter.TeamIDs = append(filter.TeamIDs, team.TeamID)
+		}
+	}
+
+	results := []models.SearchResult{}
+	if len(filter.TeamIDs) > 0 {
+		results, err = h.store.Search(requester_id, filter)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+func parseSearchDate(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", v)
+}
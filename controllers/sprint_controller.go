@@ -0,0 +1,433 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/drumilbhati/teamsync/logs"
+	"github.com/drumilbhati/teamsync/middleware"
+	"github.com/drumilbhati/teamsync/models"
+	"github.com/drumilbhati/teamsync/store"
+	"github.com/drumilbhati/teamsync/ws"
+	"github.com/gorilla/mux"
+)
+
+type SprintHandler struct {
+	store *store.Store
+	wsHub *ws.Hub
+}
+
+func NewSprintHandler(s *store.Store, wsHub *ws.Hub) *SprintHandler {
+	return &SprintHandler{store: s, wsHub: wsHub}
+}
+
+// validateSprint checks the editable fields of a sprint
+func validateSprint(sp *models.Sprint) error {
+	sp.Name = strings.TrimSpace(sp.Name)
+	if sp.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(sp.Name) > 255 {
+		return fmt.Errorf("name must be at most 255 characters")
+	}
+
+	start, err := time.Parse("2006-01-02", sp.StartDate)
+	if err != nil {
+		return fmt.Errorf("start_date must be a date (YYYY-MM-DD)")
+	}
+	end, err := time.Parse("2006-01-02", sp.EndDate)
+	if err != nil {
+		return fmt.Errorf("end_date must be a date (YYYY-MM-DD)")
+	}
+	if end.Before(start) {
+		return fmt.Errorf("end_date must not be before start_date")
+	}
+	return nil
+}
+
+/*
+sprintFromRoute loads the sprint in the route and checks the requester may
+see it, or manage it when leaderOnly is set. On failure the response has
+been written.
+*/
+func (h *SprintHandler) sprintFromRoute(w http.ResponseWriter, r *http.Request, leaderOnly bool) (*models.Sprint, bool) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	sprint_id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid sprint_id", http.StatusBadRequest)
+		return nil, false
+	}
+
+	sprint, err := h.store.GetSprintByID(sprint_id)
+	if err != nil {
+		http.Error(w, "Sprint not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	if leaderOnly {
+		team, err := h.store.GetTeamByID(sprint.TeamID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return nil, false
+		}
+		if team.TeamLeaderID != requester_id {
+			http.Error(w, "Forbidden: only the team leader can manage sprints", http.StatusForbidden)
+			return nil, false
+		}
+		return sprint, true
+	}
+
+	isMember, err := h.store.IsTeamMember(requester_id, sprint.TeamID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	if !isMember {
+		http.Error(w, "Forbidden: you are not a member of this team", http.StatusForbidden)
+		return nil, false
+	}
+	return sprint, true
+}
+
+func (h *SprintHandler) broadcastSprint(msgType string, sprint *models.Sprint) {
+	msg_bytes, _ := json.Marshal(Message{
+		Type: msgType,
+		Data: sprint,
+	})
+	h.wsHub.BroadcastToTeam(sprint.TeamID, msg_bytes)
+}
+
+// CreateSprint plans a new sprint for the team
+func (h *SprintHandler) CreateSprint(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	team_id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid team_id", http.StatusBadRequest)
+		return
+	}
+
+	team, err := h.store.GetTeamByID(team_id)
+	if err != nil {
+		http.Error(w, "Team not found", http.StatusNotFound)
+		return
+	}
+	if team.TeamLeaderID != requester_id {
+		http.Error(w, "Forbidden: only the team leader can manage sprints", http.StatusForbidden)
+		return
+	}
+
+	var sprint models.Sprint
+	if err := json.NewDecoder(r.Body).Decode(&sprint); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := validateSprint(&sprint); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sprint.TeamID = team_id
+	sprint.CreatedBy = requester_id
+	if err := h.store.CreateSprint(&sprint); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.broadcastSprint("SPRINT_CREATED", &sprint)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(sprint)
+}
+
+// GetSprintsByTeamID lists the team's sprints in the order they run
+func (h *SprintHandler) GetSprintsByTeamID(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	team_id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid team_id", http.StatusBadRequest)
+		return
+	}
+
+	isMember, err := h.store.IsTeamMember(requester_id, team_id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !isMember {
+		http.Error(w, "Forbidden: you are not a member of this team", http.StatusForbidden)
+		return
+	}
+
+	sprints, err := h.store.GetSprintsByTeamID(team_id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sprints)
+}
+
+func (h *SprintHandler) GetSprintByID(w http.ResponseWriter, r *http.Request) {
+	sprint, ok := h.sprintFromRoute(w, r, false)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sprint)
+}
+
+// UpdateSprint changes the name, goal and dates of a sprint that is not closed
+func (h *SprintHandler) UpdateSprint(w http.ResponseWriter, r *http.Request) {
+	sprint, ok := h.sprintFromRoute(w, r, true)
+	if !ok {
+		return
+	}
+
+	var req models.Sprint
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := validateSprint(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sprint.Name, sprint.Goal, sprint.StartDate, sprint.EndDate = req.Name, req.Goal, req.StartDate, req.EndDate
+	if err := h.store.UpdateSprint(sprint); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "A closed sprint cannot be changed", http.StatusConflict)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.broadcastSprint("SPRINT_UPDATED", sprint)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sprint)
+}
+
+// StartSprint makes a planned sprint the team's active one, committing to the tasks in it
+func (h *SprintHandler) StartSprint(w http.ResponseWriter, r *http.Request) {
+	sprint, ok := h.sprintFromRoute(w, r, true)
+	if !ok {
+		return
+	}
+
+	if err := h.store.StartSprint(sprint.SprintID); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			http.Error(w, "Only a planned sprint can be started", http.StatusConflict)
+		case errors.Is(err, store.ErrSprintAlreadyActive):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	sprint, err := h.store.GetSprintByID(sprint.SprintID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.broadcastSprint("SPRINT_UPDATED", sprint)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sprint)
+}
+
+/*
+CloseSprint closes the active sprint. Its unfinished tasks carry over to the
+team's next planned sprint, or go back to the backlog when there is none.
+The response includes the closed sprint's report.
+*/
+func (h *SprintHandler) CloseSprint(w http.ResponseWriter, r *http.Request) {
+	sprint, ok := h.sprintFromRoute(w, r, true)
+	if !ok {
+		return
+	}
+
+	next_id, carried_over, err := h.store.CloseSprint(sprint.SprintID)
+	if err != nil {
+		if errors.Is(err, store.ErrSprintNotActive) {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	closed, err := h.store.GetSprintByID(sprint.SprintID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	result := models.SprintCloseResult{Sprint: *closed, CarriedOver: carried_over}
+	if next_id != 0 {
+		if result.NextSprint, err = h.store.GetSprintByID(next_id); err != nil {
+			logs.Log.Errorf("Error fetching sprint %d after carry-over: %v", next_id, err)
+		}
+	}
+	if result.Report, err = h.store.GetSprintReport(&result.Sprint); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Clients reload the boards of both sprints
+	h.broadcastSprint("SPRINT_CLOSED", &result.Sprint)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// GetSprintBoard returns the sprint's tasks by status
+func (h *SprintHandler) GetSprintBoard(w http.ResponseWriter, r *http.Request) {
+	sprint, ok := h.sprintFromRoute(w, r, false)
+	if !ok {
+		return
+	}
+
+	tasks, err := h.store.GetSprintTasks(sprint.SprintID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	board := models.SprintBoard{
+		Sprint: *sprint,
+		Columns: map[models.TaskStatus][]models.Task{
+			models.TaskStatusTodo:       {},
+			models.TaskStatusInProgress: {},
+			models.TaskStatusInReview:   {},
+			models.TaskStatusDone:       {},
+		},
+	}
+	for _, task := range tasks {
+		board.Columns[task.Status] = append(board.Columns[task.Status], task)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(board)
+}
+
+// GetSprintReport compares what the sprint committed to with what it completed
+func (h *SprintHandler) GetSprintReport(w http.ResponseWriter, r *http.Request) {
+	sprint, ok := h.sprintFromRoute(w, r, false)
+	if !ok {
+		return
+	}
+
+	report, err := h.store.GetSprintReport(sprint)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+/*
+SetTaskSprint moves the task in the route into the sprint given as
+sprint_id, or back to the backlog when sprint_id is 0. The team leader and
+the task's creator can plan it; the sprint must be of the task's team and
+not closed.
+*/
+func (h *SprintHandler) SetTaskSprint(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	task_id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid task_id", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		SprintID int `json:"sprint_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	task, err := h.store.GetTaskByTaskID(task_id)
+	if err != nil {
+		http.Error(w, "Not task found with given id", http.StatusNotFound)
+		return
+	}
+
+	team, err := h.store.GetTeamByID(task.TeamID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if team.TeamLeaderID != requester_id && task.CreatorID != requester_id {
+		http.Error(w, "Forbidden: only the team leader or the task's creator can plan it", http.StatusForbidden)
+		return
+	}
+
+	if req.SprintID != 0 {
+		sprint, err := h.store.GetSprintByID(req.SprintID)
+		if err != nil || sprint.TeamID != task.TeamID {
+			http.Error(w, "Sprint not found in the task's team", http.StatusNotFound)
+			return
+		}
+		if sprint.State == models.SprintClosed {
+			http.Error(w, "Tasks cannot be added to a closed sprint", http.StatusConflict)
+			return
+		}
+	}
+
+	previous, err := h.store.SetTaskSprint(task_id, req.SprintID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	change := map[string]int{
+		"task_id":        task_id,
+		"from_sprint_id": previous,
+		"sprint_id":      req.SprintID,
+	}
+	msg_bytes, _ := json.Marshal(Message{
+		Type: "TASK_SPRINT_CHANGED",
+		Data: change,
+	})
+	h.wsHub.BroadcastToTeam(task.TeamID, msg_bytes)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(change)
+}
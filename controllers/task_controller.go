@@ -14,12 +14,13 @@ import (
 )
 
 type TaskHandler struct {
-	store *store.Store
-	wsHub *ws.Hub
+	store   *store.Store
+	wsHub   *ws.Hub
+	copilot *Copilot
 }
 
-func NewTaskHandler(s *store.Store, wsHub *ws.Hub) *TaskHandler {
-	return &TaskHandler{store: s, wsHub: wsHub}
+func NewTaskHandler(s *store.Store, wsHub *ws.Hub, cp *Copilot) *TaskHandler {
+	return &TaskHandler{store: s, wsHub: wsHub, copilot: cp}
 }
 
 type Message struct {
@@ -70,8 +71,27 @@ func (t *TaskHandler) CreateTask(w http.ResponseWriter, r *http.Request) {
 
 	t.wsHub.BroadcastToTeam(task.TeamID, msgBytes)
 
+	recordActivity(t.store, t.wsHub, models.Activity{
+		TeamID:  task.TeamID,
+		ActorID: requester_id,
+		Type:    models.ActivityTaskCreated,
+		TaskID:  task.TaskID,
+		Data:    map[string]interface{}{"title": task.Title, "status": task.Status, "priority": task.Priority},
+	})
+
+	// Likely duplicates are reported alongside the new task; failing to
+	// look them up does not fail the creation
+	duplicates, err := t.copilot.findDuplicates(r.Context(), requester_id, &task)
+	if err != nil {
+		logs.Log.Errorf("Failed to check task %d for duplicates: %v", task.TaskID, err)
+		duplicates = []models.DuplicateCandidate{}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(task)
+	json.NewEncoder(w).Encode(struct {
+		models.Task
+		Duplicates []models.DuplicateCandidate `json:"duplicates"`
+	}{task, duplicates})
 }
 
 func (t *TaskHandler) GetTaskByTaskID(w http.ResponseWriter, r *http.Request) {
@@ -275,6 +295,8 @@ func (t *TaskHandler) UpdateTaskByID(w http.ResponseWriter, r *http.Request) {
 
 	t.wsHub.BroadcastToTeam(updated_task.TeamID, msg_bytes)
 
+	recordTaskChanges(t.store, t.wsHub, requester_id, "", task, &updated_task)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(updated_task)
 }
@@ -304,7 +326,7 @@ func (t *TaskHandler) DeleteTaskByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := t.store.DeleteTaskByID(task_id); err != nil {
+	if err := t.store.DeleteTaskByID(task_id, requester_id); err != nil {
 		http.Error(w, "Error deleting the task", http.StatusInternalServerError)
 		return
 	}
@@ -317,14 +339,92 @@ func (t *TaskHandler) DeleteTaskByID(w http.ResponseWriter, r *http.Request) {
 
 	t.wsHub.BroadcastToTeam(task.TeamID, msg_bytes)
 
+	recordActivity(t.store, t.wsHub, models.Activity{
+		TeamID:  task.TeamID,
+		ActorID: requester_id,
+		Type:    models.ActivityTaskDeleted,
+		TaskID:  task_id,
+		Data:    map[string]interface{}{"title": task.Title},
+	})
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
+/*
+EnhanceTask asks the copilot to improve a task's title, description, priority
+and status. With ?preview=true the suggestion and the resulting field changes
+are returned without saving, to be confirmed through ApplyEnhancement.
+*/
 func (t *TaskHandler) EnhanceTask(w http.ResponseWriter, r *http.Request) {
+	task, ok := t.copilotTask(w, r)
+	if !ok {
+		return
+	}
+
+	call, ok := t.copilot.start(w, task.CreatorID, task.TeamID, task.TaskID, models.CopilotFeatureEnhance)
+	if !ok {
+		return
+	}
+
+	enhancement, err := store.EnhanceTask(r.Context(), call.meter, task)
+	call.finish(err)
+	if err != nil {
+		copilotError(w, err)
+		return
+	}
+
+	if r.URL.Query().Get("preview") == "true" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(enhancement)
+		return
+	}
+
+	if !t.saveEnhancedTask(w, &enhancement.Task, enhancement.Changes) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(enhancement.Task)
+}
+
+// ApplyEnhancement saves a previewed suggestion. Fields left empty in the body are not changed.
+func (t *TaskHandler) ApplyEnhancement(w http.ResponseWriter, r *http.Request) {
+	var suggestion models.TaskSuggestion
+	if err := json.NewDecoder(r.Body).Decode(&suggestion); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := store.ValidateTaskSuggestion(&suggestion); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	task, ok := t.copilotTask(w, r)
+	if !ok {
+		return
+	}
+
+	merged, changes := store.MergeTaskSuggestion(task, &suggestion)
+	if len(changes) > 0 && !t.saveEnhancedTask(w, merged, changes) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.TaskEnhancement{
+		TaskID:     task.TaskID,
+		Suggestion: suggestion,
+		Changes:    changes,
+		Task:       *merged,
+	})
+}
+
+// copilotTask loads the task in the route and checks the requester created it
+func (t *TaskHandler) copilotTask(w http.ResponseWriter, r *http.Request) (*models.Task, bool) {
 	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
 	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
+		return nil, false
 	}
 
 	params := mux.Vars(r)
@@ -332,27 +432,41 @@ func (t *TaskHandler) EnhanceTask(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		http.Error(w, "Invalid task_id", http.StatusBadRequest)
-		return
+		return nil, false
 	}
 
 	task, err := t.store.GetTaskByTaskID(task_id)
+	if err != nil {
+		http.Error(w, "Not task found with given id", http.StatusNotFound)
+		return nil, false
+	}
+
 	if requester_id != task.CreatorID {
 		http.Error(w, "Only the creator can use copilot to enhance task", http.StatusUnauthorized)
-		return
+		return nil, false
 	}
+	return task, true
+}
 
-	enhanced_task, err := store.EnhanceTask(task)
-	if err != nil {
+func (t *TaskHandler) saveEnhancedTask(w http.ResponseWriter, task *models.Task, changes []models.TaskFieldChange) bool {
+	if err := t.store.UpdateTaskByID(task.TaskID, task); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return false
 	}
 
-	err = t.store.UpdateTaskByID(task_id, enhanced_task)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	msg := Message{
+		Type: "TASK_UPDATED",
+		Data: task,
 	}
+	msg_bytes, _ := json.Marshal(msg)
+	t.wsHub.BroadcastToTeam(task.TeamID, msg_bytes)
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(enhanced_task)
+	recordActivity(t.store, t.wsHub, models.Activity{
+		TeamID:  task.TeamID,
+		ActorID: task.CreatorID,
+		Type:    models.ActivityCopilotEnhanced,
+		TaskID:  task.TaskID,
+		Data:    map[string]interface{}{"title": task.Title, "changes": changes},
+	})
+	return true
 }
@@ -5,18 +5,21 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/drumilbhati/teamsync/logs"
 	"github.com/drumilbhati/teamsync/middleware"
 	"github.com/drumilbhati/teamsync/models"
 	"github.com/drumilbhati/teamsync/store"
+	"github.com/drumilbhati/teamsync/ws"
 	"github.com/gorilla/mux"
 )
 
 type TeamHandler struct {
 	store *store.Store
+	wsHub *ws.Hub
 }
 
-func NewTeamHandler(s *store.Store) *TeamHandler {
-	return &TeamHandler{store: s}
+func NewTeamHandler(s *store.Store, wsHub *ws.Hub) *TeamHandler {
+	return &TeamHandler{store: s, wsHub: wsHub}
 }
 
 func (h *TeamHandler) GetTeamByID(w http.ResponseWriter, r *http.Request) {
@@ -106,6 +109,12 @@ func (h *TeamHandler) CreateTeam(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	channelIDs, err := h.store.GetChannelIDsByTeamID(team.TeamID)
+	if err != nil {
+		logs.Log.Errorf("Error fetching channels for new team: %v", err)
+	}
+	h.wsHub.JoinTeam(team.TeamID, team.TeamLeaderID, channelIDs)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(&team)
 }
@@ -148,6 +157,53 @@ func (h *TeamHandler) UpdateTeamByID(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(&updated_team)
 }
 
+// UpdateTeamAISettings turns the team's AI copilot features on or off. Body: {"enabled": bool}
+func (h *TeamHandler) UpdateTeamAISettings(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	params := mux.Vars(r)
+
+	team_id, err := strconv.Atoi(params["id"])
+	if err != nil {
+		http.Error(w, "Invalid team_id", http.StatusBadRequest)
+		return
+	}
+
+	var settings struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil || settings.Enabled == nil {
+		http.Error(w, "enabled is required", http.StatusBadRequest)
+		return
+	}
+
+	team, err := h.store.GetTeamByID(team_id)
+	if err != nil {
+		http.Error(w, "Team not found", http.StatusNotFound)
+		return
+	}
+
+	if team.TeamLeaderID != requester_id {
+		http.Error(w, "Unauthorized: team leader can only update this team", http.StatusForbidden)
+		return
+	}
+
+	if err := h.store.SetTeamAIEnabled(team_id, *settings.Enabled); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"team_id":    team_id,
+		"ai_enabled": *settings.Enabled,
+	})
+}
+
 func (h *TeamHandler) DeleteTeamByID(w http.ResponseWriter, r *http.Request) {
 	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
 	if !ok {
@@ -174,10 +230,23 @@ func (h *TeamHandler) DeleteTeamByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.store.DeleteTeamByID(team_id); err != nil {
+	channelIDs, err := h.store.GetChannelIDsByTeamID(team_id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.store.DeleteTeamByID(team_id, requester_id); err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
+	msg_bytes, _ := json.Marshal(Message{
+		Type: "TEAM_DELETED",
+		Data: map[string]int{"team_id": team_id},
+	})
+	h.wsHub.BroadcastToTeam(team_id, msg_bytes)
+	h.wsHub.RemoveTeam(team_id, channelIDs)
+
 	w.WriteHeader(http.StatusNoContent)
 }
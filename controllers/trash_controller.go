@@ -0,0 +1,232 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/drumilbhati/teamsync/logs"
+	"github.com/drumilbhati/teamsync/middleware"
+	"github.com/drumilbhati/teamsync/models"
+	"github.com/drumilbhati/teamsync/store"
+	"github.com/drumilbhati/teamsync/worker"
+	"github.com/drumilbhati/teamsync/ws"
+	"github.com/gorilla/mux"
+)
+
+type TrashHandler struct {
+	store *store.Store
+	wsHub *ws.Hub
+}
+
+func NewTrashHandler(s *store.Store, wsHub *ws.Hub) *TrashHandler {
+	return &TrashHandler{store: s, wsHub: wsHub}
+}
+
+// withPurgeDates sets when each item will be purged from the trash
+func withPurgeDates(items []models.TrashItem) []models.TrashItem {
+	retention := worker.TrashRetention()
+	for i := range items {
+		items[i].PurgeAt = items[i].DeletedAt.Add(retention)
+	}
+	return items
+}
+
+// GetTeamTrash lists the team's deleted tasks and comments
+func (h *TrashHandler) GetTeamTrash(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	team_id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid team_id", http.StatusBadRequest)
+		return
+	}
+
+	isMember, err := h.store.IsTeamMember(requester_id, team_id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !isMember {
+		http.Error(w, "Forbidden: you are not a member of this team", http.StatusForbidden)
+		return
+	}
+
+	items, err := h.store.GetTeamTrash(team_id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(withPurgeDates(items))
+}
+
+/*
+RestoreTrashItem takes a deleted task or comment of the team out of the
+trash. The team leader and whoever deleted the item can restore it.
+*/
+func (h *TrashHandler) RestoreTrashItem(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	params := mux.Vars(r)
+	team_id, err := strconv.Atoi(params["id"])
+	if err != nil {
+		http.Error(w, "Invalid team_id", http.StatusBadRequest)
+		return
+	}
+	item_id, err := strconv.Atoi(params["item_id"])
+	if err != nil {
+		http.Error(w, "Invalid item_id", http.StatusBadRequest)
+		return
+	}
+
+	item_type := params["type"]
+	if item_type != models.TrashTypeTask && item_type != models.TrashTypeComment {
+		http.Error(w, "type must be task or comment", http.StatusBadRequest)
+		return
+	}
+
+	team, err := h.store.GetTeamByID(team_id)
+	if err != nil {
+		http.Error(w, "Team not found", http.StatusNotFound)
+		return
+	}
+
+	item, err := h.store.GetTrashItem(item_type, item_id)
+	if err != nil || item.TeamID != team_id {
+		http.Error(w, "Item not found in the trash", http.StatusNotFound)
+		return
+	}
+
+	if team.TeamLeaderID != requester_id && item.DeletedBy != requester_id {
+		http.Error(w, "Forbidden: only the team leader or whoever deleted it can restore this item", http.StatusForbidden)
+		return
+	}
+
+	if item_type == models.TrashTypeComment {
+		if _, err := h.store.GetTaskByTaskID(item.TaskID); err != nil {
+			http.Error(w, "The comment's task is in the trash; restore the task first", http.StatusConflict)
+			return
+		}
+	}
+
+	if err := h.store.RestoreTrashItem(item_type, item_id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Item not found in the trash", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if item_type == models.TrashTypeTask {
+		task, err := h.store.GetTaskByTaskID(item_id)
+		if err != nil {
+			logs.Log.Errorf("Error fetching restored task: %v", err)
+		} else {
+			msg_bytes, _ := json.Marshal(Message{
+				Type: "TASK_RESTORED",
+				Data: task,
+			})
+			h.wsHub.BroadcastToTeam(team_id, msg_bytes)
+
+			recordActivity(h.store, h.wsHub, models.Activity{
+				TeamID:  team_id,
+				ActorID: requester_id,
+				Type:    models.ActivityTaskRestored,
+				TaskID:  task.TaskID,
+				Data:    map[string]interface{}{"title": task.Title},
+			})
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetDeletedTeams lists the teams the requester leads that are in the trash
+func (h *TrashHandler) GetDeletedTeams(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	items, err := h.store.GetDeletedTeamsByLeaderID(requester_id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(withPurgeDates(items))
+}
+
+// RestoreTeam takes a deleted team out of the trash with everything it held
+func (h *TrashHandler) RestoreTeam(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	team_id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid team_id", http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := h.store.GetDeletedTeamsByLeaderID(requester_id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	found := false
+	for _, item := range deleted {
+		found = found || item.ID == team_id
+	}
+	if !found {
+		http.Error(w, "Team not found in your trash", http.StatusNotFound)
+		return
+	}
+
+	if err := h.store.RestoreTrashItem(models.TrashTypeTeam, team_id); err != nil {
+		http.Error(w, "Team not found in your trash", http.StatusNotFound)
+		return
+	}
+
+	team, err := h.store.GetTeamByID(team_id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Connected members get the team back without reconnecting
+	for _, m := range team.Members {
+		channelIDs, err := h.store.GetTeamChannelIDsForUser(team_id, m.UserID)
+		if err != nil {
+			logs.Log.Errorf("Error fetching channels for restored member: %v", err)
+			continue
+		}
+		h.wsHub.JoinTeam(team_id, m.UserID, channelIDs)
+	}
+
+	msg_bytes, _ := json.Marshal(Message{
+		Type: "TEAM_RESTORED",
+		Data: team,
+	})
+	h.wsHub.BroadcastToTeam(team_id, msg_bytes)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(team)
+}
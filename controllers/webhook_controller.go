@@ -0,0 +1,352 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"github.com/drumilbhati/teamsync/middleware"
+	"github.com/drumilbhati/teamsync/models"
+	"github.com/drumilbhati/teamsync/store"
+	"github.com/drumilbhati/teamsync/worker"
+	"github.com/gorilla/mux"
+)
+
+const (
+	minWebhookSecretLength    = 16
+	defaultWebhookDeliveries  = 50
+	maxWebhookDeliveriesLimit = 200
+)
+
+type WebhookHandler struct {
+	store      *store.Store
+	dispatcher *worker.WebhookDispatcher
+}
+
+func NewWebhookHandler(s *store.Store, d *worker.WebhookDispatcher) *WebhookHandler {
+	return &WebhookHandler{store: s, dispatcher: d}
+}
+
+type webhookRequest struct {
+	URL    *string   `json:"url"`
+	Secret *string   `json:"secret"`
+	Events *[]string `json:"events"`
+	Active *bool     `json:"active"`
+}
+
+// apply validates the fields present in the request and copies them onto wh
+func (req *webhookRequest) apply(ctx context.Context, wh *models.Webhook) error {
+	if req.URL != nil {
+		u, err := url.Parse(*req.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("url must be an absolute http or https URL")
+		}
+		if err := worker.CheckWebhookURL(ctx, u.String()); err != nil {
+			return err
+		}
+		wh.URL = u.String()
+	}
+
+	if req.Secret != nil {
+		if len(*req.Secret) < minWebhookSecretLength {
+			return errors.New("secret must be at least 16 characters")
+		}
+		wh.Secret = *req.Secret
+	}
+
+	if req.Events != nil {
+		events := []string{}
+		for _, e := range *req.Events {
+			if !slices.Contains(models.WebhookEvents, e) {
+				return errors.New("unknown event: " + e)
+			}
+			if !slices.Contains(events, e) {
+				events = append(events, e)
+			}
+		}
+		wh.Events = events
+	}
+
+	if req.Active != nil {
+		wh.Active = *req.Active
+	}
+	return nil
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+/*
+CreateWebhook subscribes a URL to the team's events. Without a secret one is
+generated; the secret is only ever returned in this response.
+*/
+func (h *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	team_id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid team_id", http.StatusBadRequest)
+		return
+	}
+
+	var req webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.URL == nil {
+		http.Error(w, "Invalid request body: url is required", http.StatusBadRequest)
+		return
+	}
+
+	if !requireTeamLeader(w, h.store, requester_id, team_id) {
+		return
+	}
+
+	webhook := models.Webhook{
+		TeamID:    team_id,
+		CreatedBy: requester_id,
+		Events:    []string{},
+		Active:    true,
+	}
+	if err := req.apply(r.Context(), &webhook); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if webhook.Secret == "" {
+		if webhook.Secret, err = newWebhookSecret(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := h.store.CreateWebhook(&webhook); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(webhook)
+}
+
+func (h *WebhookHandler) GetWebhooksByTeamID(w http.ResponseWriter, r *http.Request) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	team_id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid team_id", http.StatusBadRequest)
+		return
+	}
+
+	if !requireTeamLeader(w, h.store, requester_id, team_id) {
+		return
+	}
+
+	webhooks, err := h.store.GetWebhooksByTeamID(team_id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(webhooks)
+}
+
+// UpdateWebhookByID changes the fields present in the body; a new secret is not echoed back
+func (h *WebhookHandler) UpdateWebhookByID(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := h.leaderWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	var req webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := req.apply(r.Context(), webhook); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.store.UpdateWebhookByID(webhook.WebhookID, webhook); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	webhook.Secret = ""
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(webhook)
+}
+
+func (h *WebhookHandler) DeleteWebhookByID(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := h.leaderWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.store.DeleteWebhookByID(webhook.WebhookID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TestWebhook queues a PING event to the webhook, whatever events it subscribes to
+func (h *WebhookHandler) TestWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := h.leaderWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	payload, err := worker.NewWebhookEventPayload(models.WebhookEventPing, webhook.TeamID, map[string]int{
+		"webhook_id": webhook.WebhookID,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	delivery, err := h.dispatcher.Send(webhook.WebhookID, models.WebhookEventPing, payload)
+	if err != nil {
+		http.Error(w, "Failed to queue test delivery: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(delivery)
+}
+
+// GetWebhookDeliveries lists the webhook's deliveries, newest first (optional status and limit)
+func (h *WebhookHandler) GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := h.leaderWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	status := query.Get("status")
+	switch status {
+	case "", models.WebhookDeliveryPending, models.WebhookDeliveryRetrying,
+		models.WebhookDeliveryDelivered, models.WebhookDeliveryDead:
+	default:
+		http.Error(w, "Invalid status", http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultWebhookDeliveries
+	if v := query.Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		if limit > maxWebhookDeliveriesLimit {
+			limit = maxWebhookDeliveriesLimit
+		}
+	}
+
+	deliveries, err := h.store.GetWebhookDeliveries(webhook.WebhookID, status, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+// ReplayWebhookDelivery sends a recorded payload again as a new delivery, e.g. after it went dead
+func (h *WebhookHandler) ReplayWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := h.leaderWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	delivery_id, err := strconv.Atoi(mux.Vars(r)["delivery_id"])
+	if err != nil {
+		http.Error(w, "Invalid delivery_id", http.StatusBadRequest)
+		return
+	}
+
+	delivery, err := h.store.GetWebhookDeliveryByID(delivery_id)
+	if err != nil || delivery.WebhookID != webhook.WebhookID {
+		http.Error(w, "Delivery not found", http.StatusNotFound)
+		return
+	}
+
+	replay, err := h.dispatcher.Send(webhook.WebhookID, delivery.Event, delivery.Payload)
+	if err != nil {
+		http.Error(w, "Failed to queue replay: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(replay)
+}
+
+// leaderWebhook loads the webhook in the route and checks the requester leads its team
+func (h *WebhookHandler) leaderWebhook(w http.ResponseWriter, r *http.Request) (*models.Webhook, bool) {
+	requester_id, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	webhook_id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid webhook_id", http.StatusBadRequest)
+		return nil, false
+	}
+
+	webhook, err := h.store.GetWebhookByID(webhook_id)
+	if err != nil {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	if !requireTeamLeader(w, h.store, requester_id, webhook.TeamID) {
+		return nil, false
+	}
+	return webhook, true
+}
+
+// requireTeamLeader rejects the request unless the requester leads the team
+func requireTeamLeader(w http.ResponseWriter, s *store.Store, requesterID, teamID int) bool {
+	team, err := s.GetTeamByID(teamID)
+	if err != nil {
+		http.Error(w, "Team not found", http.StatusNotFound)
+		return false
+	}
+
+	if team.TeamLeaderID != requesterID {
+		http.Error(w, "Forbidden: only the team leader can manage integrations of this team", http.StatusForbidden)
+		return false
+	}
+	return true
+}
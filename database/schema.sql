@@ -85,3 +85,353 @@ CREATE TABLE IF NOT EXISTS messages (
     content TEXT NOT NULL,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 );
+
+-- Conversations Table (direct messages and private group chats)
+CREATE TABLE IF NOT EXISTS conversations (
+    conversation_id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    is_group BOOLEAN NOT NULL DEFAULT FALSE,
+    created_by INTEGER REFERENCES users(user_id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Conversation Participants Table
+CREATE TABLE IF NOT EXISTS conversation_participants (
+    conversation_id INTEGER REFERENCES conversations(conversation_id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(user_id) ON DELETE CASCADE,
+    joined_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (conversation_id, user_id)
+);
+
+-- Conversation Messages Table
+CREATE TABLE IF NOT EXISTS conversation_messages (
+    message_id SERIAL PRIMARY KEY,
+    conversation_id INTEGER REFERENCES conversations(conversation_id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(user_id) ON DELETE CASCADE,
+    user_name VARCHAR(255) NOT NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_conversation_messages_conversation_id ON conversation_messages(conversation_id, created_at);
+
+-- Channels Table (named chat channels inside a team)
+CREATE TABLE IF NOT EXISTS channels (
+    channel_id SERIAL PRIMARY KEY,
+    team_id INTEGER REFERENCES teams(team_id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    is_private BOOLEAN NOT NULL DEFAULT FALSE,
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    created_by INTEGER REFERENCES users(user_id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(team_id, name)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_channels_default ON channels(team_id) WHERE is_default;
+
+-- Channel Members Table (explicit membership for private channels)
+CREATE TABLE IF NOT EXISTS channel_members (
+    channel_id INTEGER REFERENCES channels(channel_id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(user_id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (channel_id, user_id)
+);
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS channel_id INTEGER REFERENCES channels(channel_id) ON DELETE CASCADE;
+
+-- Every team gets a default "general" channel holding its existing messages
+INSERT INTO channels (team_id, name, is_default, created_by)
+SELECT t.team_id, 'general', TRUE, t.team_leader_id
+FROM teams t
+WHERE NOT EXISTS (SELECT 1 FROM channels c WHERE c.team_id = t.team_id AND c.is_default);
+
+UPDATE messages m
+SET channel_id = c.channel_id
+FROM channels c
+WHERE m.channel_id IS NULL AND c.team_id = m.team_id AND c.is_default;
+
+CREATE INDEX IF NOT EXISTS idx_messages_channel_id ON messages(channel_id, created_at);
+
+-- Full-text search vectors for chat messages, comments and tasks
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (to_tsvector('english', content)) STORED;
+
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (to_tsvector('english', content)) STORED;
+
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+        setweight(to_tsvector('english', coalesce(description, '')), 'B')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_messages_search ON messages USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_comments_search ON comments USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_tasks_search ON tasks USING GIN (search_vector);
+
+-- Attachments Table (files uploaded to tasks, comments and chat messages)
+CREATE TABLE IF NOT EXISTS attachments (
+    attachment_id SERIAL PRIMARY KEY,
+    team_id INTEGER REFERENCES teams(team_id) ON DELETE CASCADE,
+    task_id INTEGER REFERENCES tasks(task_id) ON DELETE CASCADE,
+    comment_id INTEGER REFERENCES comments(comment_id) ON DELETE CASCADE,
+    message_id INTEGER REFERENCES messages(message_id) ON DELETE CASCADE,
+    uploader_id INTEGER REFERENCES users(user_id) ON DELETE SET NULL,
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    storage_key VARCHAR(512) NOT NULL,
+    thumbnail_key VARCHAR(512),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (num_nonnulls(task_id, comment_id, message_id) = 1)
+);
+
+CREATE INDEX IF NOT EXISTS idx_attachments_team_id ON attachments(team_id);
+
+-- Copilot: per-team switch and audit log of calls to the language model
+ALTER TABLE teams ADD COLUMN IF NOT EXISTS ai_enabled BOOLEAN NOT NULL DEFAULT TRUE;
+
+CREATE TABLE IF NOT EXISTS copilot_calls (
+    call_id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(user_id) ON DELETE SET NULL,
+    team_id INTEGER REFERENCES teams(team_id) ON DELETE SET NULL,
+    task_id INTEGER REFERENCES tasks(task_id) ON DELETE SET NULL,
+    feature VARCHAR(50) NOT NULL,
+    model VARCHAR(255) NOT NULL,
+    prompt_tokens INTEGER NOT NULL DEFAULT 0,
+    completion_tokens INTEGER NOT NULL DEFAULT 0,
+    latency_ms INTEGER NOT NULL DEFAULT 0,
+    outcome VARCHAR(20) NOT NULL,
+    error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_copilot_calls_created_at ON copilot_calls(created_at);
+CREATE INDEX IF NOT EXISTS idx_copilot_calls_team_id ON copilot_calls(team_id, created_at);
+
+-- Trigram similarity for duplicate task detection
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_tasks_title_trgm ON tasks USING GIN (title gin_trgm_ops);
+
+-- Outbound webhooks: per-team subscriptions to team events
+CREATE TABLE IF NOT EXISTS webhooks (
+    webhook_id SERIAL PRIMARY KEY,
+    team_id INTEGER REFERENCES teams(team_id) ON DELETE CASCADE,
+    created_by INTEGER REFERENCES users(user_id) ON DELETE SET NULL,
+    url TEXT NOT NULL,
+    secret VARCHAR(255) NOT NULL,
+    -- Event types to deliver; empty means every event
+    events TEXT[] NOT NULL DEFAULT '{}',
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhooks_team_id ON webhooks(team_id);
+
+-- Every attempt to deliver an event to a webhook; payload is kept verbatim so it can be replayed
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    delivery_id SERIAL PRIMARY KEY,
+    webhook_id INTEGER REFERENCES webhooks(webhook_id) ON DELETE CASCADE,
+    event VARCHAR(64) NOT NULL,
+    payload TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'retrying', 'delivered', 'dead')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    response_status INTEGER,
+    last_error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON webhook_deliveries(webhook_id, created_at DESC);
+
+-- Inbound Git hosting webhooks (GitHub or GitLab), one secret per team integration
+CREATE TABLE IF NOT EXISTS git_integrations (
+    integration_id SERIAL PRIMARY KEY,
+    team_id INTEGER REFERENCES teams(team_id) ON DELETE CASCADE,
+    provider VARCHAR(20) NOT NULL CHECK (provider IN ('github', 'gitlab')),
+    secret VARCHAR(255) NOT NULL,
+    -- Move referenced tasks to in_review when a pull request opens and to done when it merges
+    auto_transition BOOLEAN NOT NULL DEFAULT FALSE,
+    created_by INTEGER REFERENCES users(user_id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_git_integrations_team_id ON git_integrations(team_id);
+
+-- Commits and pull requests that reference a task
+CREATE TABLE IF NOT EXISTS task_links (
+    link_id SERIAL PRIMARY KEY,
+    task_id INTEGER REFERENCES tasks(task_id) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('commit', 'pull_request')),
+    provider VARCHAR(20) NOT NULL,
+    repository VARCHAR(255) NOT NULL,
+    -- Commit SHA or pull request number
+    external_id VARCHAR(255) NOT NULL,
+    url TEXT,
+    title TEXT,
+    author VARCHAR(255),
+    state VARCHAR(20),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (task_id, kind, repository, external_id)
+);
+
+-- Read-only iCalendar feeds of task due dates, addressed by a secret token
+CREATE TABLE IF NOT EXISTS calendar_feeds (
+    feed_id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(user_id) ON DELETE CASCADE,
+    -- NULL for the user's assigned tasks across all their teams
+    team_id INTEGER REFERENCES teams(team_id) ON DELETE CASCADE,
+    -- SHA-256 of the token; the token itself is only shown when the feed is created
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_calendar_feeds_user_id ON calendar_feeds(user_id);
+
+-- Background imports of Trello and Jira export files
+CREATE TABLE IF NOT EXISTS import_jobs (
+    job_id SERIAL PRIMARY KEY,
+    team_id INTEGER REFERENCES teams(team_id) ON DELETE CASCADE,
+    requested_by INTEGER REFERENCES users(user_id) ON DELETE SET NULL,
+    source VARCHAR(20) NOT NULL CHECK (source IN ('trello', 'jira')),
+    -- The uploaded file, removed from storage once the job has run
+    storage_key VARCHAR(512) NOT NULL,
+    user_map JSONB NOT NULL DEFAULT '{}',
+    dry_run BOOLEAN NOT NULL DEFAULT FALSE,
+    status VARCHAR(20) NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'running', 'completed', 'failed')),
+    total INTEGER NOT NULL DEFAULT 0,
+    processed INTEGER NOT NULL DEFAULT 0,
+    report JSONB,
+    error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_import_jobs_team_id ON import_jobs(team_id);
+
+-- Team backup archives, kept after the team is deleted so it can be restored
+CREATE TABLE IF NOT EXISTS team_backups (
+    backup_id SERIAL PRIMARY KEY,
+    team_id INTEGER REFERENCES teams(team_id) ON DELETE SET NULL,
+    team_name VARCHAR(255) NOT NULL,
+    -- The team's leader when the backup was taken, who may always download and restore it
+    leader_id INTEGER REFERENCES users(user_id) ON DELETE CASCADE,
+    requested_by INTEGER REFERENCES users(user_id) ON DELETE SET NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'running', 'completed', 'failed')),
+    storage_key VARCHAR(512),
+    size_bytes BIGINT NOT NULL DEFAULT 0,
+    error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_team_backups_team_id ON team_backups(team_id);
+CREATE INDEX IF NOT EXISTS idx_team_backups_leader_id ON team_backups(leader_id);
+
+-- Soft deletion: deleted teams, tasks and comments stay in the trash until
+-- they are restored or purged after the retention period
+ALTER TABLE teams ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE teams ADD COLUMN IF NOT EXISTS deleted_by INTEGER REFERENCES users(user_id) ON DELETE SET NULL;
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS deleted_by INTEGER REFERENCES users(user_id) ON DELETE SET NULL;
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS deleted_by INTEGER REFERENCES users(user_id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_teams_deleted_at ON teams(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_tasks_deleted_at ON tasks(team_id, deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_comments_deleted_at ON comments(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Team activity feed, written as things happen and kept after the tasks it mentions are gone
+CREATE TABLE IF NOT EXISTS activity_events (
+    activity_id BIGSERIAL PRIMARY KEY,
+    team_id INTEGER REFERENCES teams(team_id) ON DELETE CASCADE,
+    -- NULL for events without a user, e.g. tasks moved by a Git integration
+    actor_id INTEGER REFERENCES users(user_id) ON DELETE SET NULL,
+    actor_name VARCHAR(255) NOT NULL DEFAULT '',
+    type VARCHAR(50) NOT NULL,
+    task_id INTEGER,
+    data JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_activity_events_team_id ON activity_events(team_id, activity_id DESC);
+
+-- Every status a task enters, for team analytics. The trigger records the
+-- changes made by any code path, including imports and restores.
+CREATE TABLE IF NOT EXISTS task_status_history (
+    history_id BIGSERIAL PRIMARY KEY,
+    task_id INTEGER REFERENCES tasks(task_id) ON DELETE CASCADE,
+    status VARCHAR(50) NOT NULL,
+    entered_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_status_history_task_id ON task_status_history(task_id, entered_at);
+CREATE INDEX IF NOT EXISTS idx_task_status_history_status ON task_status_history(status, entered_at);
+
+CREATE OR REPLACE FUNCTION record_task_status()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND NEW.status IS NOT DISTINCT FROM OLD.status THEN
+        RETURN NEW;
+    END IF;
+
+    INSERT INTO task_status_history (task_id, status, entered_at)
+    VALUES (
+        NEW.task_id,
+        NEW.status,
+        CASE WHEN TG_OP = 'INSERT' THEN COALESCE(NEW.created_at, CURRENT_TIMESTAMP) ELSE CURRENT_TIMESTAMP END
+    );
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS tasks_status_history ON tasks;
+CREATE TRIGGER tasks_status_history
+    AFTER INSERT OR UPDATE OF status ON tasks
+    FOR EACH ROW EXECUTE FUNCTION record_task_status();
+
+-- Tasks that predate the history are taken to have entered their current
+-- status when they were last updated
+INSERT INTO task_status_history (task_id, status, entered_at)
+SELECT t.task_id, t.status, COALESCE(t.updated_at, t.created_at, CURRENT_TIMESTAMP)
+FROM tasks t
+WHERE NOT EXISTS (SELECT 1 FROM task_status_history h WHERE h.task_id = t.task_id);
+
+-- Sprints: time boxes of a team's work. A team runs at most one sprint at a time.
+CREATE TABLE IF NOT EXISTS sprints (
+    sprint_id SERIAL PRIMARY KEY,
+    team_id INTEGER REFERENCES teams(team_id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    goal TEXT NOT NULL DEFAULT '',
+    start_date DATE NOT NULL,
+    end_date DATE NOT NULL,
+    state VARCHAR(20) NOT NULL DEFAULT 'planned' CHECK (state IN ('planned', 'active', 'closed')),
+    created_by INTEGER REFERENCES users(user_id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP WITH TIME ZONE,
+    closed_at TIMESTAMP WITH TIME ZONE,
+    CHECK (end_date >= start_date)
+);
+
+CREATE INDEX IF NOT EXISTS idx_sprints_team_id ON sprints(team_id, start_date);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sprints_one_active ON sprints(team_id) WHERE state = 'active';
+
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS sprint_id INTEGER REFERENCES sprints(sprint_id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_tasks_sprint_id ON tasks(sprint_id) WHERE sprint_id IS NOT NULL;
+
+-- Every task that was part of a sprint, for the sprint report. committed is
+-- set for the tasks in the sprint when it started and final_status when it
+-- closed; removed_at marks tasks taken out or carried over to the next sprint.
+CREATE TABLE IF NOT EXISTS sprint_tasks (
+    sprint_id INTEGER REFERENCES sprints(sprint_id) ON DELETE CASCADE,
+    task_id INTEGER REFERENCES tasks(task_id) ON DELETE CASCADE,
+    committed BOOLEAN NOT NULL DEFAULT FALSE,
+    added_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    removed_at TIMESTAMP WITH TIME ZONE,
+    carried_over BOOLEAN NOT NULL DEFAULT FALSE,
+    final_status VARCHAR(50),
+    PRIMARY KEY (sprint_id, task_id)
+);
@@ -0,0 +1,150 @@
+// Package importer converts Trello and Jira export files into tasks the
+// store can import.
+package importer
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/drumilbhati/teamsync/models"
+)
+
+const (
+	SourceTrello = "trello"
+	SourceJira   = "jira"
+)
+
+// Result is the parsed content of an export file
+type Result struct {
+	Tasks []models.ImportTask
+
+	// Skipped counts archived cards and other items that are not imported
+	Skipped  int
+	Warnings []string
+}
+
+/*
+UserMap resolves users of the source system to emails. Keys are matched
+case-insensitively against whatever the export identifies users by:
+Trello usernames, ids or full names, Jira account ids or display names.
+Values that already look like an email are used as they are.
+*/
+type UserMap map[string]string
+
+func (m UserMap) email(ids ...string) string {
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if strings.Contains(id, "@") {
+			return id
+		}
+		for k, v := range m {
+			if strings.EqualFold(k, id) {
+				return v
+			}
+		}
+	}
+	return ""
+}
+
+// statusNames lists the names mapped to each task status. Names match whole
+// words, and todo names are checked first so that "Not started" and "Selected
+// for Development" are not read as work in progress.
+var statusNames = []struct {
+	status models.TaskStatus
+	names  []string
+}{
+	{models.TaskStatusTodo, []string{"to do", "todo", "not started", "backlog", "selected for development", "ready for development"}},
+	{models.TaskStatusDone, []string{"done", "complete", "completed", "finished", "closed", "resolved", "shipped", "released"}},
+	{models.TaskStatusInReview, []string{"review", "reviewing", "qa", "test", "testing", "verify", "verification"}},
+	{models.TaskStatusInProgress, []string{"progress", "doing", "wip", "started", "development", "active"}},
+}
+
+/*
+MapStatus maps a Trello list or Jira status name to a task status by
+keyword, e.g. "Code Review" to in_review and "Doing" to in_progress.
+Keywords match whole words, so "Inactive" and "Latest" are not mistaken for
+"active" and "test". Names that match nothing are todo.
+*/
+func MapStatus(name string) models.TaskStatus {
+	words := nameWords(name)
+	for _, s := range statusNames {
+		for _, n := range s.names {
+			if containsWords(words, nameWords(n)) {
+				return s.status
+			}
+		}
+	}
+	return models.TaskStatusTodo
+}
+
+// nameWords splits a name into lower case words, dropping punctuation
+func nameWords(name string) []string {
+	return strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// containsWords reports whether phrase appears in words as consecutive words
+func containsWords(words, phrase []string) bool {
+	for i := 0; i+len(phrase) <= len(words); i++ {
+		if slices.Equal(words[i:i+len(phrase)], phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+// MapPriority maps Jira priorities and priority-like labels; ok is false for anything else
+func MapPriority(name string) (models.TaskPriority, bool) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "highest", "high", "critical", "blocker", "urgent", "major", "p0", "p1":
+		return models.TaskPriorityHigh, true
+	case "medium", "normal", "p2":
+		return models.TaskPriorityMedium, true
+	case "low", "lowest", "minor", "trivial", "p3", "p4":
+		return models.TaskPriorityLow, true
+	}
+	return "", false
+}
+
+// applyLabels turns priority-like labels into the task priority and lists the
+// others in the description, since tasks have no labels of their own
+func applyLabels(t *models.ImportTask, labels []string) {
+	var rest []string
+	for _, label := range labels {
+		label = strings.TrimSpace(label)
+		if label == "" {
+			continue
+		}
+		if p, ok := MapPriority(label); ok && t.Priority == "" {
+			t.Priority = string(p)
+			continue
+		}
+		rest = append(rest, label)
+	}
+	if len(rest) > 0 {
+		appendDescription(t, "Labels: "+strings.Join(rest, ", "))
+	}
+}
+
+func appendDescription(t *models.ImportTask, text string) {
+	if t.Description == "" {
+		t.Description = text
+		return
+	}
+	t.Description += "\n\n" + text
+}
+
+// truncateTitle keeps titles within the tasks.title column
+func truncateTitle(title string) string {
+	title = strings.TrimSpace(title)
+	runes := []rune(title)
+	if len(runes) > 255 {
+		return string(runes[:254]) + "…"
+	}
+	return title
+}
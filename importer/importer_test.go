@@ -0,0 +1,70 @@
+package importer
+
+import (
+	"testing"
+
+	"github.com/drumilbhati/teamsync/models"
+)
+
+func TestMapStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		want models.TaskStatus
+	}{
+		{"To Do", models.TaskStatusTodo},
+		{"Backlog", models.TaskStatusTodo},
+		{"Not started", models.TaskStatusTodo},
+		{"Selected for Development", models.TaskStatusTodo},
+		{"Ready for development", models.TaskStatusTodo},
+		{"Inactive", models.TaskStatusTodo},
+		{"Latest", models.TaskStatusTodo},
+		{"Ideas", models.TaskStatusTodo},
+		{"", models.TaskStatusTodo},
+		{"Doing", models.TaskStatusInProgress},
+		{"In Progress", models.TaskStatusInProgress},
+		{"WIP", models.TaskStatusInProgress},
+		{"In development", models.TaskStatusInProgress},
+		{"Active", models.TaskStatusInProgress},
+		{"Started", models.TaskStatusInProgress},
+		{"Code Review", models.TaskStatusInReview},
+		{"QA", models.TaskStatusInReview},
+		{"In testing", models.TaskStatusInReview},
+		{"Ready to test", models.TaskStatusInReview},
+		{"Done", models.TaskStatusDone},
+		{"Done!", models.TaskStatusDone},
+		{"Completed", models.TaskStatusDone},
+		{"Closed", models.TaskStatusDone},
+		{"Released to production", models.TaskStatusDone},
+	}
+
+	for _, tt := range tests {
+		if got := MapStatus(tt.name); got != tt.want {
+			t.Errorf("MapStatus(%q) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMapPriority(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   models.TaskPriority
+		wantOk bool
+	}{
+		{"Highest", models.TaskPriorityHigh, true},
+		{"Blocker", models.TaskPriorityHigh, true},
+		{" P1 ", models.TaskPriorityHigh, true},
+		{"Medium", models.TaskPriorityMedium, true},
+		{"normal", models.TaskPriorityMedium, true},
+		{"Low", models.TaskPriorityLow, true},
+		{"Trivial", models.TaskPriorityLow, true},
+		{"frontend", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := MapPriority(tt.name)
+		if got != tt.want || ok != tt.wantOk {
+			t.Errorf("MapPriority(%q) = (%s, %v), want (%s, %v)", tt.name, got, ok, tt.want, tt.wantOk)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/drumilbhati/teamsync/models"
+)
+
+// Date formats found in Jira CSV exports, which follow the exporting user's settings
+var jiraTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"02/Jan/06 3:04 PM",
+	"02/Jan/06 15:04",
+	"02/Jan/06",
+	"2/Jan/06 3:04 PM",
+	"2/Jan/06",
+}
+
+func parseJiraTime(v string) (time.Time, bool) {
+	v = strings.TrimSpace(v)
+	for _, layout := range jiraTimeLayouts {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+/*
+jiraStatus maps an issue's status, preferring its status category (To Do, In
+Progress or Done) when the export has one. Jira has no review category, so
+in progress issues whose status names a review stay in review.
+*/
+func jiraStatus(status, category string) models.TaskStatus {
+	switch strings.ToLower(strings.TrimSpace(category)) {
+	case "to do", "new":
+		return models.TaskStatusTodo
+	case "in progress", "indeterminate":
+		if MapStatus(status) == models.TaskStatusInReview {
+			return models.TaskStatusInReview
+		}
+		return models.TaskStatusInProgress
+	case "done", "complete":
+		return models.TaskStatusDone
+	}
+	return MapStatus(status)
+}
+
+/*
+ParseJira reads a Jira issue CSV export ("Export > CSV (all fields)").
+Statuses are mapped by their Status Category when exported, otherwise by
+name, priorities by name, and repeated Labels and Comment columns are all
+read. Jira comments have the form "date;author;text".
+Assignees and authors are matched by email when the export contains one,
+otherwise through userMap by account id or display name.
+*/
+func ParseJira(data []byte, userMap UserMap) (*Result, error) {
+	cr := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("invalid Jira export: missing header row")
+	}
+
+	// Jira repeats columns such as Labels and Comment once per value
+	columns := map[string][]int{}
+	for i, name := range header {
+		key := strings.ToLower(strings.TrimSpace(name))
+		columns[key] = append(columns[key], i)
+	}
+	if _, ok := columns["summary"]; !ok {
+		return nil, fmt.Errorf("invalid Jira export: a Summary column is required")
+	}
+
+	result := &Result{}
+	unmatched := map[string]bool{}
+	person := func(ids ...string) string {
+		email := userMap.email(ids...)
+		if email == "" && strings.TrimSpace(ids[0]) != "" {
+			unmatched[strings.TrimSpace(ids[0])] = true
+		}
+		return email
+	}
+
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid Jira export: %v", err)
+		}
+
+		values := func(names ...string) []string {
+			var out []string
+			for _, name := range names {
+				for _, i := range columns[name] {
+					if i < len(record) && strings.TrimSpace(record[i]) != "" {
+						out = append(out, strings.TrimSpace(record[i]))
+					}
+				}
+			}
+			return out
+		}
+		first := func(names ...string) string {
+			if v := values(names...); len(v) > 0 {
+				return v[0]
+			}
+			return ""
+		}
+
+		summary := first("summary")
+		if summary == "" {
+			continue
+		}
+
+		t := models.ImportTask{
+			Row:         line,
+			Title:       truncateTitle(summary),
+			Description: first("description"),
+			Status:      string(jiraStatus(first("status"), first("status category"))),
+		}
+
+		if p, ok := MapPriority(first("priority")); ok {
+			t.Priority = string(p)
+		}
+
+		if assignee := first("assignee"); assignee != "" {
+			t.AssigneeEmail = person(assignee, first("assignee id"))
+		}
+
+		if v := first("due date", "due"); v != "" {
+			if due, ok := parseJiraTime(v); ok {
+				t.DueDate = due.Format("2006-01-02")
+			} else {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("row %d: unrecognised due date %q was left out", line, v))
+			}
+		}
+
+		for _, v := range values("comment") {
+			parts := strings.SplitN(v, ";", 3)
+			if len(parts) != 3 {
+				t.Comments = append(t.Comments, models.ImportComment{Content: v})
+				continue
+			}
+			c := models.ImportComment{
+				AuthorEmail: person(parts[1]),
+				AuthorName:  parts[1],
+				Content:     parts[2],
+			}
+			if created, ok := parseJiraTime(parts[0]); ok {
+				c.CreatedAt = created.Format(time.RFC3339)
+			}
+			t.Comments = append(t.Comments, c)
+		}
+
+		applyLabels(&t, values("labels"))
+
+		if key := first("issue key"); key != "" {
+			appendDescription(&t, "Imported from Jira: "+key)
+		}
+		result.Tasks = append(result.Tasks, t)
+	}
+
+	for id := range unmatched {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("Jira user %s has no email in the user map", id))
+	}
+	sort.Strings(result.Warnings)
+	return result, nil
+}
@@ -0,0 +1,134 @@
+package importer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/drumilbhati/teamsync/models"
+)
+
+func TestParseJira(t *testing.T) {
+	csv := strings.Join([]string{
+		"Summary,Issue key,Status,Status Category,Priority,Assignee,Due Date,Labels,Labels,Comment,Comment,Description",
+		`Fix login,TS-1,Selected for Development,To Do,High,alice@example.com,2024-03-05,backend,P2,"05/Mar/24 3:04 PM;bob;Looks good","06/Mar/24 14:30;Carol;Shipped?",Steps`,
+		`Review docs,TS-2,Peer Review,In Progress,Lowest,Bob,05/Mar/24 3:04 PM,,,,,`,
+		`Release,TS-3,Released,Done,,,5/Mar/24,ops,urgent,plain comment,,`,
+		`No category,TS-4,Not started,,Medium,,someday,,,,,`,
+		`,TS-5,Done,Done,,,,,,,,`,
+	}, "\n")
+
+	result, err := ParseJira([]byte("\xef\xbb\xbf"+csv), UserMap{"bob": "bob@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []models.ImportTask{
+		{
+			Row:           2,
+			Title:         "Fix login",
+			Description:   "Steps\n\nLabels: backend, P2\n\nImported from Jira: TS-1",
+			Status:        string(models.TaskStatusTodo),
+			Priority:      string(models.TaskPriorityHigh),
+			AssigneeEmail: "alice@example.com",
+			DueDate:       "2024-03-05",
+			Comments: []models.ImportComment{
+				{AuthorEmail: "bob@example.com", AuthorName: "bob", Content: "Looks good", CreatedAt: "2024-03-05T15:04:00Z"},
+				{AuthorName: "Carol", Content: "Shipped?", CreatedAt: "2024-03-06T14:30:00Z"},
+			},
+		},
+		{
+			Row:           3,
+			Title:         "Review docs",
+			Description:   "Imported from Jira: TS-2",
+			Status:        string(models.TaskStatusInReview),
+			Priority:      string(models.TaskPriorityLow),
+			AssigneeEmail: "bob@example.com",
+			DueDate:       "2024-03-05",
+		},
+		{
+			Row:         4,
+			Title:       "Release",
+			Description: "Labels: ops\n\nImported from Jira: TS-3",
+			Status:      string(models.TaskStatusDone),
+			Priority:    string(models.TaskPriorityHigh),
+			DueDate:     "2024-03-05",
+			Comments:    []models.ImportComment{{Content: "plain comment"}},
+		},
+		{
+			Row:         5,
+			Title:       "No category",
+			Description: "Imported from Jira: TS-4",
+			Status:      string(models.TaskStatusTodo),
+			Priority:    string(models.TaskPriorityMedium),
+		},
+	}
+	if !reflect.DeepEqual(result.Tasks, want) {
+		t.Errorf("tasks:\n got %+v\nwant %+v", result.Tasks, want)
+	}
+
+	wantWarnings := []string{
+		"Jira user Carol has no email in the user map",
+		`row 5: unrecognised due date "someday" was left out`,
+	}
+	if !reflect.DeepEqual(result.Warnings, wantWarnings) {
+		t.Errorf("warnings = %q, want %q", result.Warnings, wantWarnings)
+	}
+}
+
+func TestParseJiraDateLayouts(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"2024-03-05T10:00:00Z", "2024-03-05"},
+		{"2024-03-05 10:00", "2024-03-05"},
+		{"2024-03-05", "2024-03-05"},
+		{"05/Mar/24 10:00 AM", "2024-03-05"},
+		{"05/Mar/24 22:00", "2024-03-05"},
+		{"05/Mar/24", "2024-03-05"},
+		{"5/Mar/24 10:00 PM", "2024-03-05"},
+		{"5/Mar/24", "2024-03-05"},
+	}
+
+	for _, tt := range tests {
+		result, err := ParseJira([]byte("Summary,Due Date\nTask,"+tt.in), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := result.Tasks[0].DueDate; got != tt.want {
+			t.Errorf("due date %q parsed as %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestJiraStatus(t *testing.T) {
+	tests := []struct {
+		status   string
+		category string
+		want     models.TaskStatus
+	}{
+		{"Selected for Development", "To Do", models.TaskStatusTodo},
+		{"Testing backlog", "To Do", models.TaskStatusTodo},
+		{"Building", "In Progress", models.TaskStatusInProgress},
+		{"Not started", "In Progress", models.TaskStatusInProgress},
+		{"Code Review", "In Progress", models.TaskStatusInReview},
+		{"Won't Do", "Done", models.TaskStatusDone},
+		{"Code Review", "", models.TaskStatusInReview},
+		{"Inactive", "", models.TaskStatusTodo},
+	}
+
+	for _, tt := range tests {
+		if got := jiraStatus(tt.status, tt.category); got != tt.want {
+			t.Errorf("jiraStatus(%q, %q) = %s, want %s", tt.status, tt.category, got, tt.want)
+		}
+	}
+}
+
+func TestParseJiraRequiresSummary(t *testing.T) {
+	for _, data := range []string{"", "Issue key,Status\nTS-1,Done"} {
+		if _, err := ParseJira([]byte(data), nil); err == nil {
+			t.Errorf("ParseJira(%q) succeeded, want an error", data)
+		}
+	}
+}
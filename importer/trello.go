@@ -0,0 +1,154 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/drumilbhati/teamsync/models"
+)
+
+type trelloBoard struct {
+	Name  string `json:"name"`
+	Lists []struct {
+		ID     string `json:"id"`
+		Name   string `json:"name"`
+		Closed bool   `json:"closed"`
+	} `json:"lists"`
+	Cards []struct {
+		ID          string   `json:"id"`
+		Name        string   `json:"name"`
+		Desc        string   `json:"desc"`
+		IDList      string   `json:"idList"`
+		Closed      bool     `json:"closed"`
+		Due         *string  `json:"due"`
+		DueComplete bool     `json:"dueComplete"`
+		ShortURL    string   `json:"shortUrl"`
+		IDMembers   []string `json:"idMembers"`
+		Labels      []struct {
+			Name  string `json:"name"`
+			Color string `json:"color"`
+		} `json:"labels"`
+	} `json:"cards"`
+	Members []struct {
+		ID       string `json:"id"`
+		FullName string `json:"fullName"`
+		Username string `json:"username"`
+		Email    string `json:"email"`
+	} `json:"members"`
+	Actions []struct {
+		Type string    `json:"type"`
+		Date time.Time `json:"date"`
+		Data struct {
+			Text string `json:"text"`
+			Card struct {
+				ID string `json:"id"`
+			} `json:"card"`
+		} `json:"data"`
+		MemberCreator struct {
+			ID       string `json:"id"`
+			FullName string `json:"fullName"`
+			Username string `json:"username"`
+		} `json:"memberCreator"`
+	} `json:"actions"`
+}
+
+/*
+ParseTrello reads a Trello board JSON export. The list a card is in decides
+its status (a completed due date means done), the first member becomes the
+assignee and card comments are kept. Archived cards and cards in archived
+lists are skipped. Trello exports carry no emails, so members are resolved
+through userMap by username, id or full name.
+*/
+func ParseTrello(data []byte, userMap UserMap) (*Result, error) {
+	var board trelloBoard
+	if err := json.Unmarshal(data, &board); err != nil {
+		return nil, fmt.Errorf("invalid Trello export: %v", err)
+	}
+	if len(board.Lists) == 0 && len(board.Cards) == 0 {
+		return nil, fmt.Errorf("invalid Trello export: no lists or cards found")
+	}
+
+	lists := map[string]string{}
+	closedLists := map[string]bool{}
+	for _, l := range board.Lists {
+		lists[l.ID] = l.Name
+		closedLists[l.ID] = l.Closed
+	}
+
+	result := &Result{}
+	unmatched := map[string]bool{}
+	memberEmail := map[string]string{}
+	for _, m := range board.Members {
+		email := m.Email
+		if email == "" {
+			email = userMap.email(m.Username, m.ID, m.FullName)
+		}
+		if email == "" {
+			unmatched[m.Username] = true
+		}
+		memberEmail[m.ID] = email
+	}
+
+	comments := map[string][]models.ImportComment{}
+	// Actions are newest first; comments are imported oldest first
+	for i := len(board.Actions) - 1; i >= 0; i-- {
+		a := board.Actions[i]
+		if a.Type != "commentCard" {
+			continue
+		}
+		author := a.MemberCreator
+		comments[a.Data.Card.ID] = append(comments[a.Data.Card.ID], models.ImportComment{
+			AuthorEmail: userMap.email(author.Username, author.ID, author.FullName),
+			AuthorName:  author.FullName,
+			Content:     a.Data.Text,
+			CreatedAt:   a.Date.Format(time.RFC3339),
+		})
+	}
+
+	for i, c := range board.Cards {
+		if c.Closed || closedLists[c.IDList] {
+			result.Skipped++
+			continue
+		}
+
+		t := models.ImportTask{
+			Row:         i + 1,
+			Title:       truncateTitle(c.Name),
+			Description: c.Desc,
+			Status:      string(MapStatus(lists[c.IDList])),
+			Comments:    comments[c.ID],
+		}
+		if c.DueComplete {
+			t.Status = string(models.TaskStatusDone)
+		}
+		if c.Due != nil {
+			t.DueDate = *c.Due
+		}
+		if len(c.IDMembers) > 0 {
+			t.AssigneeEmail = memberEmail[c.IDMembers[0]]
+		}
+
+		labels := make([]string, 0, len(c.Labels))
+		for _, l := range c.Labels {
+			if l.Name != "" {
+				labels = append(labels, l.Name)
+			} else {
+				labels = append(labels, l.Color)
+			}
+		}
+		applyLabels(&t, labels)
+
+		if c.ShortURL != "" {
+			appendDescription(&t, "Imported from Trello: "+c.ShortURL)
+		}
+		result.Tasks = append(result.Tasks, t)
+	}
+
+	for username := range unmatched {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("Trello member %s has no email in the user map", username))
+	}
+	sort.Strings(result.Warnings)
+	return result, nil
+}
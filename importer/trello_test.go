@@ -0,0 +1,88 @@
+package importer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/drumilbhati/teamsync/models"
+)
+
+const trelloExport = `{
+	"name": "Board",
+	"lists": [
+		{"id": "l1", "name": "Not started"},
+		{"id": "l2", "name": "Doing"},
+		{"id": "l3", "name": "Old", "closed": true}
+	],
+	"cards": [
+		{
+			"id": "c1", "name": "Write docs", "desc": "Usage", "idList": "l1",
+			"due": "2024-03-05T12:00:00.000Z", "shortUrl": "https://trello.com/c/abc",
+			"idMembers": ["m1", "m2"],
+			"labels": [{"name": "High"}, {"name": "docs"}, {"name": "", "color": "green"}]
+		},
+		{"id": "c2", "name": "Build API", "idList": "l2", "idMembers": ["m2"]},
+		{"id": "c3", "name": "Ship it", "idList": "l2", "dueComplete": true},
+		{"id": "c4", "name": "Archived card", "idList": "l1", "closed": true},
+		{"id": "c5", "name": "Card in archived list", "idList": "l3"}
+	],
+	"members": [
+		{"id": "m1", "username": "alice", "fullName": "Alice"},
+		{"id": "m2", "username": "bob", "fullName": "Bob"}
+	],
+	"actions": [
+		{"type": "commentCard", "date": "2024-03-02T10:00:00Z", "data": {"text": "second", "card": {"id": "c1"}}, "memberCreator": {"id": "m2", "username": "bob", "fullName": "Bob"}},
+		{"type": "updateCard", "date": "2024-03-01T11:00:00Z", "data": {"card": {"id": "c1"}}, "memberCreator": {"id": "m1"}},
+		{"type": "commentCard", "date": "2024-03-01T10:00:00Z", "data": {"text": "first", "card": {"id": "c1"}}, "memberCreator": {"id": "m1", "username": "alice", "fullName": "Alice"}}
+	]
+}`
+
+func TestParseTrello(t *testing.T) {
+	result, err := ParseTrello([]byte(trelloExport), UserMap{"ALICE": "alice@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []models.ImportTask{
+		{
+			Row:           1,
+			Title:         "Write docs",
+			Description:   "Usage\n\nLabels: docs, green\n\nImported from Trello: https://trello.com/c/abc",
+			Status:        string(models.TaskStatusTodo),
+			Priority:      string(models.TaskPriorityHigh),
+			AssigneeEmail: "alice@example.com",
+			DueDate:       "2024-03-05T12:00:00.000Z",
+			Comments: []models.ImportComment{
+				{AuthorEmail: "alice@example.com", AuthorName: "Alice", Content: "first", CreatedAt: "2024-03-01T10:00:00Z"},
+				{AuthorName: "Bob", Content: "second", CreatedAt: "2024-03-02T10:00:00Z"},
+			},
+		},
+		{
+			Row:    2,
+			Title:  "Build API",
+			Status: string(models.TaskStatusInProgress),
+		},
+		{
+			Row:    3,
+			Title:  "Ship it",
+			Status: string(models.TaskStatusDone),
+		},
+	}
+	if !reflect.DeepEqual(result.Tasks, want) {
+		t.Errorf("tasks:\n got %+v\nwant %+v", result.Tasks, want)
+	}
+	if result.Skipped != 2 {
+		t.Errorf("skipped %d cards, want 2", result.Skipped)
+	}
+	if wantWarnings := []string{"Trello member bob has no email in the user map"}; !reflect.DeepEqual(result.Warnings, wantWarnings) {
+		t.Errorf("warnings = %q, want %q", result.Warnings, wantWarnings)
+	}
+}
+
+func TestParseTrelloInvalidExport(t *testing.T) {
+	for _, data := range []string{"", "not json", `{"name": "Empty board"}`} {
+		if _, err := ParseTrello([]byte(data), nil); err == nil {
+			t.Errorf("ParseTrello(%q) succeeded, want an error", data)
+		}
+	}
+}
@@ -13,11 +13,13 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/drumilbhati/teamsync/ai"
 	"github.com/drumilbhati/teamsync/controllers"
 	"github.com/drumilbhati/teamsync/database"
 	"github.com/drumilbhati/teamsync/logs"
 	"github.com/drumilbhati/teamsync/middleware"
 	"github.com/drumilbhati/teamsync/models"
+	"github.com/drumilbhati/teamsync/storage"
 	"github.com/drumilbhati/teamsync/store"
 	"github.com/drumilbhati/teamsync/worker"
 	"github.com/drumilbhati/teamsync/ws"
@@ -35,11 +37,13 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-func wsHandler(hub *ws.Hub, s *store.Store, w http.ResponseWriter, r *http.Request) {
+func wsHandler(hub *ws.Hub, s *store.Store, conv *controllers.ConversationHandler, w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		return
 	}
+	defer conn.Close()
+
 	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
 
 	if !ok {
@@ -63,19 +67,30 @@ func wsHandler(hub *ws.Hub, s *store.Store, w http.ResponseWriter, r *http.Reque
 		teamIDs = append(teamIDs, team.TeamID)
 	}
 
-	hub.AddUser(conn, teamIDs)
+	channelIDs, err := s.GetChannelIDsByUserID(userID)
+	if err != nil {
+		logs.Log.Error("Error fetching channels")
+		return
+	}
 
-	defer hub.RemoveUser(conn, teamIDs)
+	client := ws.NewClient(conn, userID)
+	hub.Register(client, teamIDs, channelIDs)
+
+	defer hub.Unregister(client)
+
+	go client.WritePump()
 
 	type Message struct {
-		TeamID   int    `json:"team_id"`
-		Content  string `json:"content"`
-		UserID   int    `json:"user_id"`
-		UserName string `json:"user_name"`
+		TeamID         int    `json:"team_id,omitempty"`
+		ChannelID      int    `json:"channel_id,omitempty"`
+		ConversationID int    `json:"conversation_id,omitempty"`
+		Content        string `json:"content"`
+		UserID         int    `json:"user_id"`
+		UserName       string `json:"user_name"`
 	}
 
 	for {
-		_, message, err := conn.ReadMessage()
+		message, err := client.ReadMessage()
 		if err != nil {
 			break
 		}
@@ -86,37 +101,87 @@ func wsHandler(hub *ws.Hub, s *store.Store, w http.ResponseWriter, r *http.Reque
 			continue
 		}
 
-		// Verify the user is actually part of the team they are trying to message
-		isMember := false
-		for _, tid := range teamIDs {
-			if tid == msg.TeamID {
-				isMember = true
-				break
+		// Direct and group conversation messages are delivered to participants only
+		if msg.ConversationID != 0 {
+			isParticipant, err := s.IsConversationParticipant(userID, msg.ConversationID)
+			if err != nil {
+				logs.Log.Errorf("Error checking conversation participant: %v", err)
+				continue
+			}
+			if !isParticipant || strings.TrimSpace(msg.Content) == "" {
+				continue
+			}
+
+			dbMsg := models.ConversationMessage{
+				ConversationID: msg.ConversationID,
+				UserID:         userID,
+				UserName:       user.UserName,
+				Content:        msg.Content,
+			}
+			if err := conv.Deliver(&dbMsg); err != nil {
+				logs.Log.Errorf("Error delivering conversation message: %v", err)
 			}
+			continue
 		}
 
-		if isMember {
-			msg.UserID = userID
-			msg.UserName = user.UserName
+		if msg.ChannelID != 0 {
+			// Verify the user can read the channel they are trying to message
+			canAccess, err := s.CanAccessChannel(userID, msg.ChannelID)
+			if err != nil {
+				logs.Log.Errorf("Error checking channel access: %v", err)
+				continue
+			}
+			if !canAccess {
+				continue
+			}
 
-			// Save to database
-			dbMsg := models.Message{
-				TeamID:   msg.TeamID,
-				UserID:   msg.UserID,
-				UserName: msg.UserName,
-				Content:  msg.Content,
+			channel, err := s.GetChannelByID(msg.ChannelID)
+			if err != nil {
+				logs.Log.Errorf("Error fetching channel: %v", err)
+				continue
+			}
+			msg.TeamID = channel.TeamID
+		} else {
+			// Verify the user is currently part of the team they are trying to message
+			isMember, err := s.IsTeamMember(userID, msg.TeamID)
+			if err != nil {
+				logs.Log.Errorf("Error checking team membership: %v", err)
+				continue
 			}
-			if err := s.CreateMessage(&dbMsg); err != nil {
-				logs.Log.Errorf("Error saving message: %v", err)
+			if !isMember {
+				continue
 			}
 
-			updatedMessage, err := json.Marshal(msg)
+			// Messages without a channel go to the team's default channel
+			channelID, err := s.GetDefaultChannelID(msg.TeamID)
 			if err != nil {
-				logs.Log.Errorf("Error marshalling message: %v", err)
+				logs.Log.Errorf("Error fetching default channel: %v", err)
 				continue
 			}
-			hub.BroadcastToTeam(msg.TeamID, updatedMessage)
+			msg.ChannelID = channelID
+		}
+
+		msg.UserID = userID
+		msg.UserName = user.UserName
+
+		// Save to database
+		dbMsg := models.Message{
+			TeamID:    msg.TeamID,
+			ChannelID: msg.ChannelID,
+			UserID:    msg.UserID,
+			UserName:  msg.UserName,
+			Content:   msg.Content,
+		}
+		if err := s.CreateMessage(&dbMsg); err != nil {
+			logs.Log.Errorf("Error saving message: %v", err)
+		}
+
+		updatedMessage, err := json.Marshal(msg)
+		if err != nil {
+			logs.Log.Errorf("Error marshalling message: %v", err)
+			continue
 		}
+		hub.BroadcastToChannel(msg.ChannelID, updatedMessage)
 	}
 }
 
@@ -183,6 +248,20 @@ func main() {
 		logs.Log.Fatal("Failed to connect to redis: ", err)
 	}
 
+	s := store.NewStore(db, rdb)
+
+	// Attachment files live on local disk or an S3-compatible bucket
+	fileStorage, err := storage.NewFromEnv()
+	if err != nil {
+		logs.Log.Fatal("Failed to configure file storage: ", err)
+	}
+
+	// Language model behind the task copilot
+	aiProvider, err := ai.NewFromEnv()
+	if err != nil {
+		logs.Log.Fatal("Failed to configure AI provider: ", err)
+	}
+
 	redisAddr := os.Getenv("REDIS_ADDR")
 	redisOpt := asynq.RedisClientOpt{Addr: redisAddr}
 
@@ -190,16 +269,34 @@ func main() {
 	client := asynq.NewClient(redisOpt)
 	defer client.Close()
 
+	// Websocket events are fanned out over Redis so every replica reaches its own connections
+	wsHub, err := ws.NewHubWithBroker(ws.NewRedisBroker(rdb, "teamsync:ws"))
+	if err != nil {
+		logs.Log.Fatal("Failed to subscribe to websocket broker: ", err)
+	}
+	defer wsHub.Close()
+
+	// Every team event broadcast over the websocket is also offered to the team's webhooks
+	webhooks := worker.NewWebhookDispatcher(s, client)
+	wsHub.OnTeamBroadcast(webhooks.OnTeamBroadcast)
+
 	// Create and start sever for consumer/worker
 	srv := asynq.NewServer(
 		redisOpt,
 		asynq.Config{
-			Concurrency: 10,
+			Concurrency:    10,
+			RetryDelayFunc: worker.RetryDelay,
 		},
 	)
 
 	muxServer := asynq.NewServeMux()
 	muxServer.HandleFunc(worker.TypeEmailDelivery, worker.HandleEmailDeliveryTask)
+	muxServer.Handle(worker.TypeAttachmentThumbnail, worker.NewThumbnailProcessor(s, fileStorage))
+	muxServer.Handle(worker.TypeCopilotSummary, worker.NewSummaryProcessor(s, aiProvider))
+	muxServer.Handle(worker.TypeWebhookDelivery, worker.NewWebhookProcessor(s))
+	muxServer.Handle(worker.TypeExternalImport, worker.NewImportProcessor(s, fileStorage, wsHub))
+	muxServer.Handle(worker.TypeTeamBackup, worker.NewBackupProcessor(s, fileStorage))
+	muxServer.Handle(worker.TypeTrashPurge, worker.NewTrashPurgeProcessor(s, fileStorage))
 
 	// Run worker in background
 	go func() {
@@ -208,21 +305,47 @@ func main() {
 		}
 	}()
 
-	s := store.NewStore(db, rdb)
+	// Periodic jobs
+	scheduler := asynq.NewScheduler(redisOpt, nil)
+	purgeTask, _ := worker.NewTrashPurgeTask()
+	if _, err := scheduler.Register(worker.TrashPurgeSchedule, purgeTask); err != nil {
+		logs.Log.Fatalf("could not schedule trash purge: %v", err)
+	}
+	if err := scheduler.Start(); err != nil {
+		logs.Log.Fatalf("could not start scheduler: %v", err)
+	}
 
 	defer database.Close(db)
 	defer database.CloseRedis(rdb)
 
 	r := mux.NewRouter()
 	handler := rateLimitMiddleware(r, rate.Limit(2), 10)
-	wsHub := ws.NewHub()
+
+	copilot := controllers.NewCopilot(s, aiProvider)
 
 	u := controllers.NewUserHandler(s, client)
-	t := controllers.NewTeamHandler(s)
-	m := controllers.NewMemberHandler(s)
-	k := controllers.NewTaskHandler(s, wsHub)
-	c := controllers.NewCommentHandler(s)
+	t := controllers.NewTeamHandler(s, wsHub)
+	m := controllers.NewMemberHandler(s, wsHub)
+	k := controllers.NewTaskHandler(s, wsHub, copilot)
+	c := controllers.NewCommentHandler(s, wsHub)
 	msgCtrl := controllers.NewMessageHandler(s)
+	conv := controllers.NewConversationHandler(s, wsHub)
+	ch := controllers.NewChannelHandler(s, wsHub)
+	sh := controllers.NewSearchHandler(s)
+	att := controllers.NewAttachmentHandler(s, fileStorage, client)
+	cp := controllers.NewCopilotHandler(s, wsHub, copilot, client)
+	cu := controllers.NewCopilotUsageHandler(s)
+	wh := controllers.NewWebhookHandler(s, webhooks)
+	gh := controllers.NewGitHandler(s, wsHub)
+	cal := controllers.NewCalendarHandler(s)
+	ex := controllers.NewExportHandler(s, wsHub)
+	im := controllers.NewImportHandler(s, fileStorage, client)
+	bk := controllers.NewBackupHandler(s, fileStorage, client, wsHub)
+	tr := controllers.NewTrashHandler(s, wsHub)
+	act := controllers.NewActivityHandler(s)
+	an := controllers.NewAnalyticsHandler(s)
+	mw := controllers.NewMyWorkHandler(s)
+	sp := controllers.NewSprintHandler(s, wsHub)
 
 	// Define routes
 	// --- Public Auth Routes (changed prefix to /auth) ---
@@ -234,6 +357,12 @@ func main() {
 		w.Write([]byte("OK"))
 	}).Methods("GET")
 
+	// --- Inbound Git hosting webhooks, authenticated by the integration's secret ---
+	r.HandleFunc("/hooks/git/{id}", gh.ReceiveGitWebhook).Methods("POST")
+
+	// --- Calendar feeds, authenticated by the token in the URL ---
+	r.HandleFunc("/calendar/{token:[A-Za-z0-9_-]+}.ics", cal.ServeCalendarFeed).Methods("GET")
+
 	// --- Protected API Routes ---
 	// Create a subrouter that uses auth middleware
 	api := r.PathPrefix("/api").Subrouter()
@@ -241,7 +370,7 @@ func main() {
 
 	// Websocket routes
 	api.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
-		wsHandler(wsHub, s, w, r)
+		wsHandler(wsHub, s, conv, w, r)
 	})
 
 	// User routes
@@ -257,6 +386,7 @@ func main() {
 	api.HandleFunc("/teams", t.CreateTeam).Methods("POST")
 	api.HandleFunc("/teams/{id}", t.UpdateTeamByID).Methods("PUT")
 	api.HandleFunc("/teams/{id}", t.DeleteTeamByID).Methods("DELETE")
+	api.HandleFunc("/teams/{id}/ai", t.UpdateTeamAISettings).Methods("PUT")
 
 	// Member routes
 	api.HandleFunc("/members/{id}", m.GetMemberByID).Methods("GET")
@@ -274,6 +404,18 @@ func main() {
 	api.HandleFunc("/tasks/{id}", k.UpdateTaskByID).Methods("PUT")
 	api.HandleFunc("/tasks/{id}", k.DeleteTaskByID).Methods("DELETE")
 	api.HandleFunc("/tasks/enhance/{id}", k.EnhanceTask).Methods("POST")
+	api.HandleFunc("/tasks/enhance/{id}/apply", k.ApplyEnhancement).Methods("POST")
+	api.HandleFunc("/tasks/describe", cp.Describe).Methods("POST")
+	api.HandleFunc("/tasks/plan", cp.PlanTasks).Methods("POST")
+	api.HandleFunc("/tasks/plan/apply", cp.ApplyPlan).Methods("POST")
+	api.HandleFunc("/tasks/query", cp.QueryTasks).Methods("POST")
+	api.HandleFunc("/tasks/{id}/summary", cp.SummarizeTask).Methods("GET")
+	api.HandleFunc("/tasks/{id}/duplicates", k.GetDuplicates).Methods("GET")
+	api.HandleFunc("/tasks/{id}/merge", k.MergeTasks).Methods("POST")
+	api.HandleFunc("/teams/{id}/chat/summary", cp.SummarizeChat).Methods("GET")
+
+	// Admin routes
+	api.HandleFunc("/admin/copilot/usage", cu.GetCopilotUsage).Methods("GET")
 
 	// Comment routes
 	api.HandleFunc("/comments", c.CreateComment).Methods("POST")
@@ -284,6 +426,95 @@ func main() {
 	// Message routes
 	api.HandleFunc("/messages", msgCtrl.GetMessagesByTeamID).Methods("GET").Queries("team_id", "{id}")
 
+	// Channel routes
+	api.HandleFunc("/channels", ch.GetChannelsByTeamID).Methods("GET").Queries("team_id", "{id}")
+	api.HandleFunc("/channels", ch.CreateChannel).Methods("POST")
+	api.HandleFunc("/channels/{id}", ch.GetChannelByID).Methods("GET")
+	api.HandleFunc("/channels/{id}", ch.UpdateChannelByID).Methods("PUT")
+	api.HandleFunc("/channels/{id}", ch.DeleteChannelByID).Methods("DELETE")
+	api.HandleFunc("/channels/{id}/messages", ch.GetChannelMessages).Methods("GET")
+	api.HandleFunc("/channels/{id}/members", ch.AddChannelMember).Methods("POST")
+	api.HandleFunc("/channels/{id}/members/{user_id}", ch.RemoveChannelMember).Methods("DELETE")
+
+	// Conversation routes (direct messages and private groups)
+	api.HandleFunc("/conversations", conv.GetConversations).Methods("GET")
+	api.HandleFunc("/conversations", conv.CreateConversation).Methods("POST")
+	api.HandleFunc("/conversations/{id}", conv.GetConversationByID).Methods("GET")
+	api.HandleFunc("/conversations/{id}/messages", conv.GetConversationMessages).Methods("GET")
+	api.HandleFunc("/conversations/{id}/messages", conv.CreateConversationMessage).Methods("POST")
+	api.HandleFunc("/conversations/{id}/participants", conv.AddParticipant).Methods("POST")
+	api.HandleFunc("/conversations/{id}/participants/{user_id}", conv.RemoveParticipant).Methods("DELETE")
+
+	// Search routes
+	api.HandleFunc("/search", sh.Search).Methods("GET")
+
+	// Attachment routes
+	api.HandleFunc("/attachments", att.UploadAttachment).Methods("POST")
+	api.HandleFunc("/attachments", att.GetAttachments).Methods("GET")
+	api.HandleFunc("/attachments/{id}", att.DeleteAttachmentByID).Methods("DELETE")
+	api.HandleFunc("/attachments/{id}/download", att.DownloadAttachment).Methods("GET")
+	api.HandleFunc("/attachments/{id}/thumbnail", att.DownloadThumbnail).Methods("GET")
+	api.HandleFunc("/teams/{id}/attachments/usage", att.GetAttachmentUsage).Methods("GET")
+
+	// Webhook routes
+	api.HandleFunc("/teams/{id}/webhooks", wh.CreateWebhook).Methods("POST")
+	api.HandleFunc("/teams/{id}/webhooks", wh.GetWebhooksByTeamID).Methods("GET")
+	api.HandleFunc("/webhooks/{id}", wh.UpdateWebhookByID).Methods("PUT")
+	api.HandleFunc("/webhooks/{id}", wh.DeleteWebhookByID).Methods("DELETE")
+	api.HandleFunc("/webhooks/{id}/test", wh.TestWebhook).Methods("POST")
+	api.HandleFunc("/webhooks/{id}/deliveries", wh.GetWebhookDeliveries).Methods("GET")
+	api.HandleFunc("/webhooks/{id}/deliveries/{delivery_id}/replay", wh.ReplayWebhookDelivery).Methods("POST")
+
+	// Git integration routes
+	api.HandleFunc("/teams/{id}/integrations/git", gh.CreateGitIntegration).Methods("POST")
+	api.HandleFunc("/teams/{id}/integrations/git", gh.GetGitIntegrations).Methods("GET")
+	api.HandleFunc("/integrations/git/{id}", gh.DeleteGitIntegration).Methods("DELETE")
+	api.HandleFunc("/tasks/{id}/links", gh.GetTaskLinks).Methods("GET")
+
+	// Calendar feed routes
+	api.HandleFunc("/calendar/feeds", cal.CreateCalendarFeed).Methods("POST")
+	api.HandleFunc("/calendar/feeds", cal.GetCalendarFeeds).Methods("GET")
+	api.HandleFunc("/calendar/feeds/{id}", cal.DeleteCalendarFeed).Methods("DELETE")
+
+	// Export and import routes
+	api.HandleFunc("/teams/{id}/export", ex.ExportTeam).Methods("GET")
+	api.HandleFunc("/teams/{id}/import", ex.ImportTasks).Methods("POST")
+	api.HandleFunc("/teams/{id}/import/{source}", im.ImportExternal).Methods("POST")
+	api.HandleFunc("/import-jobs/{id}", im.GetImportJob).Methods("GET")
+
+	// Backup routes
+	api.HandleFunc("/teams/{id}/backups", bk.CreateBackup).Methods("POST")
+	api.HandleFunc("/backups", bk.GetBackups).Methods("GET")
+	api.HandleFunc("/backups/{id}", bk.GetBackupByID).Methods("GET")
+	api.HandleFunc("/backups/{id}/download", bk.DownloadBackup).Methods("GET")
+	api.HandleFunc("/backups/{id}/restore", bk.RestoreBackup).Methods("POST")
+
+	// Trash routes
+	api.HandleFunc("/teams/{id}/trash", tr.GetTeamTrash).Methods("GET")
+	api.HandleFunc("/teams/{id}/trash/{type}/{item_id}/restore", tr.RestoreTrashItem).Methods("POST")
+	api.HandleFunc("/trash/teams", tr.GetDeletedTeams).Methods("GET")
+	api.HandleFunc("/trash/teams/{id}/restore", tr.RestoreTeam).Methods("POST")
+
+	// Activity feed routes
+	api.HandleFunc("/teams/{id}/activity", act.GetTeamActivity).Methods("GET")
+
+	// Analytics routes
+	api.HandleFunc("/teams/{id}/analytics", an.GetTeamAnalytics).Methods("GET")
+
+	// Personal dashboard routes
+	api.HandleFunc("/my-work", mw.GetMyWork).Methods("GET")
+
+	// Sprint routes
+	api.HandleFunc("/teams/{id}/sprints", sp.CreateSprint).Methods("POST")
+	api.HandleFunc("/teams/{id}/sprints", sp.GetSprintsByTeamID).Methods("GET")
+	api.HandleFunc("/sprints/{id}", sp.GetSprintByID).Methods("GET")
+	api.HandleFunc("/sprints/{id}", sp.UpdateSprint).Methods("PUT")
+	api.HandleFunc("/sprints/{id}/start", sp.StartSprint).Methods("POST")
+	api.HandleFunc("/sprints/{id}/close", sp.CloseSprint).Methods("POST")
+	api.HandleFunc("/sprints/{id}/board", sp.GetSprintBoard).Methods("GET")
+	api.HandleFunc("/sprints/{id}/report", sp.GetSprintReport).Methods("GET")
+	api.HandleFunc("/tasks/{id}/sprint", sp.SetTaskSprint).Methods("PUT")
+
 	// --- Start Server ---
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -321,6 +552,7 @@ func main() {
 	}
 
 	// Shutdown Asynq worker
+	scheduler.Shutdown()
 	srv.Shutdown()
 
 	logs.Log.Info("Server gracefully stopped")
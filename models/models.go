@@ -32,6 +32,7 @@ type Team struct {
 	TeamName       string    `json:"team_name"`
 	TeamLeaderID   int       `json:"team_leader_id"`
 	TeamLeaderName string    `json:"team_leader_name,omitempty"`
+	AIEnabled      bool      `json:"ai_enabled"`
 	Members        []Member  `json:"members"`
 	CreatedAt      time.Time `json:"created_at"`
 }
@@ -96,8 +97,805 @@ type Comment struct {
 type Message struct {
 	MessageID int       `json:"message_id"`
 	TeamID    int       `json:"team_id"`
+	ChannelID int       `json:"channel_id"`
 	UserID    int       `json:"user_id"`
 	UserName  string    `json:"user_name"`
 	Content   string    `json:"content"`
 	CreatedAt time.Time `json:"created_at"`
 }
+
+type Channel struct {
+	ChannelID int       `json:"channel_id"`
+	TeamID    int       `json:"team_id"`
+	Name      string    `json:"name"`
+	IsPrivate bool      `json:"is_private"`
+	IsDefault bool      `json:"is_default"`
+	CreatedBy int       `json:"created_by"`
+	MemberIDs []int     `json:"member_ids,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ConversationParticipant struct {
+	UserID   int       `json:"user_id"`
+	UserName string    `json:"user_name"`
+	Email    string    `json:"email,omitempty"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+type Conversation struct {
+	ConversationID int                       `json:"conversation_id"`
+	Name           string                    `json:"name,omitempty"`
+	IsGroup        bool                      `json:"is_group"`
+	CreatedBy      int                       `json:"created_by"`
+	Participants   []ConversationParticipant `json:"participants"`
+	CreatedAt      time.Time                 `json:"created_at"`
+}
+
+type ConversationMessage struct {
+	MessageID      int       `json:"message_id"`
+	ConversationID int       `json:"conversation_id"`
+	UserID         int       `json:"user_id"`
+	UserName       string    `json:"user_name"`
+	Content        string    `json:"content"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+const (
+	SearchTypeMessage = "message"
+	SearchTypeComment = "comment"
+	SearchTypeTask    = "task"
+)
+
+type SearchFilter struct {
+	Query    string
+	TeamIDs  []int
+	Types    []string
+	AuthorID int
+	From     time.Time
+	To       time.Time
+	Limit    int
+	Offset   int
+}
+
+type SearchResult struct {
+	Type       string    `json:"type"`
+	ID         int       `json:"id"`
+	TeamID     int       `json:"team_id"`
+	ChannelID  int       `json:"channel_id,omitempty"`
+	TaskID     int       `json:"task_id,omitempty"`
+	AuthorID   int       `json:"author_id"`
+	AuthorName string    `json:"author_name"`
+	Title      string    `json:"title,omitempty"`
+	Snippet    string    `json:"snippet"`
+	Rank       float64   `json:"rank"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type Attachment struct {
+	AttachmentID int       `json:"attachment_id"`
+	TeamID       int       `json:"team_id"`
+	TaskID       int       `json:"task_id,omitempty"`
+	CommentID    int       `json:"comment_id,omitempty"`
+	MessageID    int       `json:"message_id,omitempty"`
+	UploaderID   int       `json:"uploader_id"`
+	FileName     string    `json:"file_name"`
+	ContentType  string    `json:"content_type"`
+	SizeBytes    int64     `json:"size_bytes"`
+	StorageKey   string    `json:"-"`
+	ThumbnailKey string    `json:"-"`
+	HasThumbnail bool      `json:"has_thumbnail"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// TaskSuggestion is the only shape the copilot may return for a task.
+// Empty fields leave the task unchanged.
+type TaskSuggestion struct {
+	Title       string       `json:"title"`
+	Description string       `json:"description"`
+	Priority    TaskPriority `json:"priority"`
+	Status      TaskStatus   `json:"status"`
+}
+
+type TaskFieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+type TaskEnhancement struct {
+	TaskID     int               `json:"task_id"`
+	Suggestion TaskSuggestion    `json:"suggestion"`
+	Changes    []TaskFieldChange `json:"changes"`
+	Task       Task              `json:"task"`
+}
+
+// PlannedTask is one task proposed by the copilot when breaking down a goal
+type PlannedTask struct {
+	Title        string       `json:"title"`
+	Description  string       `json:"description"`
+	Priority     TaskPriority `json:"priority"`
+	AssigneeID   int          `json:"assignee_id,omitempty"`
+	AssigneeName string       `json:"assignee_name,omitempty"`
+}
+
+type TaskPlan struct {
+	TeamID int           `json:"team_id"`
+	Goal   string        `json:"goal"`
+	Tasks  []PlannedTask `json:"tasks"`
+}
+
+const (
+	SummaryKindTask = "task"
+	SummaryKindChat = "chat"
+
+	SummaryStatusReady   = "ready"
+	SummaryStatusPending = "pending"
+)
+
+type Summary struct {
+	Status      string `json:"status"`
+	Summary     string `json:"summary,omitempty"`
+	ContentHash string `json:"content_hash"`
+	ItemCount   int    `json:"item_count"`
+}
+
+const (
+	CopilotFeatureEnhance    = "enhance"
+	CopilotFeatureDescribe   = "describe"
+	CopilotFeaturePlan       = "plan"
+	CopilotFeatureSummary    = "summary"
+	CopilotFeatureQuery      = "query"
+	CopilotFeatureDuplicates = "duplicates"
+
+	CopilotOutcomeSuccess = "success"
+	CopilotOutcomeInvalid = "invalid"
+	CopilotOutcomeTimeout = "timeout"
+	CopilotOutcomeError   = "error"
+)
+
+// CopilotCall is one audited use of a copilot feature
+type CopilotCall struct {
+	CallID           int       `json:"call_id"`
+	UserID           int       `json:"user_id"`
+	TeamID           int       `json:"team_id"`
+	TaskID           int       `json:"task_id,omitempty"`
+	Feature          string    `json:"feature"`
+	Model            string    `json:"model"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	LatencyMs        int       `json:"latency_ms"`
+	Outcome          string    `json:"outcome"`
+	Error            string    `json:"error,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// CopilotUsage aggregates copilot calls per day, team, user, feature and model
+type CopilotUsage struct {
+	Day              string  `json:"day"`
+	TeamID           int     `json:"team_id"`
+	TeamName         string  `json:"team_name"`
+	UserID           int     `json:"user_id"`
+	UserName         string  `json:"user_name"`
+	Feature          string  `json:"feature"`
+	Model            string  `json:"model"`
+	Calls            int     `json:"calls"`
+	Failures         int     `json:"failures"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	AvgLatencyMs     int     `json:"avg_latency_ms"`
+	EstimatedCost    float64 `json:"estimated_cost"`
+}
+
+type CopilotUsageFilter struct {
+	From   time.Time
+	To     time.Time
+	TeamID int
+	UserID int
+}
+
+/*
+TaskFilter is a structured task query. It is what the copilot produces from a
+natural-language question and what users send back to refine it. Dates are
+YYYY-MM-DD; empty fields do not filter.
+*/
+type TaskFilter struct {
+	TeamIDs     []int          `json:"team_ids,omitempty"`
+	Statuses    []TaskStatus   `json:"statuses,omitempty"`
+	Priorities  []TaskPriority `json:"priorities,omitempty"`
+	AssigneeIDs []int          `json:"assignee_ids,omitempty"`
+	Unassigned  bool           `json:"unassigned,omitempty"`
+	CreatorIDs  []int          `json:"creator_ids,omitempty"`
+	DueAfter    string         `json:"due_after,omitempty"`
+	DueBefore   string         `json:"due_before,omitempty"`
+	Overdue     bool           `json:"overdue,omitempty"`
+	Text        string         `json:"text,omitempty"`
+	Limit       int            `json:"limit,omitempty"`
+}
+
+type TaskQueryResult struct {
+	Question string     `json:"question,omitempty"`
+	Filter   TaskFilter `json:"filter"`
+	Tasks    []Task     `json:"tasks"`
+}
+
+// DuplicateCandidate is an existing task that looks like the same work as another one
+type DuplicateCandidate struct {
+	TaskID int        `json:"task_id"`
+	Title  string     `json:"title"`
+	Status TaskStatus `json:"status"`
+	Score  float64    `json:"score"`
+}
+
+// Team events that can be delivered to webhooks, named after their websocket frames
+const (
+	WebhookEventTaskCreated   = "TASK_CREATED"
+	WebhookEventTaskUpdated   = "TASK_UPDATED"
+	WebhookEventTaskDeleted   = "TASK_DELETED"
+	WebhookEventMemberAdded   = "MEMBER_ADDED"
+	WebhookEventMemberRemoved = "MEMBER_REMOVED"
+	WebhookEventTeamDeleted   = "TEAM_DELETED"
+	WebhookEventTaskLinked    = "TASK_LINKED"
+	WebhookEventTasksImported = "TASKS_IMPORTED"
+
+	// Sent by the test endpoint only
+	WebhookEventPing = "PING"
+)
+
+var WebhookEvents = []string{
+	WebhookEventTaskCreated,
+	WebhookEventTaskUpdated,
+	WebhookEventTaskDeleted,
+	WebhookEventMemberAdded,
+	WebhookEventMemberRemoved,
+	WebhookEventTeamDeleted,
+	WebhookEventTaskLinked,
+	WebhookEventTasksImported,
+}
+
+// Webhook subscribes a URL to a team's events. An empty Events list means every event.
+type Webhook struct {
+	WebhookID int       `json:"webhook_id"`
+	TeamID    int       `json:"team_id"`
+	CreatedBy int       `json:"created_by"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryRetrying  = "retrying"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryDead      = "dead"
+)
+
+type WebhookDelivery struct {
+	DeliveryID     int       `json:"delivery_id"`
+	WebhookID      int       `json:"webhook_id"`
+	Event          string    `json:"event"`
+	Payload        string    `json:"payload"`
+	Status         string    `json:"status"`
+	Attempts       int       `json:"attempts"`
+	ResponseStatus int       `json:"response_status,omitempty"`
+	LastError      string    `json:"last_error,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+const (
+	GitProviderGitHub = "github"
+	GitProviderGitLab = "gitlab"
+)
+
+// GitIntegration accepts push and pull request webhooks from a Git host for one team
+type GitIntegration struct {
+	IntegrationID  int       `json:"integration_id"`
+	TeamID         int       `json:"team_id"`
+	Provider       string    `json:"provider"`
+	Secret         string    `json:"secret,omitempty"`
+	AutoTransition bool      `json:"auto_transition"`
+	CreatedBy      int       `json:"created_by"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+const (
+	TaskLinkCommit      = "commit"
+	TaskLinkPullRequest = "pull_request"
+
+	PullRequestOpen   = "open"
+	PullRequestMerged = "merged"
+	PullRequestClosed = "closed"
+)
+
+// TaskLink is a commit or pull request whose message, title or branch references a task
+type TaskLink struct {
+	LinkID     int       `json:"link_id"`
+	TaskID     int       `json:"task_id"`
+	Kind       string    `json:"kind"`
+	Provider   string    `json:"provider"`
+	Repository string    `json:"repository"`
+	ExternalID string    `json:"external_id"`
+	URL        string    `json:"url"`
+	Title      string    `json:"title"`
+	Author     string    `json:"author"`
+	State      string    `json:"state,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// CalendarFeed is a tokenized .ics feed of due dates: the user's assigned tasks, or all tasks of TeamID
+type CalendarFeed struct {
+	FeedID    int       `json:"feed_id"`
+	UserID    int       `json:"user_id"`
+	TeamID    int       `json:"team_id,omitempty"`
+	Timezone  string    `json:"timezone"`
+	URL       string    `json:"url,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TeamExportVersion is bumped whenever the export format changes incompatibly
+const TeamExportVersion = 1
+
+// TeamExport is a team's tasks, their comments and the team's members.
+// Users are identified by email so the file can be imported into another team.
+type TeamExport struct {
+	Version    int            `json:"version"`
+	ExportedAt time.Time      `json:"exported_at"`
+	Team       Team           `json:"team"`
+	Members    []ExportMember `json:"members"`
+	Tasks      []ExportTask   `json:"tasks"`
+}
+
+type ExportMember struct {
+	UserID   int       `json:"user_id"`
+	UserName string    `json:"user_name"`
+	Email    string    `json:"email"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+type ExportTask struct {
+	TaskID        int             `json:"task_id"`
+	Title         string          `json:"title"`
+	Description   string          `json:"description"`
+	Status        TaskStatus      `json:"status"`
+	Priority      TaskPriority    `json:"priority"`
+	AssigneeEmail string          `json:"assignee_email"`
+	AssigneeName  string          `json:"assignee_name"`
+	CreatorEmail  string          `json:"creator_email"`
+	DueDate       *time.Time      `json:"due_date"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     *time.Time      `json:"updated_at"`
+	Comments      []ExportComment `json:"comments"`
+}
+
+type ExportComment struct {
+	CommentID   int       `json:"comment_id"`
+	TaskID      int       `json:"task_id"`
+	AuthorEmail string    `json:"author_email"`
+	AuthorName  string    `json:"author_name"`
+	Content     string    `json:"content"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+/*
+ImportTask is one task to import, as read from a CSV row or a JSON export.
+Fields are kept as text so every problem can be reported against its row.
+*/
+type ImportTask struct {
+	Row           int             `json:"-"`
+	Title         string          `json:"title"`
+	Description   string          `json:"description"`
+	Status        string          `json:"status"`
+	Priority      string          `json:"priority"`
+	AssigneeEmail string          `json:"assignee_email"`
+	DueDate       string          `json:"due_date"`
+	Comments      []ImportComment `json:"comments"`
+}
+
+type ImportComment struct {
+	AuthorEmail string `json:"author_email"`
+	AuthorName  string `json:"author_name"`
+	Content     string `json:"content"`
+	CreatedAt   string `json:"created_at"`
+}
+
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// ImportResult reports an import. Nothing is written when Errors is not empty or DryRun is set.
+type ImportResult struct {
+	DryRun          bool             `json:"dry_run"`
+	Rows            int              `json:"rows"`
+	CreatedTasks    int              `json:"created_tasks"`
+	CreatedComments int              `json:"created_comments"`
+	Errors          []ImportRowError `json:"errors"`
+}
+
+const (
+	ImportJobQueued    = "queued"
+	ImportJobRunning   = "running"
+	ImportJobCompleted = "completed"
+	ImportJobFailed    = "failed"
+)
+
+// ImportJob is a Trello or Jira import running in the background
+type ImportJob struct {
+	JobID       int               `json:"job_id"`
+	TeamID      int               `json:"team_id"`
+	RequestedBy int               `json:"requested_by"`
+	Source      string            `json:"source"`
+	StorageKey  string            `json:"-"`
+	UserMap     map[string]string `json:"-"`
+	DryRun      bool              `json:"dry_run"`
+	Status      string            `json:"status"`
+	Total       int               `json:"total"`
+	Processed   int               `json:"processed"`
+	Report      *ImportReport     `json:"report,omitempty"`
+	Error       string            `json:"error,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	FinishedAt  *time.Time        `json:"finished_at,omitempty"`
+}
+
+// ImportReport is the final report of an import job
+type ImportReport struct {
+	ImportResult
+	Source string `json:"source"`
+
+	// Archived cards and other items that were not imported
+	Skipped int `json:"skipped"`
+
+	// Source users that could not be matched to a team member and were left out
+	UnmatchedUsers []string `json:"unmatched_users"`
+	Warnings       []string `json:"warnings"`
+}
+
+// TeamBackupVersion is bumped whenever the backup format changes incompatibly
+const TeamBackupVersion = 1
+
+const (
+	BackupQueued    = "queued"
+	BackupRunning   = "running"
+	BackupCompleted = "completed"
+	BackupFailed    = "failed"
+)
+
+// Backup is a team backup archive produced in the background. It outlives
+// the team so a deleted team can be restored from it.
+type Backup struct {
+	BackupID    int        `json:"backup_id"`
+	TeamID      int        `json:"team_id,omitempty"`
+	TeamName    string     `json:"team_name"`
+	LeaderID    int        `json:"leader_id"`
+	RequestedBy int        `json:"requested_by"`
+	Status      string     `json:"status"`
+	StorageKey  string     `json:"-"`
+	SizeBytes   int64      `json:"size_bytes"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+/*
+TeamBackup is the archive format of a backup. IDs are the ones the rows had
+when the backup was taken; a restore gives every row a new ID and rewrites
+the references. Users are identified by email so they can be matched again.
+*/
+type TeamBackup struct {
+	Version    int             `json:"version"`
+	BackedUpAt time.Time       `json:"backed_up_at"`
+	Team       BackupTeam      `json:"team"`
+	Users      []BackupUser    `json:"users"`
+	Members    []BackupMember  `json:"members"`
+	Channels   []BackupChannel `json:"channels"`
+	Tasks      []BackupTask    `json:"tasks"`
+	Comments   []BackupComment `json:"comments"`
+	Messages   []BackupMessage `json:"messages"`
+}
+
+type BackupTeam struct {
+	TeamID       int       `json:"team_id"`
+	TeamName     string    `json:"team_name"`
+	TeamLeaderID int       `json:"team_leader_id"`
+	AIEnabled    bool      `json:"ai_enabled"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// BackupUser is every user referenced anywhere in the backup
+type BackupUser struct {
+	UserID   int    `json:"user_id"`
+	UserName string `json:"user_name"`
+	Email    string `json:"email"`
+}
+
+type BackupMember struct {
+	UserID    int       `json:"user_id"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type BackupChannel struct {
+	ChannelID int       `json:"channel_id"`
+	Name      string    `json:"name"`
+	IsPrivate bool      `json:"is_private"`
+	IsDefault bool      `json:"is_default"`
+	CreatedBy int       `json:"created_by"`
+	MemberIDs []int     `json:"member_ids"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type BackupTask struct {
+	TaskID      int          `json:"task_id"`
+	CreatorID   int          `json:"creator_id"`
+	AssigneeID  int          `json:"assignee_id"`
+	Title       string       `json:"title"`
+	Description string       `json:"description"`
+	Status      TaskStatus   `json:"status"`
+	Priority    TaskPriority `json:"priority"`
+	DueDate     *time.Time   `json:"due_date"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   *time.Time   `json:"updated_at"`
+}
+
+type BackupComment struct {
+	CommentID int       `json:"comment_id"`
+	TaskID    int       `json:"task_id"`
+	UserID    int       `json:"user_id"`
+	UserName  string    `json:"user_name"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type BackupMessage struct {
+	MessageID int       `json:"message_id"`
+	ChannelID int       `json:"channel_id"`
+	UserID    int       `json:"user_id"`
+	UserName  string    `json:"user_name"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RestoreResult is the team recreated from a backup and what could not be carried over
+type RestoreResult struct {
+	Team     Team `json:"team"`
+	Tasks    int  `json:"tasks"`
+	Comments int  `json:"comments"`
+	Messages int  `json:"messages"`
+
+	// Emails of backed up users without an account any more. Their tasks are
+	// left unassigned and their comments and messages kept under their name.
+	MissingUsers []string `json:"missing_users"`
+}
+
+const (
+	TrashTypeTeam    = "team"
+	TrashTypeTask    = "task"
+	TrashTypeComment = "comment"
+)
+
+// TrashItem is a deleted team, task or comment that can be restored until it is purged
+type TrashItem struct {
+	Type   string `json:"type"`
+	ID     int    `json:"id"`
+	TeamID int    `json:"team_id"`
+	TaskID int    `json:"task_id,omitempty"`
+
+	// The team's name, the task's title or the start of the comment
+	Title string `json:"title"`
+
+	DeletedBy     int       `json:"deleted_by"`
+	DeletedByName string    `json:"deleted_by_name"`
+	DeletedAt     time.Time `json:"deleted_at"`
+	PurgeAt       time.Time `json:"purge_at"`
+}
+
+const (
+	ActivityTaskCreated     = "task_created"
+	ActivityTaskMoved       = "task_moved"
+	ActivityTaskAssigned    = "task_assigned"
+	ActivityTaskDeleted     = "task_deleted"
+	ActivityTaskRestored    = "task_restored"
+	ActivityTasksImported   = "tasks_imported"
+	ActivityCommentPosted   = "comment_posted"
+	ActivityMemberJoined    = "member_joined"
+	ActivityMemberLeft      = "member_left"
+	ActivityCopilotEnhanced = "copilot_enhanced"
+)
+
+// ActivityTypes are the event types of the team activity feed
+var ActivityTypes = []string{
+	ActivityTaskCreated,
+	ActivityTaskMoved,
+	ActivityTaskAssigned,
+	ActivityTaskDeleted,
+	ActivityTaskRestored,
+	ActivityTasksImported,
+	ActivityCommentPosted,
+	ActivityMemberJoined,
+	ActivityMemberLeft,
+	ActivityCopilotEnhanced,
+}
+
+// Activity is one event of a team's activity feed. Data holds the details
+// of the event, e.g. the task title or the statuses a task moved between.
+type Activity struct {
+	ActivityID int64                  `json:"activity_id"`
+	TeamID     int                    `json:"team_id"`
+	ActorID    int                    `json:"actor_id,omitempty"`
+	ActorName  string                 `json:"actor_name"`
+	Type       string                 `json:"type"`
+	TaskID     int                    `json:"task_id,omitempty"`
+	Data       map[string]interface{} `json:"data"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
+
+type ActivityFilter struct {
+	TeamID  int
+	Types   []string
+	ActorID int
+	TaskID  int
+	Before  int64
+	Limit   int
+}
+
+// ActivityPage is a page of the activity feed, newest first. NextBefore is
+// passed as before to fetch the next page and is 0 on the last one.
+type ActivityPage struct {
+	Activities []Activity `json:"activities"`
+	NextBefore int64      `json:"next_before,omitempty"`
+}
+
+// TeamAnalytics holds a team's delivery metrics for the days from From up to, but excluding, To
+type TeamAnalytics struct {
+	TeamID       int                `json:"team_id"`
+	From         time.Time          `json:"from"`
+	To           time.Time          `json:"to"`
+	Throughput   []WeeklyThroughput `json:"throughput"`
+	CycleTime    CycleTime          `json:"cycle_time"`
+	TimeInStatus []StatusTime       `json:"time_in_status"`
+	Burndown     []BurndownPoint    `json:"burndown"`
+	Workload     []AssigneeWorkload `json:"workload"`
+	GeneratedAt  time.Time          `json:"generated_at"`
+}
+
+type WeeklyThroughput struct {
+	WeekStart time.Time `json:"week_start"`
+	Created   int       `json:"created"`
+	Completed int       `json:"completed"`
+}
+
+// CycleTime measures the tasks completed in the range from when they were first started
+type CycleTime struct {
+	Tasks        int     `json:"tasks"`
+	AverageHours float64 `json:"average_hours"`
+	P85Hours     float64 `json:"p85_hours"`
+}
+
+type StatusTime struct {
+	Status       TaskStatus `json:"status"`
+	Tasks        int        `json:"tasks"`
+	TotalHours   float64    `json:"total_hours"`
+	AverageHours float64    `json:"average_hours"`
+}
+
+// BurndownPoint counts the team's tasks at the end of a day
+type BurndownPoint struct {
+	Date      time.Time `json:"date"`
+	Total     int       `json:"total"`
+	Remaining int       `json:"remaining"`
+}
+
+// AssigneeWorkload counts the tasks of one assignee; AssigneeID is 0 for unassigned tasks
+type AssigneeWorkload struct {
+	AssigneeID   int    `json:"assignee_id"`
+	AssigneeName string `json:"assignee_name"`
+	Open         int    `json:"open"`
+	InProgress   int    `json:"in_progress"`
+	Overdue      int    `json:"overdue"`
+	Completed    int    `json:"completed"`
+}
+
+const (
+	MentionSourceComment = "comment"
+	MentionSourceMessage = "message"
+)
+
+// Mention is a comment or channel message that mentions a user as @ followed by their user name
+type Mention struct {
+	Source     string    `json:"source"`
+	ID         int       `json:"id"`
+	TeamID     int       `json:"team_id"`
+	TaskID     int       `json:"task_id,omitempty"`
+	TaskTitle  string    `json:"task_title,omitempty"`
+	ChannelID  int       `json:"channel_id,omitempty"`
+	AuthorID   int       `json:"author_id"`
+	AuthorName string    `json:"author_name"`
+	Content    string    `json:"content"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// WorkComment is a comment listed outside its task, with the task it belongs to
+type WorkComment struct {
+	Comment
+	TeamID    int    `json:"team_id"`
+	TaskTitle string `json:"task_title"`
+}
+
+// MyWork is a user's open tasks across all their teams, grouped by due date, with recent activity around them
+type MyWork struct {
+	Overdue  []Task        `json:"overdue"`
+	DueToday []Task        `json:"due_today"`
+	ThisWeek []Task        `json:"this_week"`
+	Later    []Task        `json:"later"`
+	NoDate   []Task        `json:"no_date"`
+	Mentions []Mention     `json:"mentions"`
+	Comments []WorkComment `json:"comments"`
+}
+
+const (
+	SprintPlanned = "planned"
+	SprintActive  = "active"
+	SprintClosed  = "closed"
+)
+
+// Sprint is a time box of a team's work. Dates are YYYY-MM-DD and both are included.
+type Sprint struct {
+	SprintID  int        `json:"sprint_id"`
+	TeamID    int        `json:"team_id"`
+	Name      string     `json:"name"`
+	Goal      string     `json:"goal"`
+	StartDate string     `json:"start_date"`
+	EndDate   string     `json:"end_date"`
+	State     string     `json:"state"`
+	CreatedBy int        `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	ClosedAt  *time.Time `json:"closed_at,omitempty"`
+}
+
+// SprintBoard holds the sprint's tasks by status
+type SprintBoard struct {
+	Sprint  Sprint                `json:"sprint"`
+	Columns map[TaskStatus][]Task `json:"columns"`
+}
+
+type SprintReportTask struct {
+	TaskID      int        `json:"task_id"`
+	Title       string     `json:"title"`
+	Status      TaskStatus `json:"status"`
+	Committed   bool       `json:"committed"`
+	Completed   bool       `json:"completed"`
+	CarriedOver bool       `json:"carried_over"`
+	Removed     bool       `json:"removed"`
+}
+
+/*
+SprintReport compares what a sprint committed to with what it completed.
+Committed tasks were in the sprint when it started (or are planned in it, for
+a sprint that has not started); Added ones joined it later. CompletionRate is
+the share of committed tasks that were completed.
+*/
+type SprintReport struct {
+	Sprint             Sprint             `json:"sprint"`
+	Committed          int                `json:"committed"`
+	CommittedCompleted int                `json:"committed_completed"`
+	Added              int                `json:"added"`
+	Completed          int                `json:"completed"`
+	Removed            int                `json:"removed"`
+	CarriedOver        int                `json:"carried_over"`
+	CompletionRate     float64            `json:"completion_rate"`
+	Tasks              []SprintReportTask `json:"tasks"`
+}
+
+// SprintCloseResult tells where a closed sprint's unfinished tasks went; NextSprint is nil when they went back to the backlog
+type SprintCloseResult struct {
+	Sprint      Sprint        `json:"sprint"`
+	NextSprint  *Sprint       `json:"next_sprint,omitempty"`
+	CarriedOver int           `json:"carried_over"`
+	Report      *SprintReport `json:"report"`
+}
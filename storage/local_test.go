@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readObject(t *testing.T, s Storage, key string) string {
+	t.Helper()
+	r, err := s.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("get %s: %v", key, err)
+	}
+	defer r.Close()
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestLocalStorageRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	s, err := NewLocalStorage(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const key = "attachments/1/42/report.pdf"
+	if err := s.Put(ctx, key, strings.NewReader("first"), 5, "application/pdf"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readObject(t, s, key); got != "first" {
+		t.Errorf("got %q, want %q", got, "first")
+	}
+	if _, err := os.Stat(filepath.Join(root, "attachments", "1", "42", "report.pdf")); err != nil {
+		t.Errorf("object not stored below the root: %v", err)
+	}
+
+	// Overwriting replaces the object and leaves no temporary files behind
+	if err := s.Put(ctx, key, strings.NewReader("second"), 6, "application/pdf"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readObject(t, s, key); got != "second" {
+		t.Errorf("got %q after overwrite, want %q", got, "second")
+	}
+	entries, err := os.ReadDir(filepath.Join(root, "attachments", "1", "42"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory holds %d files, want only the object", len(entries))
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("get after delete returned %v, want %v", err, ErrNotFound)
+	}
+	if err := s.Delete(ctx, key); err != nil {
+		t.Errorf("deleting a missing object returned %v", err)
+	}
+}
+
+func TestLocalStorageFailedPutKeepsObject(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Put(ctx, "a.txt", strings.NewReader("kept"), 4, ""); err != nil {
+		t.Fatal(err)
+	}
+	r := io.MultiReader(bytes.NewReader([]byte("partial")), failingReader{})
+	if err := s.Put(ctx, "a.txt", r, 100, ""); err == nil {
+		t.Fatal("put with a failing reader succeeded")
+	}
+	if got := readObject(t, s, "a.txt"); got != "kept" {
+		t.Errorf("got %q after a failed put, want %q", got, "kept")
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestLocalStorageRejectsEscapingKeys(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"../outside", "a/../../outside", "", "."} {
+		t.Run(key, func(t *testing.T) {
+			if err := s.Put(ctx, key, strings.NewReader("x"), 1, ""); err == nil {
+				t.Error("put accepted the key")
+			}
+			if _, err := s.Get(ctx, key); err == nil {
+				t.Error("get accepted the key")
+			}
+			if err := s.Delete(ctx, key); err == nil {
+				t.Error("delete accepted the key")
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	testAccessKey = "AKIDEXAMPLE"
+	testSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	testRegion    = "eu-west-1"
+	testBucket    = "teamsync"
+)
+
+// fakeS3 is a minimal path-style S3 stand-in that checks request signatures
+type fakeS3 struct {
+	t       *testing.T
+	mu      sync.Mutex
+	objects map[string]fakeObject
+}
+
+type fakeObject struct {
+	body        string
+	contentType string
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *S3Storage) {
+	t.Helper()
+	f := &fakeS3{t: t, objects: make(map[string]fakeObject)}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	s, err := NewS3Storage(srv.URL, testRegion, testBucket, testAccessKey, testSecretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, s
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := f.verify(r); err != nil {
+		f.t.Errorf("%s %s: %v", r.Method, r.URL.EscapedPath(), err)
+		http.Error(w, "<Error><Code>SignatureDoesNotMatch</Code></Error>", http.StatusForbidden)
+		return
+	}
+
+	prefix := "/" + testBucket + "/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		http.Error(w, "<Error><Code>NoSuchBucket</Code></Error>", http.StatusNotFound)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, prefix)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.objects[key] = fakeObject{body: string(body), contentType: r.Header.Get("Content-Type")}
+	case http.MethodGet:
+		obj, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+			return
+		}
+		io.WriteString(w, obj.body)
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// verify recomputes the SigV4 signature from the request as it arrived
+func (f *fakeS3) verify(r *http.Request) error {
+	amzDate := r.Header.Get("X-Amz-Date")
+	if len(amzDate) != len("20060102T150405Z") {
+		return errors.New("missing X-Amz-Date")
+	}
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash != "UNSIGNED-PAYLOAD" {
+		return errors.New("unexpected X-Amz-Content-Sha256: " + payloadHash)
+	}
+
+	scope := amzDate[:8] + "/" + testRegion + "/s3/aws4_request"
+	canonicalRequest := r.Method + "\n" +
+		r.URL.EscapedPath() + "\n" +
+		r.URL.RawQuery + "\n" +
+		"host:" + r.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n" +
+		"\n" +
+		"host;x-amz-content-sha256;x-amz-date\n" +
+		payloadHash
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	key := hmacSHA256([]byte("AWS4"+testSecretKey), amzDate[:8])
+	for _, part := range []string{testRegion, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	want := "AWS4-HMAC-SHA256 Credential=" + testAccessKey + "/" + scope +
+		", SignedHeaders=host;x-amz-content-sha256;x-amz-date" +
+		", Signature=" + hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	if got := r.Header.Get("Authorization"); got != want {
+		return errors.New("signature mismatch: " + got)
+	}
+	return nil
+}
+
+func TestS3StorageRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	f, s := newFakeS3(t)
+
+	tests := []struct {
+		name string
+		key  string
+	}{
+		{"plain key", "attachments/1/42/report.pdf"},
+		{"key needing escapes", "attachments/1/42/Q3 plan (final)+v2.pdf"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const body = "object bytes"
+			if err := s.Put(ctx, tt.key, strings.NewReader(body), int64(len(body)), "application/pdf"); err != nil {
+				t.Fatal(err)
+			}
+
+			f.mu.Lock()
+			obj, ok := f.objects[tt.key]
+			f.mu.Unlock()
+			if !ok {
+				t.Fatalf("object not stored under %q", tt.key)
+			}
+			if obj.contentType != "application/pdf" {
+				t.Errorf("content type %q, want %q", obj.contentType, "application/pdf")
+			}
+
+			if got := readObject(t, s, tt.key); got != body {
+				t.Errorf("got %q, want %q", got, body)
+			}
+
+			if err := s.Delete(ctx, tt.key); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Get(ctx, tt.key); !errors.Is(err, ErrNotFound) {
+				t.Errorf("get after delete returned %v, want %v", err, ErrNotFound)
+			}
+		})
+	}
+}
+
+func TestS3StorageErrors(t *testing.T) {
+	ctx := context.Background()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "<Error><Code>AccessDenied</Code></Error>", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	s, err := NewS3Storage(srv.URL, testRegion, testBucket, testAccessKey, "wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Put(ctx, "a.txt", strings.NewReader("x"), 1, "")
+	if err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("put returned %v, want an access denied error", err)
+	}
+	if _, err := s.Get(ctx, "a.txt"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("get returned %v, want an access denied error", err)
+	}
+	if err := s.Delete(ctx, "a.txt"); err == nil {
+		t.Error("delete succeeded against a failing store")
+	}
+}
+
+func TestNewS3StorageRequiresEndpointAndBucket(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		bucket   string
+	}{
+		{"no endpoint", "", testBucket},
+		{"no bucket", "http://localhost:9000", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewS3Storage(tt.endpoint, testRegion, tt.bucket, testAccessKey, testSecretKey); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestNewFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr bool
+	}{
+		{"default is local", map[string]string{"STORAGE_DRIVER": ""}, "*storage.LocalStorage", false},
+		{"local", map[string]string{"STORAGE_DRIVER": "local"}, "*storage.LocalStorage", false},
+		{"s3", map[string]string{"STORAGE_DRIVER": "s3", "S3_ENDPOINT": "http://localhost:9000", "S3_BUCKET": testBucket}, "*storage.S3Storage", false},
+		{"s3 without bucket", map[string]string{"STORAGE_DRIVER": "s3", "S3_ENDPOINT": "http://localhost:9000", "S3_BUCKET": ""}, "", true},
+		{"unknown driver", map[string]string{"STORAGE_DRIVER": "ftp"}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("STORAGE_LOCAL_DIR", t.TempDir())
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			s, err := NewFromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if got := fmt.Sprintf("%T", s); got != tt.want {
+					t.Errorf("driver %s, want %s", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+// Storage stores attachment bytes under opaque keys
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+/*
+NewFromEnv builds the storage driver selected by STORAGE_DRIVER:
+
+	local: files under STORAGE_LOCAL_DIR (default ./uploads)
+	s3:    an S3-compatible bucket configured by S3_ENDPOINT, S3_REGION,
+	       S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY
+*/
+func NewFromEnv() (Storage, error) {
+	switch driver := os.Getenv("STORAGE_DRIVER"); driver {
+	case "", "local":
+		dir := os.Getenv("STORAGE_LOCAL_DIR")
+		if dir == "" {
+			dir = "./uploads"
+		}
+		return NewLocalStorage(dir)
+	case "s3":
+		region := os.Getenv("S3_REGION")
+		if region == "" {
+			region = "us-east-1"
+		}
+		return NewS3Storage(
+			os.Getenv("S3_ENDPOINT"),
+			region,
+			os.Getenv("S3_BUCKET"),
+			os.Getenv("S3_ACCESS_KEY_ID"),
+			os.Getenv("S3_SECRET_ACCESS_KEY"),
+		)
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_DRIVER: %s", driver)
+	}
+}
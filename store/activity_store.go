@@ -0,0 +1,92 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/drumilbhati/teamsync/models"
+	"github.com/lib/pq"
+)
+
+/*
+CreateActivity saves an event of the team's activity feed. The actor's name
+is looked up from the actor id; a.ActorName is kept for events without a
+user, such as those of Git integrations.
+*/
+func (s *Store) CreateActivity(a *models.Activity) error {
+	if a.Data == nil {
+		a.Data = map[string]interface{}{}
+	}
+	data, err := json.Marshal(a.Data)
+	if err != nil {
+		return err
+	}
+
+	return s.db.QueryRow(
+		`INSERT INTO activity_events (team_id, actor_id, actor_name, type, task_id, data)
+		VALUES ($1, NULLIF($2, 0), COALESCE((SELECT user_name FROM users WHERE user_id = $2), $3), $4, NULLIF($5, 0), $6)
+		RETURNING activity_id, actor_name, created_at`,
+		a.TeamID, a.ActorID, a.ActorName, a.Type, a.TaskID, data,
+	).Scan(&a.ActivityID, &a.ActorName, &a.CreatedAt)
+}
+
+// GetTeamActivity returns a page of the filter's team activity, newest first
+func (s *Store) GetTeamActivity(f models.ActivityFilter) (*models.ActivityPage, error) {
+	args := []interface{}{f.TeamID}
+	conditions := []string{"team_id = $1"}
+
+	if len(f.Types) > 0 {
+		args = append(args, pq.Array(f.Types))
+		conditions = append(conditions, fmt.Sprintf("type = ANY($%d)", len(args)))
+	}
+	if f.ActorID != 0 {
+		args = append(args, f.ActorID)
+		conditions = append(conditions, fmt.Sprintf("actor_id = $%d", len(args)))
+	}
+	if f.TaskID != 0 {
+		args = append(args, f.TaskID)
+		conditions = append(conditions, fmt.Sprintf("task_id = $%d", len(args)))
+	}
+	if f.Before != 0 {
+		args = append(args, f.Before)
+		conditions = append(conditions, fmt.Sprintf("activity_id < $%d", len(args)))
+	}
+
+	// One extra row tells whether there is a next page
+	args = append(args, f.Limit+1)
+	rows, err := s.db.Query(
+		`SELECT activity_id, team_id, COALESCE(actor_id, 0), actor_name, type, COALESCE(task_id, 0), data, created_at
+		FROM activity_events
+		WHERE `+strings.Join(conditions, " AND ")+fmt.Sprintf(`
+		ORDER BY activity_id DESC
+		LIMIT $%d`, len(args)),
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &models.ActivityPage{Activities: []models.Activity{}}
+	for rows.Next() {
+		var a models.Activity
+		var data []byte
+		if err := rows.Scan(&a.ActivityID, &a.TeamID, &a.ActorID, &a.ActorName, &a.Type, &a.TaskID, &data, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &a.Data); err != nil {
+			return nil, err
+		}
+		page.Activities = append(page.Activities, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Activities) > f.Limit {
+		page.Activities = page.Activities[:f.Limit]
+		page.NextBefore = page.Activities[f.Limit-1].ActivityID
+	}
+	return page, nil
+}
@@ -0,0 +1,256 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/drumilbhati/teamsync/models"
+)
+
+// Analytics are recomputed at most this often per team and range
+const analyticsCacheTTL = 5 * time.Minute
+
+func analyticsCacheKey(teamID int, from, to time.Time) string {
+	return fmt.Sprintf("analytics:team:%d:%s:%s", teamID, from.Format("2006-01-02"), to.Format("2006-01-02"))
+}
+
+/*
+GetTeamAnalytics returns the team's metrics for the days from from up to,
+but excluding, to. Both are expected at midnight UTC. Results are served
+from Redis when a recent computation of the same range is cached; the cache
+is best effort and is skipped when Redis fails.
+*/
+func (s *Store) GetTeamAnalytics(ctx context.Context, teamID int, from, to time.Time) (*models.TeamAnalytics, error) {
+	key := analyticsCacheKey(teamID, from, to)
+	if cached, err := s.rdb.Get(ctx, key).Bytes(); err == nil {
+		var a models.TeamAnalytics
+		if json.Unmarshal(cached, &a) == nil {
+			return &a, nil
+		}
+	}
+
+	a, err := s.computeTeamAnalytics(ctx, teamID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	if b, err := json.Marshal(a); err == nil {
+		s.rdb.Set(ctx, key, b, analyticsCacheTTL)
+	}
+	return a, nil
+}
+
+// computeTeamAnalytics runs every aggregate against one snapshot, so the metrics agree with each other
+func (s *Store) computeTeamAnalytics(ctx context.Context, teamID int, from, to time.Time) (*models.TeamAnalytics, error) {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	a := &models.TeamAnalytics{
+		TeamID:       teamID,
+		From:         from,
+		To:           to,
+		Throughput:   []models.WeeklyThroughput{},
+		TimeInStatus: []models.StatusTime{},
+		Burndown:     []models.BurndownPoint{},
+		Workload:     []models.AssigneeWorkload{},
+		GeneratedAt:  time.Now().UTC(),
+	}
+
+	steps := []func(context.Context, *sql.Tx, *models.TeamAnalytics) error{
+		analyticsThroughput,
+		analyticsCycleTime,
+		analyticsTimeInStatus,
+		analyticsBurndown,
+		analyticsWorkload,
+	}
+	for _, step := range steps {
+		if err := step(ctx, tx, a); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// analyticsThroughput counts the tasks created and completed in each week of the range
+func analyticsThroughput(ctx context.Context, tx *sql.Tx, a *models.TeamAnalytics) error {
+	rows, err := tx.QueryContext(ctx,
+		`WITH weeks AS (
+			SELECT generate_series(date_trunc('week', $2::timestamptz), $3::timestamptz - interval '1 second', interval '1 week') AS week_start
+		), created AS (
+			SELECT date_trunc('week', t.created_at) AS week_start, COUNT(*) AS n
+			FROM tasks t
+			WHERE t.team_id = $1 AND `+liveTaskCondition+`
+				AND t.created_at >= $2 AND t.created_at < $3
+			GROUP BY 1
+		), completed AS (
+			SELECT date_trunc('week', h.entered_at) AS week_start, COUNT(DISTINCT h.task_id) AS n
+			FROM task_status_history h
+			JOIN tasks t ON h.task_id = t.task_id
+			WHERE t.team_id = $1 AND `+liveTaskCondition+`
+				AND h.status = 'done' AND h.entered_at >= $2 AND h.entered_at < $3
+			GROUP BY 1
+		)
+		SELECT w.week_start, COALESCE(c.n, 0), COALESCE(d.n, 0)
+		FROM weeks w
+		LEFT JOIN created c ON c.week_start = w.week_start
+		LEFT JOIN completed d ON d.week_start = w.week_start
+		ORDER BY w.week_start`,
+		a.TeamID, a.From, a.To,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var w models.WeeklyThroughput
+		if err := rows.Scan(&w.WeekStart, &w.Created, &w.Completed); err != nil {
+			return err
+		}
+		a.Throughput = append(a.Throughput, w)
+	}
+	return rows.Err()
+}
+
+/*
+analyticsCycleTime measures, for each task last completed in the range, the
+time from when it first entered in_progress to that completion. Tasks that
+went straight to done are left out.
+*/
+func analyticsCycleTime(ctx context.Context, tx *sql.Tx, a *models.TeamAnalytics) error {
+	return tx.QueryRowContext(ctx,
+		`WITH completions AS (
+			SELECT h.task_id, MAX(h.entered_at) AS done_at
+			FROM task_status_history h
+			JOIN tasks t ON h.task_id = t.task_id
+			WHERE t.team_id = $1 AND `+liveTaskCondition+`
+				AND h.status = 'done' AND h.entered_at >= $2 AND h.entered_at < $3
+			GROUP BY h.task_id
+		), cycles AS (
+			SELECT EXTRACT(EPOCH FROM d.done_at - MIN(h.entered_at)) / 3600 AS hours
+			FROM completions d
+			JOIN task_status_history h ON h.task_id = d.task_id
+			WHERE h.status = 'in_progress' AND h.entered_at < d.done_at
+			GROUP BY d.task_id, d.done_at
+		)
+		SELECT COUNT(*), COALESCE(AVG(hours), 0),
+			COALESCE(percentile_cont(0.85) WITHIN GROUP (ORDER BY hours), 0)
+		FROM cycles`,
+		a.TeamID, a.From, a.To,
+	).Scan(&a.CycleTime.Tasks, &a.CycleTime.AverageHours, &a.CycleTime.P85Hours)
+}
+
+/*
+analyticsTimeInStatus adds up the time the team's tasks spent in each status
+within the range. A status lasts until the task's next change, or until now
+for its current one. done is left out as it has no end.
+*/
+func analyticsTimeInStatus(ctx context.Context, tx *sql.Tx, a *models.TeamAnalytics) error {
+	rows, err := tx.QueryContext(ctx,
+		`WITH spans AS (
+			SELECT h.task_id, h.status, h.entered_at AS started_at,
+				COALESCE(LEAD(h.entered_at) OVER (PARTITION BY h.task_id ORDER BY h.entered_at, h.history_id), CURRENT_TIMESTAMP) AS ended_at
+			FROM task_status_history h
+			JOIN tasks t ON h.task_id = t.task_id
+			WHERE t.team_id = $1 AND `+liveTaskCondition+`
+		)
+		SELECT status, COUNT(DISTINCT task_id),
+			SUM(EXTRACT(EPOCH FROM LEAST(ended_at, $3) - GREATEST(started_at, $2))) / 3600
+		FROM spans
+		WHERE status <> 'done' AND started_at < $3 AND ended_at > $2
+		GROUP BY status
+		ORDER BY status`,
+		a.TeamID, a.From, a.To,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var st models.StatusTime
+		if err := rows.Scan(&st.Status, &st.Tasks, &st.TotalHours); err != nil {
+			return err
+		}
+		if st.Tasks > 0 {
+			st.AverageHours = st.TotalHours / float64(st.Tasks)
+		}
+		a.TimeInStatus = append(a.TimeInStatus, st)
+	}
+	return rows.Err()
+}
+
+// analyticsBurndown counts, at the end of each day of the range, the team's tasks and those not yet done
+func analyticsBurndown(ctx context.Context, tx *sql.Tx, a *models.TeamAnalytics) error {
+	rows, err := tx.QueryContext(ctx,
+		`WITH days AS (
+			SELECT generate_series($2::timestamptz, $3::timestamptz - interval '1 day', interval '1 day') AS day
+		)
+		SELECT d.day, COUNT(t.task_id),
+			COUNT(t.task_id) FILTER (WHERE s.status IS DISTINCT FROM 'done')
+		FROM days d
+		LEFT JOIN tasks t ON t.team_id = $1 AND `+liveTaskCondition+`
+			AND t.created_at < d.day + interval '1 day'
+		LEFT JOIN LATERAL (
+			SELECT h.status FROM task_status_history h
+			WHERE h.task_id = t.task_id AND h.entered_at < d.day + interval '1 day'
+			ORDER BY h.entered_at DESC, h.history_id DESC
+			LIMIT 1
+		) s ON true
+		GROUP BY d.day
+		ORDER BY d.day`,
+		a.TeamID, a.From, a.To,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p models.BurndownPoint
+		if err := rows.Scan(&p.Date, &p.Total, &p.Remaining); err != nil {
+			return err
+		}
+		a.Burndown = append(a.Burndown, p)
+	}
+	return rows.Err()
+}
+
+// analyticsWorkload counts each assignee's open tasks now and the tasks they completed in the range
+func analyticsWorkload(ctx context.Context, tx *sql.Tx, a *models.TeamAnalytics) error {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT COALESCE(t.assignee_id, 0), COALESCE(u.user_name, ''),
+			COUNT(*) FILTER (WHERE t.status <> 'done'),
+			COUNT(*) FILTER (WHERE t.status = 'in_progress'),
+			COUNT(*) FILTER (WHERE t.status <> 'done' AND t.due_date < CURRENT_TIMESTAMP),
+			COUNT(*) FILTER (WHERE t.status = 'done' AND EXISTS(
+				SELECT 1 FROM task_status_history h
+				WHERE h.task_id = t.task_id AND h.status = 'done' AND h.entered_at >= $2 AND h.entered_at < $3
+			))
+		FROM tasks t
+		LEFT JOIN users u ON t.assignee_id = u.user_id
+		WHERE t.team_id = $1 AND `+liveTaskCondition+`
+		GROUP BY 1, 2
+		ORDER BY 3 DESC, 2`,
+		a.TeamID, a.From, a.To,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var wl models.AssigneeWorkload
+		if err := rows.Scan(&wl.AssigneeID, &wl.AssigneeName, &wl.Open, &wl.InProgress, &wl.Overdue, &wl.Completed); err != nil {
+			return err
+		}
+		a.Workload = append(a.Workload, wl)
+	}
+	return rows.Err()
+}
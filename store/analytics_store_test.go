@@ -0,0 +1,114 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// openTestDB connects to the database at TEST_DATABASE_URL and applies the
+// schema. Tests that need PostgreSQL are skipped when it is not set.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	schema, err := os.ReadFile("../database/schema.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(string(schema)); err != nil {
+		t.Fatalf("applying schema: %v", err)
+	}
+	return db
+}
+
+func TestMergeTasksKeepsSurvivorAnalytics(t *testing.T) {
+	db := openTestDB(t)
+	s := NewStore(db, nil)
+	ctx := context.Background()
+
+	var userID, teamID int
+	email := fmt.Sprintf("analytics-%d@example.com", time.Now().UnixNano())
+	if err := db.QueryRow(
+		`INSERT INTO users (user_name, email, password) VALUES ('analytics', $1, 'x') RETURNING user_id`,
+		email,
+	).Scan(&userID); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow(
+		`INSERT INTO teams (team_name, team_leader_id) VALUES ('Analytics', $1) RETURNING team_id`,
+		userID,
+	).Scan(&teamID); err != nil {
+		t.Fatal(err)
+	}
+
+	createTask := func(title, status string, createdAt time.Time) int {
+		t.Helper()
+		var taskID int
+		if err := db.QueryRow(
+			`INSERT INTO tasks (team_id, creator_id, title, status, created_at)
+			VALUES ($1, $2, $3, $4, $5) RETURNING task_id`,
+			teamID, userID, title, status, createdAt,
+		).Scan(&taskID); err != nil {
+			t.Fatal(err)
+		}
+		return taskID
+	}
+
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	from, to := today.AddDate(0, 0, -7), today.AddDate(0, 0, 1)
+
+	survivorID := createTask("Fix login", "in_progress", now.Add(-72*time.Hour))
+	before, err := s.computeTeamAnalytics(ctx, teamID, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The duplicate was completed; its progress must not be credited to the survivor
+	duplicateID := createTask("Fix the login", "todo", now.Add(-48*time.Hour))
+	if _, err := db.Exec(`UPDATE tasks SET status = 'done' WHERE task_id = $1`, duplicateID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.MergeTasks(survivorID, duplicateID, userID); err != nil {
+		t.Fatal(err)
+	}
+	after, err := s.computeTeamAnalytics(ctx, teamID, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(after.Throughput, before.Throughput) {
+		t.Errorf("throughput after merge = %+v, want %+v", after.Throughput, before.Throughput)
+	}
+	if !reflect.DeepEqual(after.Burndown, before.Burndown) {
+		t.Errorf("burndown after merge = %+v, want %+v", after.Burndown, before.Burndown)
+	}
+	if after.CycleTime != before.CycleTime {
+		t.Errorf("cycle time after merge = %+v, want %+v", after.CycleTime, before.CycleTime)
+	}
+
+	var trashed int
+	if err := db.QueryRow(
+		`SELECT COUNT(*) FROM task_status_history WHERE task_id = $1`, duplicateID,
+	).Scan(&trashed); err != nil {
+		t.Fatal(err)
+	}
+	if trashed == 0 {
+		t.Error("the duplicate's status history did not stay with it in the trash")
+	}
+}
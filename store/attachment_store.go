@@ -0,0 +1,144 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/drumilbhati/teamsync/models"
+)
+
+var ErrQuotaExceeded = errors.New("team attachment quota exceeded")
+
+const attachmentColumns = `attachment_id, team_id, COALESCE(task_id, 0), COALESCE(comment_id, 0), COALESCE(message_id, 0),
+	COALESCE(uploader_id, 0), file_name, content_type, size_bytes, storage_key, COALESCE(thumbnail_key, ''), created_at`
+
+func scanAttachment(row interface{ Scan(...interface{}) error }, a *models.Attachment) error {
+	err := row.Scan(&a.AttachmentID, &a.TeamID, &a.TaskID, &a.CommentID, &a.MessageID,
+		&a.UploaderID, &a.FileName, &a.ContentType, &a.SizeBytes, &a.StorageKey, &a.ThumbnailKey, &a.CreatedAt)
+	a.HasThumbnail = a.ThumbnailKey != ""
+	return err
+}
+
+/*
+Given an attachment save it, failing with ErrQuotaExceeded if the team's
+attachments would exceed quotaBytes in total
+*/
+func (s *Store) CreateAttachment(a *models.Attachment, quotaBytes int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Serialize uploads per team so concurrent requests cannot overshoot the quota
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext('attachments'), $1)", a.TeamID); err != nil {
+		return err
+	}
+
+	var used int64
+	err = tx.QueryRow(
+		"SELECT COALESCE(SUM(size_bytes), 0) FROM attachments WHERE team_id = $1",
+		a.TeamID,
+	).Scan(&used)
+	if err != nil {
+		return err
+	}
+
+	if used+a.SizeBytes > quotaBytes {
+		return ErrQuotaExceeded
+	}
+
+	err = tx.QueryRow(
+		`INSERT INTO attachments (team_id, task_id, comment_id, message_id, uploader_id, file_name, content_type, size_bytes, storage_key)
+		VALUES ($1, NULLIF($2, 0), NULLIF($3, 0), NULLIF($4, 0), $5, $6, $7, $8, $9)
+		RETURNING attachment_id, created_at`,
+		a.TeamID, a.TaskID, a.CommentID, a.MessageID, a.UploaderID, a.FileName, a.ContentType, a.SizeBytes, a.StorageKey,
+	).Scan(&a.AttachmentID, &a.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) GetAttachmentByID(attachmentID int) (*models.Attachment, error) {
+	var a models.Attachment
+	row := s.db.QueryRow(
+		"SELECT "+attachmentColumns+" FROM attachments WHERE attachment_id = $1 AND "+attachmentNotTrashed,
+		attachmentID,
+	)
+	if err := scanAttachment(row, &a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (s *Store) GetAttachmentsByTaskID(taskID int) ([]models.Attachment, error) {
+	return s.getAttachments("task_id", taskID)
+}
+
+func (s *Store) GetAttachmentsByCommentID(commentID int) ([]models.Attachment, error) {
+	return s.getAttachments("comment_id", commentID)
+}
+
+func (s *Store) GetAttachmentsByMessageID(messageID int) ([]models.Attachment, error) {
+	return s.getAttachments("message_id", messageID)
+}
+
+// getAttachments lists attachments by one of the fixed parent columns above
+func (s *Store) getAttachments(column string, id int) ([]models.Attachment, error) {
+	rows, err := s.db.Query(
+		"SELECT "+attachmentColumns+" FROM attachments WHERE "+column+" = $1 AND "+attachmentNotTrashed+" ORDER BY created_at ASC",
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []models.Attachment{}
+	for rows.Next() {
+		var a models.Attachment
+		if err := scanAttachment(rows, &a); err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, rows.Err()
+}
+
+func (s *Store) GetTeamAttachmentUsage(teamID int) (int64, error) {
+	var used int64
+	err := s.db.QueryRow(
+		"SELECT COALESCE(SUM(size_bytes), 0) FROM attachments WHERE team_id = $1",
+		teamID,
+	).Scan(&used)
+	return used, err
+}
+
+func (s *Store) SetAttachmentThumbnail(attachmentID int, thumbnailKey string) error {
+	_, err := s.db.Exec(
+		"UPDATE attachments SET thumbnail_key = $1 WHERE attachment_id = $2",
+		thumbnailKey, attachmentID,
+	)
+	return err
+}
+
+func (s *Store) DeleteAttachmentByID(attachmentID int) error {
+	res, err := s.db.Exec(
+		"DELETE FROM attachments WHERE attachment_id = $1",
+		attachmentID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
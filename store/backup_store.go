@@ -0,0 +1,494 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/drumilbhati/teamsync/models"
+	"github.com/lib/pq"
+)
+
+const backupColumns = `backup_id, COALESCE(team_id, 0), team_name, COALESCE(leader_id, 0), COALESCE(requested_by, 0),
+	status, COALESCE(storage_key, ''), size_bytes, COALESCE(error, ''), created_at, finished_at`
+
+func scanBackup(row interface{ Scan(...interface{}) error }) (*models.Backup, error) {
+	var b models.Backup
+	var finishedAt sql.NullTime
+	err := row.Scan(&b.BackupID, &b.TeamID, &b.TeamName, &b.LeaderID, &b.RequestedBy,
+		&b.Status, &b.StorageKey, &b.SizeBytes, &b.Error, &b.CreatedAt, &finishedAt)
+	if err != nil {
+		return nil, err
+	}
+	if finishedAt.Valid {
+		b.FinishedAt = &finishedAt.Time
+	}
+	return &b, nil
+}
+
+func (s *Store) CreateBackup(b *models.Backup) error {
+	b.Status = models.BackupQueued
+	return s.db.QueryRow(
+		`INSERT INTO team_backups (team_id, team_name, leader_id, requested_by, status)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING backup_id, created_at`,
+		b.TeamID, b.TeamName, b.LeaderID, b.RequestedBy, b.Status,
+	).Scan(&b.BackupID, &b.CreatedAt)
+}
+
+func (s *Store) GetBackupByID(backupID int) (*models.Backup, error) {
+	return scanBackup(s.db.QueryRow(
+		`SELECT `+backupColumns+` FROM team_backups WHERE backup_id = $1`,
+		backupID,
+	))
+}
+
+// GetBackupsForUser returns the backups taken while the user led the team,
+// and those of teams the user leads now, newest first
+func (s *Store) GetBackupsForUser(userID int) ([]models.Backup, error) {
+	rows, err := s.db.Query(
+		`SELECT `+backupColumns+`
+		FROM team_backups
+		WHERE leader_id = $1
+			OR team_id IN (SELECT team_id FROM teams WHERE team_leader_id = $1 AND deleted_at IS NULL)
+		ORDER BY created_at DESC, backup_id DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	backups := []models.Backup{}
+	for rows.Next() {
+		b, err := scanBackup(rows)
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, *b)
+	}
+	return backups, rows.Err()
+}
+
+func (s *Store) StartBackup(backupID int) error {
+	_, err := s.db.Exec(
+		`UPDATE team_backups SET status = $1 WHERE backup_id = $2`,
+		models.BackupRunning, backupID,
+	)
+	return err
+}
+
+// FinishBackup records where a completed archive is stored, or why the backup failed
+func (s *Store) FinishBackup(backupID int, status, storageKey string, size int64, errMsg string) error {
+	_, err := s.db.Exec(
+		`UPDATE team_backups
+		SET status = $1, storage_key = NULLIF($2, ''), size_bytes = $3, error = NULLIF($4, ''),
+			finished_at = CURRENT_TIMESTAMP
+		WHERE backup_id = $5`,
+		status, storageKey, size, errMsg, backupID,
+	)
+	return err
+}
+
+/*
+BuildTeamBackup reads everything the team owns into a backup archive. All
+reads share one repeatable read transaction so the archive is a consistent
+snapshot even while the team keeps working.
+*/
+func (s *Store) BuildTeamBackup(ctx context.Context, teamID int) (*models.TeamBackup, error) {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	b := &models.TeamBackup{
+		Version:    models.TeamBackupVersion,
+		BackedUpAt: time.Now().UTC(),
+		Users:      []models.BackupUser{},
+		Members:    []models.BackupMember{},
+		Channels:   []models.BackupChannel{},
+		Tasks:      []models.BackupTask{},
+		Comments:   []models.BackupComment{},
+		Messages:   []models.BackupMessage{},
+	}
+
+	err = tx.QueryRowContext(ctx,
+		`SELECT team_id, team_name, COALESCE(team_leader_id, 0), ai_enabled, created_at
+		FROM teams WHERE team_id = $1 AND deleted_at IS NULL`,
+		teamID,
+	).Scan(&b.Team.TeamID, &b.Team.TeamName, &b.Team.TeamLeaderID, &b.Team.AIEnabled, &b.Team.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every user referenced by a row, so the restore can match them by email
+	userIDs := map[int]bool{b.Team.TeamLeaderID: true}
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT user_id, role, created_at FROM members WHERE team_id = $1 ORDER BY created_at, member_id`,
+		teamID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var m models.BackupMember
+		if err := rows.Scan(&m.UserID, &m.Role, &m.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		userIDs[m.UserID] = true
+		b.Members = append(b.Members, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.QueryContext(ctx,
+		`SELECT c.channel_id, c.name, c.is_private, c.is_default, COALESCE(c.created_by, 0),
+			COALESCE(array_agg(cm.user_id) FILTER (WHERE cm.user_id IS NOT NULL), '{}'), c.created_at
+		FROM channels c
+		LEFT JOIN channel_members cm ON c.channel_id = cm.channel_id
+		WHERE c.team_id = $1
+		GROUP BY c.channel_id
+		ORDER BY c.channel_id`,
+		teamID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var c models.BackupChannel
+		var memberIDs pq.Int64Array
+		if err := rows.Scan(&c.ChannelID, &c.Name, &c.IsPrivate, &c.IsDefault, &c.CreatedBy, &memberIDs, &c.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		c.MemberIDs = make([]int, len(memberIDs))
+		for i, id := range memberIDs {
+			c.MemberIDs[i] = int(id)
+			userIDs[int(id)] = true
+		}
+		userIDs[c.CreatedBy] = true
+		b.Channels = append(b.Channels, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.QueryContext(ctx,
+		`SELECT task_id, COALESCE(creator_id, 0), COALESCE(assignee_id, 0), title, COALESCE(description, ''),
+			status, priority, due_date, created_at, updated_at
+		FROM tasks WHERE team_id = $1 AND deleted_at IS NULL ORDER BY task_id`,
+		teamID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var t models.BackupTask
+		var dueDate, updatedAt sql.NullTime
+		if err := rows.Scan(&t.TaskID, &t.CreatorID, &t.AssigneeID, &t.Title, &t.Description,
+			&t.Status, &t.Priority, &dueDate, &t.CreatedAt, &updatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if dueDate.Valid {
+			t.DueDate = &dueDate.Time
+		}
+		if updatedAt.Valid {
+			t.UpdatedAt = &updatedAt.Time
+		}
+		userIDs[t.CreatorID] = true
+		userIDs[t.AssigneeID] = true
+		b.Tasks = append(b.Tasks, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.QueryContext(ctx,
+		`SELECT c.comment_id, c.task_id, COALESCE(c.user_id, 0), COALESCE(c.user_name, u.user_name, ''), c.content, c.created_at
+		FROM comments c
+		JOIN tasks t ON c.task_id = t.task_id
+		LEFT JOIN users u ON c.user_id = u.user_id
+		WHERE t.team_id = $1 AND t.deleted_at IS NULL AND c.deleted_at IS NULL
+		ORDER BY c.comment_id`,
+		teamID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var c models.BackupComment
+		if err := rows.Scan(&c.CommentID, &c.TaskID, &c.UserID, &c.UserName, &c.Content, &c.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		userIDs[c.UserID] = true
+		b.Comments = append(b.Comments, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.QueryContext(ctx,
+		`SELECT message_id, COALESCE(channel_id, 0), COALESCE(user_id, 0), user_name, content, created_at
+		FROM messages WHERE team_id = $1 ORDER BY message_id`,
+		teamID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var m models.BackupMessage
+		if err := rows.Scan(&m.MessageID, &m.ChannelID, &m.UserID, &m.UserName, &m.Content, &m.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		userIDs[m.UserID] = true
+		b.Messages = append(b.Messages, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ids := []int64{}
+	for id := range userIDs {
+		if id != 0 {
+			ids = append(ids, int64(id))
+		}
+	}
+	rows, err = tx.QueryContext(ctx,
+		`SELECT user_id, user_name, email FROM users WHERE user_id = ANY($1) ORDER BY user_id`,
+		pq.Int64Array(ids),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var u models.BackupUser
+		if err := rows.Scan(&u.UserID, &u.UserName, &u.Email); err != nil {
+			return nil, err
+		}
+		b.Users = append(b.Users, u)
+	}
+	return b, rows.Err()
+}
+
+/*
+RestoreTeamBackup recreates a backed up team as a new team led by the
+restorer, in a single transaction. Every team, channel, task, comment and
+message gets a new ID and references between them are rewritten. Users are
+matched by email; members without an account any more are dropped, their
+tasks left unassigned and their comments and messages attributed to the
+restorer under their original name.
+*/
+func (s *Store) RestoreTeamBackup(b *models.TeamBackup, restorerID int) (*models.RestoreResult, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &models.RestoreResult{MissingUsers: []string{}}
+
+	emails := make([]string, len(b.Users))
+	for i, u := range b.Users {
+		emails[i] = strings.ToLower(u.Email)
+	}
+	rows, err := tx.Query(
+		`SELECT user_id, LOWER(email) FROM users WHERE LOWER(email) = ANY($1)`,
+		pq.Array(emails),
+	)
+	if err != nil {
+		return nil, err
+	}
+	existing := map[string]int{}
+	for rows.Next() {
+		var id int
+		var email string
+		if err := rows.Scan(&id, &email); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		existing[email] = id
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	users := map[int]int{}
+	for _, u := range b.Users {
+		if id, ok := existing[strings.ToLower(u.Email)]; ok {
+			users[u.UserID] = id
+		} else {
+			result.MissingUsers = append(result.MissingUsers, u.Email)
+		}
+	}
+	sort.Strings(result.MissingUsers)
+
+	// authorOf keeps content of users who are gone, under the restorer
+	authorOf := func(oldID int) int {
+		if id, ok := users[oldID]; ok {
+			return id
+		}
+		return restorerID
+	}
+
+	team := &result.Team
+	team.TeamName = b.Team.TeamName
+	team.TeamLeaderID = restorerID
+	team.AIEnabled = b.Team.AIEnabled
+	err = tx.QueryRow(
+		`INSERT INTO teams (team_name, team_leader_id, ai_enabled)
+		VALUES ($1, $2, $3)
+		RETURNING team_id, created_at`,
+		team.TeamName, team.TeamLeaderID, team.AIEnabled,
+	).Scan(&team.TeamID, &team.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	team.TeamCode = generateTeamCode(team.TeamID)
+
+	_, err = tx.Exec(
+		`INSERT INTO members (user_id, team_id, role) VALUES ($1, $2, 'leader')`,
+		restorerID, team.TeamID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	members := map[int]bool{restorerID: true}
+	for _, m := range b.Members {
+		id, ok := users[m.UserID]
+		if !ok || members[id] {
+			continue
+		}
+		// The restorer leads the restored team
+		role := m.Role
+		if role == "leader" {
+			role = "member"
+		}
+		_, err = tx.Exec(
+			`INSERT INTO members (user_id, team_id, role, created_at) VALUES ($1, $2, $3, $4)`,
+			id, team.TeamID, role, m.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		members[id] = true
+	}
+
+	channels := map[int]int{}
+	defaultChannelID := 0
+	for _, c := range b.Channels {
+		var id int
+		err = tx.QueryRow(
+			`INSERT INTO channels (team_id, name, is_private, is_default, created_by, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING channel_id`,
+			team.TeamID, c.Name, c.IsPrivate, c.IsDefault && defaultChannelID == 0, authorOf(c.CreatedBy), c.CreatedAt,
+		).Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		channels[c.ChannelID] = id
+		if c.IsDefault && defaultChannelID == 0 {
+			defaultChannelID = id
+		}
+
+		for _, userID := range c.MemberIDs {
+			if newID, ok := users[userID]; ok && members[newID] {
+				if _, err := tx.Exec(
+					`INSERT INTO channel_members (channel_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+					id, newID,
+				); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	if defaultChannelID == 0 {
+		err = tx.QueryRow(
+			`INSERT INTO channels (team_id, name, is_default, created_by)
+			VALUES ($1, 'general', TRUE, $2)
+			RETURNING channel_id`,
+			team.TeamID, restorerID,
+		).Scan(&defaultChannelID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tasks := map[int]int{}
+	for _, t := range b.Tasks {
+		var creatorID, assigneeID sql.NullInt64
+		if id, ok := users[t.CreatorID]; ok {
+			creatorID = sql.NullInt64{Int64: int64(id), Valid: true}
+		}
+		if id, ok := users[t.AssigneeID]; ok && members[id] {
+			assigneeID = sql.NullInt64{Int64: int64(id), Valid: true}
+		}
+
+		var id int
+		err = tx.QueryRow(
+			`INSERT INTO tasks (team_id, creator_id, assignee_id, title, description, status, priority, due_date, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8, $9, $10)
+			RETURNING task_id`,
+			team.TeamID, creatorID, assigneeID, t.Title, t.Description, t.Status, t.Priority, t.DueDate, t.CreatedAt, t.UpdatedAt,
+		).Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		tasks[t.TaskID] = id
+	}
+	result.Tasks = len(tasks)
+
+	for _, c := range b.Comments {
+		taskID, ok := tasks[c.TaskID]
+		if !ok {
+			continue
+		}
+		_, err = tx.Exec(
+			`INSERT INTO comments (task_id, user_id, user_name, content, created_at)
+			VALUES ($1, $2, $3, $4, $5)`,
+			taskID, authorOf(c.UserID), c.UserName, c.Content, c.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		result.Comments++
+	}
+
+	for _, m := range b.Messages {
+		channelID, ok := channels[m.ChannelID]
+		if !ok {
+			channelID = defaultChannelID
+		}
+		_, err = tx.Exec(
+			`INSERT INTO messages (team_id, channel_id, user_id, user_name, content, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6)`,
+			team.TeamID, channelID, authorOf(m.UserID), m.UserName, m.Content, m.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		result.Messages++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
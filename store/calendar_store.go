@@ -0,0 +1,114 @@
+package store
+
+import (
+	"github.com/drumilbhati/teamsync/models"
+)
+
+const calendarFeedColumns = `feed_id, user_id, COALESCE(team_id, 0), timezone, created_at`
+
+func scanCalendarFeed(row interface{ Scan(...interface{}) error }, f *models.CalendarFeed) error {
+	return row.Scan(&f.FeedID, &f.UserID, &f.TeamID, &f.Timezone, &f.CreatedAt)
+}
+
+func (s *Store) CreateCalendarFeed(f *models.CalendarFeed, tokenHash string) error {
+	return s.db.QueryRow(
+		`INSERT INTO calendar_feeds (user_id, team_id, token_hash, timezone)
+		VALUES ($1, NULLIF($2, 0), $3, $4)
+		RETURNING feed_id, created_at`,
+		f.UserID, f.TeamID, tokenHash, f.Timezone,
+	).Scan(&f.FeedID, &f.CreatedAt)
+}
+
+func (s *Store) GetCalendarFeedByID(feedID int) (*models.CalendarFeed, error) {
+	var f models.CalendarFeed
+	row := s.db.QueryRow(
+		"SELECT "+calendarFeedColumns+" FROM calendar_feeds WHERE feed_id = $1",
+		feedID,
+	)
+	if err := scanCalendarFeed(row, &f); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func (s *Store) GetCalendarFeedByTokenHash(tokenHash string) (*models.CalendarFeed, error) {
+	var f models.CalendarFeed
+	row := s.db.QueryRow(
+		"SELECT "+calendarFeedColumns+" FROM calendar_feeds WHERE token_hash = $1",
+		tokenHash,
+	)
+	if err := scanCalendarFeed(row, &f); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func (s *Store) GetCalendarFeedsByUserID(userID int) ([]models.CalendarFeed, error) {
+	rows, err := s.db.Query(
+		"SELECT "+calendarFeedColumns+" FROM calendar_feeds WHERE user_id = $1 ORDER BY feed_id",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	feeds := []models.CalendarFeed{}
+	for rows.Next() {
+		var f models.CalendarFeed
+		if err := scanCalendarFeed(rows, &f); err != nil {
+			return nil, err
+		}
+		feeds = append(feeds, f)
+	}
+	return feeds, rows.Err()
+}
+
+// DeleteCalendarFeedByID revokes the feed's token
+func (s *Store) DeleteCalendarFeedByID(feedID int) error {
+	_, err := s.db.Exec("DELETE FROM calendar_feeds WHERE feed_id = $1", feedID)
+	return err
+}
+
+/*
+GetCalendarTasks returns the tasks with a due date shown in a feed: every
+task of the team when teamID is set, otherwise the tasks assigned to the
+user in teams they still belong to. Tasks due more than a year ago are left out.
+*/
+func (s *Store) GetCalendarTasks(userID, teamID int) ([]models.Task, error) {
+	rows, err := s.db.Query(
+		`SELECT t.task_id, t.team_id, t.creator_id, t.assignee_id, u.user_name, t.title, t.description, t.status, t.priority, t.due_date, t.created_at, t.updated_at
+		FROM tasks t
+		LEFT JOIN users u ON t.assignee_id = u.user_id
+		WHERE t.due_date IS NOT NULL
+			AND t.due_date > NOW() - INTERVAL '1 year'
+			AND `+liveTaskCondition+`
+			AND CASE WHEN $2 = 0
+				THEN t.assignee_id = $1 AND (
+					t.team_id IN (SELECT team_id FROM members WHERE user_id = $1)
+					OR t.team_id IN (SELECT team_id FROM teams WHERE team_leader_id = $1)
+				)
+				ELSE t.team_id = $2
+			END
+		ORDER BY t.due_date`,
+		userID, teamID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := []models.Task{}
+	for rows.Next() {
+		var t models.Task
+		var assigneeName *string
+		if err := rows.Scan(&t.TaskID, &t.TeamID, &t.CreatorID, &t.AssigneeID, &assigneeName, &t.Title, &t.Description, &t.Status, &t.Priority, &t.DueDate, &t.CreatedAt, &t.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if assigneeName != nil {
+			t.AssigneeName = *assigneeName
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, rows.Err()
+}
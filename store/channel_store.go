@@ -0,0 +1,319 @@
+package store
+
+/*
+	APIs needed:
+	GET:
+	GetChannelByID
+	GetChannelsByTeamID
+	GetChannelIDsByUserID
+	GetChannelIDsByTeamID
+	GetTeamChannelIDsForUser
+	GetDefaultChannelID
+
+	POST:
+	CreateChannel
+	AddChannelMember
+
+	PUT:
+	UpdateChannelByID
+
+	DELETE:
+	DeleteChannelByID
+	RemoveChannelMember
+*/
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/drumilbhati/teamsync/models"
+	"github.com/lib/pq"
+)
+
+var ErrChannelNameTaken = errors.New("a channel with this name already exists in the team")
+
+// channelNameError reports a violation of the unique channel name per team as ErrChannelNameTaken
+func channelNameError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrChannelNameTaken
+	}
+	return err
+}
+
+// channelAccessCondition matches channels (aliased c) that user $1 can read:
+// public channels of their teams and private channels they were added to.
+// Channels of teams in the trash are not readable.
+const channelAccessCondition = `EXISTS(
+	SELECT 1 FROM teams t
+	WHERE t.team_id = c.team_id AND t.deleted_at IS NULL AND (
+		t.team_leader_id = $1
+		OR EXISTS(SELECT 1 FROM members m WHERE m.user_id = $1 AND m.team_id = t.team_id)
+	)
+) AND (
+	NOT c.is_private
+	OR EXISTS(SELECT 1 FROM channel_members cm WHERE cm.channel_id = c.channel_id AND cm.user_id = $1)
+)`
+
+/*
+Given a channel create it, adding the creator as a member of private channels
+*/
+func (s *Store) CreateChannel(c *models.Channel) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(
+		`INSERT INTO channels (team_id, name, is_private, created_by)
+		VALUES ($1, $2, $3, $4)
+		RETURNING channel_id, created_at`,
+		c.TeamID, c.Name, c.IsPrivate, c.CreatedBy,
+	).Scan(&c.ChannelID, &c.CreatedAt)
+	if err != nil {
+		return channelNameError(err)
+	}
+
+	if c.IsPrivate {
+		_, err = tx.Exec(
+			`INSERT INTO channel_members (channel_id, user_id)
+			VALUES ($1, $2)`,
+			c.ChannelID, c.CreatedBy,
+		)
+		if err != nil {
+			return err
+		}
+		c.MemberIDs = []int{c.CreatedBy}
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) GetChannelByID(channelID int) (*models.Channel, error) {
+	var c models.Channel
+	err := s.db.QueryRow(
+		`SELECT channel_id, team_id, name, is_private, is_default, COALESCE(created_by, 0), created_at
+		FROM channels
+		WHERE channel_id = $1`,
+		channelID,
+	).Scan(&c.ChannelID, &c.TeamID, &c.Name, &c.IsPrivate, &c.IsDefault, &c.CreatedBy, &c.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.IsPrivate {
+		memberIDs, err := s.GetChannelMemberIDs(channelID)
+		if err != nil {
+			return nil, err
+		}
+		c.MemberIDs = memberIDs
+	}
+	return &c, nil
+}
+
+/*
+Given a team_id return the channels of the team visible to user_id
+*/
+func (s *Store) GetChannelsByTeamID(teamID int, userID int) ([]models.Channel, error) {
+	rows, err := s.db.Query(
+		`SELECT c.channel_id, c.team_id, c.name, c.is_private, c.is_default, COALESCE(c.created_by, 0), c.created_at
+		FROM channels c
+		WHERE c.team_id = $2 AND `+channelAccessCondition+`
+		ORDER BY c.is_default DESC, c.name ASC`,
+		userID, teamID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	channels := []models.Channel{}
+	for rows.Next() {
+		var c models.Channel
+		if err := rows.Scan(&c.ChannelID, &c.TeamID, &c.Name, &c.IsPrivate, &c.IsDefault, &c.CreatedBy, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		channels = append(channels, c)
+	}
+	return channels, rows.Err()
+}
+
+/*
+Given a user_id return the ids of every channel the user can read, across all teams
+*/
+func (s *Store) GetChannelIDsByUserID(userID int) ([]int, error) {
+	rows, err := s.db.Query(
+		`SELECT c.channel_id
+		FROM channels c
+		WHERE `+channelAccessCondition,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var channelIDs []int
+	for rows.Next() {
+		var channelID int
+		if err := rows.Scan(&channelID); err != nil {
+			return nil, err
+		}
+		channelIDs = append(channelIDs, channelID)
+	}
+	return channelIDs, rows.Err()
+}
+
+/*
+Given a team_id return the ids of all its channels, public and private
+*/
+func (s *Store) GetChannelIDsByTeamID(teamID int) ([]int, error) {
+	rows, err := s.db.Query(
+		"SELECT channel_id FROM channels WHERE team_id = $1",
+		teamID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var channelIDs []int
+	for rows.Next() {
+		var channelID int
+		if err := rows.Scan(&channelID); err != nil {
+			return nil, err
+		}
+		channelIDs = append(channelIDs, channelID)
+	}
+	return channelIDs, rows.Err()
+}
+
+/*
+Given a team_id and user_id return the ids of the team's channels the user can read
+*/
+func (s *Store) GetTeamChannelIDsForUser(teamID int, userID int) ([]int, error) {
+	channels, err := s.GetChannelsByTeamID(teamID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	channelIDs := make([]int, 0, len(channels))
+	for _, c := range channels {
+		channelIDs = append(channelIDs, c.ChannelID)
+	}
+	return channelIDs, nil
+}
+
+func (s *Store) GetDefaultChannelID(teamID int) (int, error) {
+	var channelID int
+	err := s.db.QueryRow(
+		"SELECT channel_id FROM channels WHERE team_id = $1 AND is_default",
+		teamID,
+	).Scan(&channelID)
+	return channelID, err
+}
+
+func (s *Store) CanAccessChannel(userID int, channelID int) (bool, error) {
+	var exists bool
+	err := s.db.QueryRow(
+		`SELECT EXISTS(
+			SELECT 1 FROM channels c WHERE c.channel_id = $2 AND `+channelAccessCondition+`
+		)`,
+		userID, channelID,
+	).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+func (s *Store) UpdateChannelByID(channelID int, c *models.Channel) error {
+	res, err := s.db.Exec(
+		`UPDATE channels
+		SET name = $1
+		WHERE channel_id = $2`,
+		c.Name, channelID,
+	)
+	if err != nil {
+		return channelNameError(err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s *Store) DeleteChannelByID(channelID int) error {
+	res, err := s.db.Exec(
+		"DELETE FROM channels WHERE channel_id = $1 AND NOT is_default",
+		channelID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s *Store) GetChannelMemberIDs(channelID int) ([]int, error) {
+	rows, err := s.db.Query(
+		"SELECT user_id FROM channel_members WHERE channel_id = $1",
+		channelID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	userIDs := []int{}
+	for rows.Next() {
+		var userID int
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, rows.Err()
+}
+
+func (s *Store) AddChannelMember(channelID int, userID int) error {
+	_, err := s.db.Exec(
+		`INSERT INTO channel_members (channel_id, user_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`,
+		channelID, userID,
+	)
+	return err
+}
+
+func (s *Store) RemoveChannelMember(channelID int, userID int) error {
+	res, err := s.db.Exec(
+		"DELETE FROM channel_members WHERE channel_id = $1 AND user_id = $2",
+		channelID, userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -21,7 +21,7 @@ func (s *Store) GetCommentsByTaskID(taskID int) ([]models.Comment, error) {
 	query := `
 		SELECT comment_id, task_id, user_id, COALESCE(user_name, ''), content, created_at
 		FROM comments
-		WHERE task_id = $1
+		WHERE task_id = $1 AND deleted_at IS NULL
 		ORDER BY created_at ASC`
 
 	rows, err := s.db.Query(query, taskID)
@@ -46,7 +46,10 @@ func (s *Store) GetCommentsByTaskID(taskID int) ([]models.Comment, error) {
 func (s *Store) GetCommentbyID(comment_id int) (models.Comment, error) {
 	var c models.Comment
 	err := s.db.QueryRow(
-		"SELECT comment_id, task_id, user_id, COALESCE(user_name, ''), content, created_at FROM comments WHERE comment_id = $1",
+		`SELECT c.comment_id, c.task_id, c.user_id, COALESCE(c.user_name, ''), c.content, c.created_at
+		FROM comments c
+		JOIN tasks t ON c.task_id = t.task_id
+		WHERE c.comment_id = $1 AND c.deleted_at IS NULL AND `+liveTaskCondition,
 		comment_id,
 	).Scan(&c.CommentID, &c.TaskID, &c.UserID, &c.UserName, &c.Content, &c.CreatedAt)
 	return c, err
@@ -56,7 +59,7 @@ func (s *Store) UpdateCommentByID(comment_id int, c *models.Comment) error {
 	res, err := s.db.Exec(
 		`UPDATE comments
 		SET content = $1
-		WHERE comment_id = $2`,
+		WHERE comment_id = $2 AND deleted_at IS NULL`,
 		c.Content, comment_id,
 	)
 	if err != nil {
@@ -73,10 +76,12 @@ func (s *Store) UpdateCommentByID(comment_id int, c *models.Comment) error {
 	return nil
 }
 
-func (s *Store) DeleteCommentByID(comment_id int) error {
+// DeleteCommentByID moves the comment to the trash
+func (s *Store) DeleteCommentByID(comment_id int, deleted_by int) error {
 	res, err := s.db.Exec(
-		"DELETE FROM comments WHERE comment_id = $1",
-		comment_id,
+		`UPDATE comments SET deleted_at = CURRENT_TIMESTAMP, deleted_by = $2
+		WHERE comment_id = $1 AND deleted_at IS NULL`,
+		comment_id, deleted_by,
 	)
 	if err != nil {
 		return err
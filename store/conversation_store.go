@@ -0,0 +1,267 @@
+package store
+
+/*
+	APIs needed:
+	GET:
+	GetConversationByID
+	GetConversationsByUserID
+	GetDirectConversation
+	GetConversationParticipantIDs
+	GetMessagesByConversationID
+
+	POST:
+	CreateConversation
+	AddConversationParticipant
+	CreateConversationMessage
+
+	DELETE:
+	RemoveConversationParticipant
+*/
+
+import (
+	"database/sql"
+
+	"github.com/drumilbhati/teamsync/models"
+)
+
+/*
+Given a conversation create it along with its participants in one transaction
+*/
+func (s *Store) CreateConversation(c *models.Conversation, participantIDs []int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(
+		`INSERT INTO conversations (name, is_group, created_by)
+		VALUES ($1, $2, $3)
+		RETURNING conversation_id, created_at`,
+		c.Name, c.IsGroup, c.CreatedBy,
+	).Scan(&c.ConversationID, &c.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	for _, userID := range participantIDs {
+		_, err = tx.Exec(
+			`INSERT INTO conversation_participants (conversation_id, user_id)
+			VALUES ($1, $2)
+			ON CONFLICT DO NOTHING`,
+			c.ConversationID, userID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) GetConversationByID(conversationID int) (*models.Conversation, error) {
+	var c models.Conversation
+	err := s.db.QueryRow(
+		`SELECT conversation_id, name, is_group, COALESCE(created_by, 0), created_at
+		FROM conversations
+		WHERE conversation_id = $1`,
+		conversationID,
+	).Scan(&c.ConversationID, &c.Name, &c.IsGroup, &c.CreatedBy, &c.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	participants, err := s.getConversationParticipants(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	c.Participants = participants
+	return &c, nil
+}
+
+func (s *Store) getConversationParticipants(conversationID int) ([]models.ConversationParticipant, error) {
+	rows, err := s.db.Query(
+		`SELECT cp.user_id, u.user_name, u.email, cp.joined_at
+		FROM conversation_participants cp
+		JOIN users u ON cp.user_id = u.user_id
+		WHERE cp.conversation_id = $1
+		ORDER BY cp.joined_at ASC`,
+		conversationID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	participants := []models.ConversationParticipant{}
+	for rows.Next() {
+		var p models.ConversationParticipant
+		if err := rows.Scan(&p.UserID, &p.UserName, &p.Email, &p.JoinedAt); err != nil {
+			return nil, err
+		}
+		participants = append(participants, p)
+	}
+	return participants, rows.Err()
+}
+
+/*
+Given a user_id return all conversations the user participates in,
+most recently active first
+*/
+func (s *Store) GetConversationsByUserID(userID int) ([]models.Conversation, error) {
+	rows, err := s.db.Query(
+		`SELECT c.conversation_id, c.name, c.is_group, COALESCE(c.created_by, 0), c.created_at
+		FROM conversations c
+		JOIN conversation_participants cp ON c.conversation_id = cp.conversation_id
+		WHERE cp.user_id = $1
+		ORDER BY COALESCE(
+			(SELECT MAX(cm.created_at) FROM conversation_messages cm WHERE cm.conversation_id = c.conversation_id),
+			c.created_at
+		) DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conversations := []models.Conversation{}
+	for rows.Next() {
+		var c models.Conversation
+		if err := rows.Scan(&c.ConversationID, &c.Name, &c.IsGroup, &c.CreatedBy, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		conversations = append(conversations, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range conversations {
+		participants, err := s.getConversationParticipants(conversations[i].ConversationID)
+		if err != nil {
+			return nil, err
+		}
+		conversations[i].Participants = participants
+	}
+	return conversations, nil
+}
+
+/*
+Given two user_ids return the 1:1 conversation between them, if one exists
+*/
+func (s *Store) GetDirectConversation(userID int, otherUserID int) (*models.Conversation, error) {
+	var conversationID int
+	err := s.db.QueryRow(
+		`SELECT c.conversation_id
+		FROM conversations c
+		WHERE c.is_group = FALSE
+		AND EXISTS (SELECT 1 FROM conversation_participants WHERE conversation_id = c.conversation_id AND user_id = $1)
+		AND EXISTS (SELECT 1 FROM conversation_participants WHERE conversation_id = c.conversation_id AND user_id = $2)
+		LIMIT 1`,
+		userID, otherUserID,
+	).Scan(&conversationID)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetConversationByID(conversationID)
+}
+
+func (s *Store) GetConversationParticipantIDs(conversationID int) ([]int, error) {
+	rows, err := s.db.Query(
+		"SELECT user_id FROM conversation_participants WHERE conversation_id = $1",
+		conversationID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []int
+	for rows.Next() {
+		var userID int
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, rows.Err()
+}
+
+func (s *Store) IsConversationParticipant(userID int, conversationID int) (bool, error) {
+	var exists bool
+	err := s.db.QueryRow(
+		`SELECT EXISTS(
+			SELECT 1 FROM conversation_participants WHERE user_id = $1 AND conversation_id = $2
+		)`,
+		userID, conversationID,
+	).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+func (s *Store) AddConversationParticipant(conversationID int, userID int) error {
+	_, err := s.db.Exec(
+		`INSERT INTO conversation_participants (conversation_id, user_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`,
+		conversationID, userID,
+	)
+	return err
+}
+
+func (s *Store) RemoveConversationParticipant(conversationID int, userID int) error {
+	res, err := s.db.Exec(
+		"DELETE FROM conversation_participants WHERE conversation_id = $1 AND user_id = $2",
+		conversationID, userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s *Store) CreateConversationMessage(msg *models.ConversationMessage) error {
+	err := s.db.QueryRow(
+		`INSERT INTO conversation_messages (conversation_id, user_id, user_name, content)
+		VALUES ($1, $2, $3, $4)
+		RETURNING message_id, created_at`,
+		msg.ConversationID, msg.UserID, msg.UserName, msg.Content,
+	).Scan(&msg.MessageID, &msg.CreatedAt)
+
+	return err
+}
+
+func (s *Store) GetMessagesByConversationID(conversationID int) ([]models.ConversationMessage, error) {
+	rows, err := s.db.Query(
+		`SELECT message_id, conversation_id, user_id, user_name, content, created_at
+		FROM conversation_messages
+		WHERE conversation_id = $1
+		ORDER BY created_at ASC`,
+		conversationID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []models.ConversationMessage{}
+	for rows.Next() {
+		var msg models.ConversationMessage
+		if err := rows.Scan(&msg.MessageID, &msg.ConversationID, &msg.UserID, &msg.UserName, &msg.Content, &msg.CreatedAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
@@ -1,65 +1,453 @@
 package store
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/drumilbhati/teamsync/ai"
 	"github.com/drumilbhati/teamsync/models"
-	"google.golang.org/genai"
 )
 
-func EnhanceTask(task *models.Task) (*models.Task, error) {
-	// 1. Convert input task to JSON for the prompt
-	inputBytes, err := json.Marshal(task)
+const (
+	maxSuggestedTitleLength       = 200
+	maxSuggestedDescriptionLength = 10000
+)
+
+var ErrInvalidSuggestion = errors.New("invalid AI suggestion")
+
+const enhanceTaskInstructions = `You are an AI assistant for a task management platform.
+You will receive a JSON object with a task's title, description, status and priority.
+Your goal is to enhance the task details to be more clear, professional, and actionable.
+
+Enhancement Rules:
+1. **Title:** Make it concise but descriptive (at most 200 characters).
+2. **Description:** Expand on the description to provide context, potential steps, or necessary details based on the title and existing description. Ensure it is well-formatted.
+3. **Priority:** One of "low", "medium" or "high". If the text implies urgency, use "high".
+4. **Status:** Suggest one of "todo", "in_progress", "in_review" or "done" only if the text clearly implies it, otherwise keep the current status.
+
+Respond with a single JSON object with exactly these keys and string values:
+{"title": "...", "description": "...", "priority": "...", "status": "..."}
+
+Return ONLY the raw JSON object. No markdown formatting.`
+
+// EnhanceTask asks the provider for a suggestion and merges it into a copy of
+// the task. Nothing is saved; the caller decides whether to apply it.
+func EnhanceTask(ctx context.Context, provider ai.Provider, task *models.Task) (*models.TaskEnhancement, error) {
+	suggestion, err := SuggestTaskEnhancement(ctx, provider, task)
+	if err != nil {
+		return nil, err
+	}
+
+	merged, changes := MergeTaskSuggestion(task, suggestion)
+	return &models.TaskEnhancement{
+		TaskID:     task.TaskID,
+		Suggestion: *suggestion,
+		Changes:    changes,
+		Task:       *merged,
+	}, nil
+}
+
+// SuggestTaskEnhancement sends only the editable fields to the provider and
+// validates the structured answer
+func SuggestTaskEnhancement(ctx context.Context, provider ai.Provider, task *models.Task) (*models.TaskSuggestion, error) {
+	input := models.TaskSuggestion{
+		Title:       task.Title,
+		Description: task.Description.String,
+		Priority:    task.Priority,
+		Status:      task.Status,
+	}
+	inputBytes, err := json.Marshal(input)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal input task: %v", err)
 	}
 
-	ctx := context.Background()
-	client, err := genai.NewClient(ctx, nil)
+	resp, err := provider.Generate(ctx, ai.Request{
+		System: enhanceTaskInstructions,
+		Prompt: fmt.Sprintf("Input Task (JSON):\n%s", inputBytes),
+		JSON:   true,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create genai client: %v", err)
+		return nil, fmt.Errorf("ai generation failed: %w", err)
 	}
 
-	prompt := fmt.Sprintf(`You are an AI assistant for a task management platform.
-You will receive a JSON object representing a task.
-Your goal is to enhance the task details to be more clear, professional, and actionable.
+	// LLMs sometimes add ```json ... ``` blocks even when told not to
+	responseText := ai.StripCodeFence(resp.Text)
 
-Input Task (JSON):
-%s
+	var suggestion models.TaskSuggestion
+	dec := json.NewDecoder(bytes.NewReader([]byte(responseText)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&suggestion); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSuggestion, err)
+	}
 
-Enhancement Rules:
-1. **Title:** Make it concise but descriptive.
-2. **Description:** Expand on the description to provide context, potential steps, or necessary details based on the title and existing description. Ensure it is well-formatted.
-3. **Consistency:** Ensure the 'status' and 'priority' match the context of the task. If the text implies urgency, ensure priority is 'high'.
-4. **Structure:** Return the EXACT same JSON structure (fields and types) as the input. Do not add or remove fields. Only modify the values of 'title', 'description', 'status', and 'priority' if needed. Preserve all IDs and dates.
+	if err := ValidateTaskSuggestion(&suggestion); err != nil {
+		return nil, err
+	}
+	return &suggestion, nil
+}
+
+// ValidateTaskSuggestion trims the suggestion and rejects values the task
+// columns cannot hold
+func ValidateTaskSuggestion(s *models.TaskSuggestion) error {
+	s.Title = strings.TrimSpace(s.Title)
+	s.Description = strings.TrimSpace(s.Description)
+
+	if len(s.Title) > maxSuggestedTitleLength {
+		return fmt.Errorf("%w: title is longer than %d characters", ErrInvalidSuggestion, maxSuggestedTitleLength)
+	}
+	if len(s.Description) > maxSuggestedDescriptionLength {
+		return fmt.Errorf("%w: description is longer than %d characters", ErrInvalidSuggestion, maxSuggestedDescriptionLength)
+	}
+	if s.Priority != "" && !s.Priority.IsValid() {
+		return fmt.Errorf("%w: unknown priority %q", ErrInvalidSuggestion, s.Priority)
+	}
+	if s.Status != "" && !s.Status.IsValid() {
+		return fmt.Errorf("%w: unknown status %q", ErrInvalidSuggestion, s.Status)
+	}
+	return nil
+}
+
+// MergeTaskSuggestion copies the suggested title, description, priority and
+// status onto a copy of task. IDs, assignee and dates are never touched.
+func MergeTaskSuggestion(task *models.Task, s *models.TaskSuggestion) (*models.Task, []models.TaskFieldChange) {
+	merged := *task
+	changes := []models.TaskFieldChange{}
+
+	if s.Title != "" && s.Title != task.Title {
+		changes = append(changes, models.TaskFieldChange{Field: "title", Old: task.Title, New: s.Title})
+		merged.Title = s.Title
+	}
+	if s.Description != "" && s.Description != task.Description.String {
+		changes = append(changes, models.TaskFieldChange{Field: "description", Old: task.Description.String, New: s.Description})
+		merged.Description.String = s.Description
+		merged.Description.Valid = true
+	}
+	if s.Priority != "" && s.Priority != task.Priority {
+		changes = append(changes, models.TaskFieldChange{Field: "priority", Old: string(task.Priority), New: string(s.Priority)})
+		merged.Priority = s.Priority
+	}
+	if s.Status != "" && s.Status != task.Status {
+		changes = append(changes, models.TaskFieldChange{Field: "status", Old: string(task.Status), New: string(s.Status)})
+		merged.Status = s.Status
+	}
+
+	return &merged, changes
+}
+
+const (
+	maxPlannedTasks  = 20
+	planInstructions = `You are an AI assistant for a task management platform.
+You will receive a goal and the members of the team that will work on it.
+Break the goal down into a small set of concrete, actionable tasks (at most 20).
+
+Rules:
+1. **Title:** Concise but descriptive (at most 200 characters).
+2. **Description:** A few sentences of context, steps or acceptance criteria.
+3. **Priority:** One of "low", "medium" or "high".
+4. **Assignee:** Suggest the user_id of the member best suited for the task based on their role, or 0 to leave it unassigned. Only use user_ids from the member list.
+
+Respond with a single JSON object of the form:
+{"tasks": [{"title": "...", "description": "...", "priority": "...", "assignee_id": 0}]}
+
+Return ONLY the raw JSON object. No markdown formatting.`
+	planExample = `{"tasks": [{"title": "Write the project brief", "description": "Summarise the goal, scope and success criteria.", "priority": "high", "assignee_id": 0}, {"title": "Review the brief with the team", "description": "Walk through the brief and collect open questions.", "priority": "medium", "assignee_id": 0}]}`
+)
+
+type planResponse struct {
+	Tasks []models.PlannedTask `json:"tasks"`
+}
+
+type planMember struct {
+	UserID   int    `json:"user_id"`
+	UserName string `json:"user_name"`
+	Role     string `json:"role"`
+}
+
+// PlanTasks asks the provider to break a goal down into draft tasks for the
+// team's members. Nothing is saved.
+func PlanTasks(ctx context.Context, provider ai.Provider, goal string, members []models.Member) ([]models.PlannedTask, error) {
+	team := make([]planMember, 0, len(members))
+	for _, m := range members {
+		team = append(team, planMember{UserID: m.UserID, UserName: m.UserName, Role: m.Role})
+	}
+	teamBytes, err := json.Marshal(team)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal team members: %v", err)
+	}
+
+	resp, err := provider.Generate(ctx, ai.Request{
+		System:  planInstructions,
+		Prompt:  fmt.Sprintf("Goal:\n%s\n\nTeam members (JSON):\n%s", goal, teamBytes),
+		JSON:    true,
+		Example: planExample,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ai generation failed: %w", err)
+	}
+
+	responseText := ai.StripCodeFence(resp.Text)
+
+	var plan planResponse
+	dec := json.NewDecoder(bytes.NewReader([]byte(responseText)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&plan); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSuggestion, err)
+	}
+
+	if len(plan.Tasks) > maxPlannedTasks {
+		plan.Tasks = plan.Tasks[:maxPlannedTasks]
+	}
 
-Return ONLY the raw JSON string. No markdown formatting.`, string(inputBytes))
+	// Unknown assignees are dropped rather than failing the whole plan
+	tasks := make([]models.PlannedTask, 0, len(plan.Tasks))
+	for _, t := range plan.Tasks {
+		if t.AssigneeID != 0 && !hasMember(members, t.AssigneeID) {
+			t.AssigneeID = 0
+		}
+		if err := ValidatePlannedTask(&t, members); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, nil
+}
+
+// ValidatePlannedTask trims the task, defaults its priority to medium and
+// fills in the assignee's name. Assignees must be members of the team.
+func ValidatePlannedTask(t *models.PlannedTask, members []models.Member) error {
+	t.Title = strings.TrimSpace(t.Title)
+	t.Description = strings.TrimSpace(t.Description)
+	t.AssigneeName = ""
+
+	if t.Title == "" {
+		return fmt.Errorf("%w: task title is required", ErrInvalidSuggestion)
+	}
+	if len(t.Title) > maxSuggestedTitleLength {
+		return fmt.Errorf("%w: title is longer than %d characters", ErrInvalidSuggestion, maxSuggestedTitleLength)
+	}
+	if len(t.Description) > maxSuggestedDescriptionLength {
+		return fmt.Errorf("%w: description is longer than %d characters", ErrInvalidSuggestion, maxSuggestedDescriptionLength)
+	}
+	if t.Priority == "" {
+		t.Priority = models.TaskPriorityMedium
+	}
+	if !t.Priority.IsValid() {
+		return fmt.Errorf("%w: unknown priority %q", ErrInvalidSuggestion, t.Priority)
+	}
+
+	if t.AssigneeID != 0 {
+		for _, m := range members {
+			if m.UserID == t.AssigneeID {
+				t.AssigneeName = m.UserName
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: user %d is not a member of the team", ErrInvalidSuggestion, t.AssigneeID)
+	}
+	return nil
+}
+
+func hasMember(members []models.Member, userID int) bool {
+	for _, m := range members {
+		if m.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
 
-	result, err := client.Models.GenerateContent(
-		ctx,
-		"gemini-3-flash-preview",
-		genai.Text(prompt),
-		nil,
-	)
+var summaryInstructions = map[string]string{
+	models.SummaryKindTask: `You are an AI assistant for a task management platform.
+You will receive a task and its comment thread.
+Summarise the discussion for a teammate who has been away: decisions made, open questions, blockers and next steps.
+Use a few short bullet points in plain text. Mention people by name where it matters. Do not invent details.`,
+	models.SummaryKindChat: `You are an AI assistant for a team chat.
+You will receive the chat messages posted since the reader last checked in.
+Summarise them for a teammate who has been away: main topics, decisions, questions addressed to the team and action items.
+Use a few short bullet points in plain text. Mention people by name where it matters. Do not invent details.`,
+}
+
+// Summarize asks the provider for a short catch-up summary of a transcript
+func Summarize(ctx context.Context, provider ai.Provider, kind, transcript string) (string, error) {
+	instructions, ok := summaryInstructions[kind]
+	if !ok {
+		return "", fmt.Errorf("unknown summary kind: %s", kind)
+	}
+
+	resp, err := provider.Generate(ctx, ai.Request{
+		System: instructions,
+		Prompt: transcript,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("ai generation failed: %v", err)
+		return "", fmt.Errorf("ai generation failed: %w", err)
 	}
+	return strings.TrimSpace(resp.Text), nil
+}
+
+const (
+	defaultTaskQueryLimit = 50
+	maxTaskQueryLimit     = 200
+	maxTaskQueryText      = 200
+	maxQuestionLength     = 500
+	taskQueryDateFormat   = "2006-01-02"
+	taskQueryInstructions = `You are an AI assistant for a task management platform.
+You translate a user's question about tasks into a JSON filter. You never write SQL.
+You will receive today's date, the teams the user belongs to with their members, and the question.
+
+The filter has these optional keys:
+- "team_ids": team_id values from the team list the question refers to (omit for all teams)
+- "statuses": any of "todo", "in_progress", "in_review", "done"
+- "priorities": any of "low", "medium", "high"
+- "assignee_ids": user_id values of the assignees the question names
+- "unassigned": true for tasks without an assignee
+- "creator_ids": user_id values of task creators the question names
+- "due_after", "due_before": inclusive due date bounds as YYYY-MM-DD
+- "overdue": true for tasks past their due date that are not done
+- "text": keywords to match in the title or description
+
+Only use ids that appear in the team list. Resolve relative dates ("this week", "tomorrow") against today's date.
+Omit keys the question does not mention. Respond with a single JSON object and nothing else.`
+	taskQueryExample = `{"team_ids": [], "statuses": ["todo", "in_progress"], "priorities": ["high"], "overdue": true}`
+)
+
+var ErrInvalidTaskFilter = errors.New("invalid task filter")
+
+type taskQueryMember struct {
+	UserID   int    `json:"user_id"`
+	UserName string `json:"user_name"`
+}
+
+type taskQueryTeam struct {
+	TeamID   int               `json:"team_id"`
+	TeamName string            `json:"team_name"`
+	Members  []taskQueryMember `json:"members"`
+}
 
-	// 2. Clean the response (LLMs sometimes add ```json ... ``` blocks even when told not to)
-	responseText := result.Text()
-	responseText = strings.TrimPrefix(responseText, "```json")
-	responseText = strings.TrimSuffix(responseText, "```")
-	responseText = strings.TrimSpace(responseText)
+/*
+InterpretTaskQuery asks the provider to turn a question into a task filter.
+teams are the requester's teams with their members; the filter is validated
+against them so it can never reach outside the requester's teams.
+*/
+func InterpretTaskQuery(ctx context.Context, provider ai.Provider, question string, teams []models.Team, today time.Time) (*models.TaskFilter, error) {
+	scope := make([]taskQueryTeam, 0, len(teams))
+	for _, t := range teams {
+		team := taskQueryTeam{TeamID: t.TeamID, TeamName: t.TeamName, Members: []taskQueryMember{}}
+		for _, m := range t.Members {
+			team.Members = append(team.Members, taskQueryMember{UserID: m.UserID, UserName: m.UserName})
+		}
+		scope = append(scope, team)
+	}
+	scopeBytes, err := json.Marshal(scope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal teams: %v", err)
+	}
 
-	// 3. Unmarshal the JSON back into a Task struct
-	var enhancedTask models.Task
-	err = json.Unmarshal([]byte(responseText), &enhancedTask)
+	resp, err := provider.Generate(ctx, ai.Request{
+		System:  taskQueryInstructions,
+		Prompt:  fmt.Sprintf("Today: %s (%s)\n\nTeams (JSON):\n%s\n\nQuestion:\n%s", today.Format(taskQueryDateFormat), today.Weekday(), scopeBytes, question),
+		JSON:    true,
+		Example: taskQueryExample,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse AI response: %v. Response was: %s", err, responseText)
+		return nil, fmt.Errorf("ai generation failed: %w", err)
+	}
+
+	responseText := ai.StripCodeFence(resp.Text)
+
+	var filter models.TaskFilter
+	dec := json.NewDecoder(bytes.NewReader([]byte(responseText)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&filter); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSuggestion, err)
+	}
+
+	if err := ValidateTaskFilter(&filter, teams); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSuggestion, err)
+	}
+	return &filter, nil
+}
+
+// ValidateTaskQuestion trims a natural-language task question and bounds its length
+func ValidateTaskQuestion(question string) (string, error) {
+	question = strings.TrimSpace(question)
+	if question == "" {
+		return "", fmt.Errorf("question is required")
+	}
+	if len(question) > maxQuestionLength {
+		return "", fmt.Errorf("question is longer than %d characters", maxQuestionLength)
+	}
+	return question, nil
+}
+
+/*
+ValidateTaskFilter normalises f and checks every id and value in it. Team ids
+must be among teams (all of them when empty) and user ids must belong to one
+of those teams.
+*/
+func ValidateTaskFilter(f *models.TaskFilter, teams []models.Team) error {
+	allowedTeams := make(map[int]bool)
+	allowedUsers := make(map[int]bool)
+	for _, t := range teams {
+		allowedTeams[t.TeamID] = true
+		for _, m := range t.Members {
+			allowedUsers[m.UserID] = true
+		}
 	}
 
-	return &enhancedTask, nil
+	if len(f.TeamIDs) == 0 {
+		for _, t := range teams {
+			f.TeamIDs = append(f.TeamIDs, t.TeamID)
+		}
+	}
+	for _, id := range f.TeamIDs {
+		if !allowedTeams[id] {
+			return fmt.Errorf("%w: you are not a member of team %d", ErrInvalidTaskFilter, id)
+		}
+	}
+
+	for _, ids := range [][]int{f.AssigneeIDs, f.CreatorIDs} {
+		for _, id := range ids {
+			if !allowedUsers[id] {
+				return fmt.Errorf("%w: user %d is not a member of your teams", ErrInvalidTaskFilter, id)
+			}
+		}
+	}
+
+	for _, status := range f.Statuses {
+		if !status.IsValid() {
+			return fmt.Errorf("%w: unknown status %q", ErrInvalidTaskFilter, status)
+		}
+	}
+	for _, priority := range f.Priorities {
+		if !priority.IsValid() {
+			return fmt.Errorf("%w: unknown priority %q", ErrInvalidTaskFilter, priority)
+		}
+	}
+
+	for _, date := range []string{f.DueAfter, f.DueBefore} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse(taskQueryDateFormat, date); err != nil {
+			return fmt.Errorf("%w: invalid date %q, expected YYYY-MM-DD", ErrInvalidTaskFilter, date)
+		}
+	}
+
+	f.Text = strings.TrimSpace(f.Text)
+	if len(f.Text) > maxTaskQueryText {
+		return fmt.Errorf("%w: text is longer than %d characters", ErrInvalidTaskFilter, maxTaskQueryText)
+	}
+
+	if f.Limit <= 0 {
+		f.Limit = defaultTaskQueryLimit
+	}
+	if f.Limit > maxTaskQueryLimit {
+		f.Limit = maxTaskQueryLimit
+	}
+	return nil
 }
@@ -0,0 +1,165 @@
+package store
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/drumilbhati/teamsync/models"
+)
+
+func TestValidateTaskSuggestion(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      models.TaskSuggestion
+		want    models.TaskSuggestion
+		wantErr bool
+	}{
+		{
+			name: "valid suggestion is trimmed",
+			in:   models.TaskSuggestion{Title: "  Fix login  ", Description: "\nSteps\n", Priority: models.TaskPriorityHigh, Status: models.TaskStatusInProgress},
+			want: models.TaskSuggestion{Title: "Fix login", Description: "Steps", Priority: models.TaskPriorityHigh, Status: models.TaskStatusInProgress},
+		},
+		{
+			name: "empty fields are left alone",
+			in:   models.TaskSuggestion{},
+			want: models.TaskSuggestion{},
+		},
+		{
+			name: "title at the limit",
+			in:   models.TaskSuggestion{Title: strings.Repeat("a", maxSuggestedTitleLength)},
+			want: models.TaskSuggestion{Title: strings.Repeat("a", maxSuggestedTitleLength)},
+		},
+		{name: "title too long", in: models.TaskSuggestion{Title: strings.Repeat("a", maxSuggestedTitleLength+1)}, wantErr: true},
+		{name: "description too long", in: models.TaskSuggestion{Description: strings.Repeat("a", maxSuggestedDescriptionLength+1)}, wantErr: true},
+		{name: "unknown priority", in: models.TaskSuggestion{Priority: "urgent"}, wantErr: true},
+		{name: "unknown status", in: models.TaskSuggestion{Status: "blocked"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.in
+			err := ValidateTaskSuggestion(&s)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidSuggestion) {
+					t.Errorf("err = %v, want %v", err, ErrInvalidSuggestion)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s != tt.want {
+				t.Errorf("got %+v, want %+v", s, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTaskFilter(t *testing.T) {
+	teams := []models.Team{
+		{TeamID: 1, Members: []models.Member{{UserID: 10}, {UserID: 11}}},
+		{TeamID: 2, Members: []models.Member{{UserID: 20}}},
+	}
+
+	tests := []struct {
+		name    string
+		in      models.TaskFilter
+		want    models.TaskFilter
+		wantErr bool
+	}{
+		{
+			name: "empty filter covers all teams with the default limit",
+			in:   models.TaskFilter{},
+			want: models.TaskFilter{TeamIDs: []int{1, 2}, Limit: defaultTaskQueryLimit},
+		},
+		{
+			name: "team subset is kept",
+			in:   models.TaskFilter{TeamIDs: []int{2}, Limit: 10},
+			want: models.TaskFilter{TeamIDs: []int{2}, Limit: 10},
+		},
+		{
+			name: "members of any team are allowed",
+			in:   models.TaskFilter{TeamIDs: []int{1}, AssigneeIDs: []int{20}, CreatorIDs: []int{11}},
+			want: models.TaskFilter{TeamIDs: []int{1}, AssigneeIDs: []int{20}, CreatorIDs: []int{11}, Limit: defaultTaskQueryLimit},
+		},
+		{
+			name: "values and dates are accepted",
+			in: models.TaskFilter{
+				Statuses:   []models.TaskStatus{models.TaskStatusTodo, models.TaskStatusDone},
+				Priorities: []models.TaskPriority{models.TaskPriorityLow},
+				DueAfter:   "2024-01-01",
+				DueBefore:  "2024-12-31",
+				Text:       "  login  ",
+			},
+			want: models.TaskFilter{
+				TeamIDs:    []int{1, 2},
+				Statuses:   []models.TaskStatus{models.TaskStatusTodo, models.TaskStatusDone},
+				Priorities: []models.TaskPriority{models.TaskPriorityLow},
+				DueAfter:   "2024-01-01",
+				DueBefore:  "2024-12-31",
+				Text:       "login",
+				Limit:      defaultTaskQueryLimit,
+			},
+		},
+		{
+			name: "limit at the maximum is kept",
+			in:   models.TaskFilter{Limit: maxTaskQueryLimit},
+			want: models.TaskFilter{TeamIDs: []int{1, 2}, Limit: maxTaskQueryLimit},
+		},
+		{
+			name: "limit above the maximum is clamped",
+			in:   models.TaskFilter{Limit: 100000},
+			want: models.TaskFilter{TeamIDs: []int{1, 2}, Limit: maxTaskQueryLimit},
+		},
+		{
+			name: "negative limit falls back to the default",
+			in:   models.TaskFilter{Limit: -5},
+			want: models.TaskFilter{TeamIDs: []int{1, 2}, Limit: defaultTaskQueryLimit},
+		},
+		{name: "team outside the user's teams", in: models.TaskFilter{TeamIDs: []int{1, 3}}, wantErr: true},
+		{name: "assignee outside the user's teams", in: models.TaskFilter{AssigneeIDs: []int{99}}, wantErr: true},
+		{name: "creator outside the user's teams", in: models.TaskFilter{CreatorIDs: []int{99}}, wantErr: true},
+		{name: "unknown status", in: models.TaskFilter{Statuses: []models.TaskStatus{"blocked"}}, wantErr: true},
+		{name: "unknown priority", in: models.TaskFilter{Priorities: []models.TaskPriority{"urgent"}}, wantErr: true},
+		{name: "invalid date", in: models.TaskFilter{DueAfter: "01/02/2024"}, wantErr: true},
+		{name: "date with a time", in: models.TaskFilter{DueBefore: "2024-01-02T00:00:00Z"}, wantErr: true},
+		{name: "text too long", in: models.TaskFilter{Text: strings.Repeat("a", maxTaskQueryText+1)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := tt.in
+			err := ValidateTaskFilter(&f, teams)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidTaskFilter) {
+					t.Errorf("err = %v, want %v", err, ErrInvalidTaskFilter)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(f, tt.want) {
+				t.Errorf("got %+v, want %+v", f, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTaskFilterWithoutTeams(t *testing.T) {
+	// A user without teams can only ever match nothing
+	f := models.TaskFilter{}
+	if err := ValidateTaskFilter(&f, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.TeamIDs) != 0 {
+		t.Errorf("team ids %v, want none", f.TeamIDs)
+	}
+
+	f = models.TaskFilter{TeamIDs: []int{1}}
+	if err := ValidateTaskFilter(&f, nil); !errors.Is(err, ErrInvalidTaskFilter) {
+		t.Errorf("err = %v, want %v", err, ErrInvalidTaskFilter)
+	}
+}
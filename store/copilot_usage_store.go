@@ -0,0 +1,173 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/drumilbhati/teamsync/models"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrCopilotUserQuotaExceeded = errors.New("daily copilot quota exceeded for this user")
+	ErrCopilotTeamQuotaExceeded = errors.New("daily copilot quota exceeded for this team")
+)
+
+// Quota counters outlive their day so a late release still finds its key
+const copilotQuotaKeyTTL = 48 * time.Hour
+
+func copilotQuotaKeys(userID, teamID int, now time.Time) (string, string) {
+	day := now.UTC().Format("2006-01-02")
+	return fmt.Sprintf("copilot:quota:user:%d:%s", userID, day),
+		fmt.Sprintf("copilot:quota:team:%d:%s", teamID, day)
+}
+
+/*
+ReserveCopilotQuota counts one copilot call against the user's and the team's
+daily limits. A limit of 0 disables that check and a teamID of 0 (a call
+not tied to one team) only counts against the user. When either limit is already
+used up nothing is counted and the matching error is returned.
+*/
+func (s *Store) ReserveCopilotQuota(userID, teamID, userLimit, teamLimit int) error {
+	ctx := context.Background()
+	userKey, teamKey := copilotQuotaKeys(userID, teamID, time.Now())
+
+	pipe := s.rdb.TxPipeline()
+	userCount := pipe.Incr(ctx, userKey)
+	pipe.Expire(ctx, userKey, copilotQuotaKeyTTL)
+	var teamCount *redis.IntCmd
+	if teamID != 0 {
+		teamCount = pipe.Incr(ctx, teamKey)
+		pipe.Expire(ctx, teamKey, copilotQuotaKeyTTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+
+	var err error
+	if userLimit > 0 && userCount.Val() > int64(userLimit) {
+		err = ErrCopilotUserQuotaExceeded
+	} else if teamCount != nil && teamLimit > 0 && teamCount.Val() > int64(teamLimit) {
+		err = ErrCopilotTeamQuotaExceeded
+	}
+
+	if err != nil {
+		s.ReleaseCopilotQuota(userID, teamID)
+		return err
+	}
+	return nil
+}
+
+// ReleaseCopilotQuota gives back a reservation for a call that was never made
+func (s *Store) ReleaseCopilotQuota(userID, teamID int) error {
+	ctx := context.Background()
+	userKey, teamKey := copilotQuotaKeys(userID, teamID, time.Now())
+
+	pipe := s.rdb.TxPipeline()
+	pipe.Decr(ctx, userKey)
+	if teamID != 0 {
+		pipe.Decr(ctx, teamKey)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// IsTeamAIEnabled reports whether the team allows copilot features. Teams in
+// the trash never do.
+func (s *Store) IsTeamAIEnabled(teamID int) (bool, error) {
+	var enabled bool
+	err := s.db.QueryRow(
+		`SELECT ai_enabled FROM teams WHERE team_id = $1 AND deleted_at IS NULL`,
+		teamID,
+	).Scan(&enabled)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return enabled, err
+}
+
+func (s *Store) SetTeamAIEnabled(teamID int, enabled bool) error {
+	_, err := s.db.Exec(
+		`UPDATE teams SET ai_enabled = $1 WHERE team_id = $2`,
+		enabled, teamID,
+	)
+	return err
+}
+
+// CopilotOutcome classifies the result of a copilot call for the audit log
+func CopilotOutcome(err error) string {
+	switch {
+	case err == nil:
+		return models.CopilotOutcomeSuccess
+	case errors.Is(err, context.DeadlineExceeded):
+		return models.CopilotOutcomeTimeout
+	case errors.Is(err, ErrInvalidSuggestion):
+		return models.CopilotOutcomeInvalid
+	default:
+		return models.CopilotOutcomeError
+	}
+}
+
+func (s *Store) CreateCopilotCall(c *models.CopilotCall) error {
+	err := s.db.QueryRow(
+		`INSERT INTO copilot_calls (user_id, team_id, task_id, feature, model, prompt_tokens, completion_tokens, latency_ms, outcome, error)
+		VALUES ($1, NULLIF($2, 0), NULLIF($3, 0), $4, $5, $6, $7, $8, $9, NULLIF($10, ''))
+		RETURNING call_id, created_at`,
+		c.UserID, c.TeamID, c.TaskID, c.Feature, c.Model, c.PromptTokens, c.CompletionTokens, c.LatencyMs, c.Outcome, c.Error,
+	).Scan(&c.CallID, &c.CreatedAt)
+
+	return err
+}
+
+// GetCopilotUsage aggregates audited calls per day, team, user, feature and model
+func (s *Store) GetCopilotUsage(f models.CopilotUsageFilter) ([]models.CopilotUsage, error) {
+	conditions := []string{"c.created_at >= $1", "c.created_at < $2"}
+	args := []interface{}{f.From, f.To}
+
+	if f.TeamID != 0 {
+		args = append(args, f.TeamID)
+		conditions = append(conditions, fmt.Sprintf("c.team_id = $%d", len(args)))
+	}
+	if f.UserID != 0 {
+		args = append(args, f.UserID)
+		conditions = append(conditions, fmt.Sprintf("c.user_id = $%d", len(args)))
+	}
+
+	query := `
+		SELECT to_char(date_trunc('day', c.created_at AT TIME ZONE 'UTC'), 'YYYY-MM-DD') AS day,
+			COALESCE(c.team_id, 0), COALESCE(t.team_name, ''),
+			COALESCE(c.user_id, 0), COALESCE(u.user_name, ''),
+			c.feature, c.model,
+			COUNT(*),
+			COUNT(*) FILTER (WHERE c.outcome <> 'success'),
+			COALESCE(SUM(c.prompt_tokens), 0),
+			COALESCE(SUM(c.completion_tokens), 0),
+			COALESCE(AVG(c.latency_ms), 0)::int
+		FROM copilot_calls c
+		LEFT JOIN teams t ON c.team_id = t.team_id
+		LEFT JOIN users u ON c.user_id = u.user_id
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		GROUP BY 1, 2, 3, 4, 5, 6, 7
+		ORDER BY 1 DESC, 2, 4, 6, 7`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usage := []models.CopilotUsage{}
+	for rows.Next() {
+		var u models.CopilotUsage
+		if err := rows.Scan(&u.Day, &u.TeamID, &u.TeamName, &u.UserID, &u.UserName, &u.Feature, &u.Model,
+			&u.Calls, &u.Failures, &u.PromptTokens, &u.CompletionTokens, &u.AvgLatencyMs); err != nil {
+			return nil, err
+		}
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
@@ -0,0 +1,232 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/drumilbhati/teamsync/ai"
+	"github.com/drumilbhati/teamsync/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// Minimum pg_trgm similarity of the titles for a task to be a candidate
+	trigramDuplicateThreshold = 0.45
+
+	// Minimum cosine similarity of the embeddings in embedding mode
+	embeddingDuplicateThreshold = 0.85
+
+	// Embedding mode compares against the team's most recently created tasks
+	maxEmbeddingComparisons = 200
+	embeddingCacheTTL       = 7 * 24 * time.Hour
+)
+
+/*
+FindSimilarTasks returns tasks of the team whose title is similar to title by
+trigram similarity, best match first. A matching description raises the score.
+*/
+func (s *Store) FindSimilarTasks(teamID int, title, description string, excludeTaskID int, limit int) ([]models.DuplicateCandidate, error) {
+	rows, err := s.db.Query(
+		`SELECT task_id, title, status, score
+		FROM (
+			SELECT t.task_id, t.title, t.status,
+				CASE WHEN $3 = '' OR t.description IS NULL OR t.description = ''
+					THEN similarity(t.title, $2)
+					ELSE 0.75 * similarity(t.title, $2) + 0.25 * similarity(t.description, $3)
+				END AS score,
+				similarity(t.title, $2) AS title_score
+			FROM tasks t
+			WHERE t.team_id = $1 AND t.task_id <> $4 AND t.title % $2 AND `+liveTaskCondition+`
+		) candidates
+		WHERE title_score >= $5
+		ORDER BY score DESC
+		LIMIT $6`,
+		teamID, title, description, excludeTaskID, trigramDuplicateThreshold, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candidates := []models.DuplicateCandidate{}
+	for rows.Next() {
+		var c models.DuplicateCandidate
+		if err := rows.Scan(&c.TaskID, &c.Title, &c.Status, &c.Score); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, rows.Err()
+}
+
+/*
+FindSimilarTasksByEmbedding compares the task's embedding with those of the
+team's recent tasks through the provider. Embeddings are cached in Redis by
+model and content so each task is only embedded once.
+*/
+func (s *Store) FindSimilarTasksByEmbedding(ctx context.Context, provider ai.Provider, task *models.Task, limit int) ([]models.DuplicateCandidate, error) {
+	rows, err := s.db.Query(
+		`SELECT t.task_id, t.title, COALESCE(t.description, ''), t.status
+		FROM tasks t
+		WHERE t.team_id = $1 AND t.task_id <> $2 AND `+liveTaskCondition+`
+		ORDER BY t.created_at DESC
+		LIMIT $3`,
+		task.TeamID, task.TaskID, maxEmbeddingComparisons,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var others []models.DuplicateCandidate
+	texts := []string{duplicateText(task.Title, task.Description.String)}
+	for rows.Next() {
+		var c models.DuplicateCandidate
+		var description string
+		if err := rows.Scan(&c.TaskID, &c.Title, &description, &c.Status); err != nil {
+			return nil, err
+		}
+		others = append(others, c)
+		texts = append(texts, duplicateText(c.Title, description))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	candidates := []models.DuplicateCandidate{}
+	if len(others) == 0 {
+		return candidates, nil
+	}
+
+	vectors, err := s.embedCached(ctx, provider, texts)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, c := range others {
+		c.Score = ai.CosineSimilarity(vectors[0], vectors[i+1])
+		if c.Score >= embeddingDuplicateThreshold {
+			candidates = append(candidates, c)
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Score > candidates[j].Score })
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	return candidates, nil
+}
+
+func duplicateText(title, description string) string {
+	if description == "" {
+		return title
+	}
+	return title + "\n\n" + description
+}
+
+// embedCached embeds texts, reusing vectors cached for identical content
+func (s *Store) embedCached(ctx context.Context, provider ai.Provider, texts []string) ([][]float32, error) {
+	keys := make([]string, len(texts))
+	for i, text := range texts {
+		sum := sha256.Sum256([]byte(text))
+		keys[i] = fmt.Sprintf("embedding:%s:%s", provider.Name(), hex.EncodeToString(sum[:]))
+	}
+
+	vectors := make([][]float32, len(texts))
+	cached, err := s.rdb.MGet(ctx, keys...).Result()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	var missing []int
+	for i, v := range cached {
+		if str, ok := v.(string); ok && json.Unmarshal([]byte(str), &vectors[i]) == nil {
+			continue
+		}
+		missing = append(missing, i)
+	}
+
+	if len(missing) == 0 {
+		return vectors, nil
+	}
+
+	missingTexts := make([]string, len(missing))
+	for j, i := range missing {
+		missingTexts[j] = texts[i]
+	}
+
+	embedded, err := ai.Embed(ctx, provider, missingTexts)
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := s.rdb.Pipeline()
+	for j, i := range missing {
+		vectors[i] = embedded[j]
+		if b, err := json.Marshal(embedded[j]); err == nil {
+			pipe.Set(ctx, keys[i], b, embeddingCacheTTL)
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	return vectors, nil
+}
+
+/*
+MergeTasks folds duplicate into survivor in one transaction: the duplicate's
+comments, attachments and commit and pull request links move to the survivor
+and the duplicate is moved to the trash as deleted by deletedBy. Links the
+survivor already has stay with the duplicate. The duplicate's status history
+stays with it in the trash, as it describes another task's progress, so the
+survivor's analytics are unchanged. The schema has no task watcher table yet;
+watchers belong here once it exists.
+*/
+func (s *Store) MergeTasks(survivorID, duplicateID, deletedBy int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		`UPDATE comments SET task_id = $1 WHERE task_id = $2`,
+		survivorID, duplicateID,
+	); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(
+		`UPDATE attachments SET task_id = $1 WHERE task_id = $2`,
+		survivorID, duplicateID,
+	); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(
+		`UPDATE task_links l SET task_id = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE l.task_id = $2 AND NOT EXISTS (
+			SELECT 1 FROM task_links s
+			WHERE s.task_id = $1 AND s.kind = l.kind
+				AND s.repository = l.repository AND s.external_id = l.external_id
+		)`,
+		survivorID, duplicateID,
+	); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(
+		`UPDATE tasks SET deleted_at = CURRENT_TIMESTAMP, deleted_by = $2
+		WHERE task_id = $1 AND deleted_at IS NULL`,
+		duplicateID, deletedBy,
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,355 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/drumilbhati/teamsync/models"
+)
+
+// GetTeamExport collects a team's members, tasks and comments for export
+func (s *Store) GetTeamExport(teamID int) (*models.TeamExport, error) {
+	team, err := s.GetTeamByID(teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &models.TeamExport{
+		Version:    models.TeamExportVersion,
+		ExportedAt: time.Now().UTC(),
+		Team:       *team,
+		Members:    []models.ExportMember{},
+		Tasks:      []models.ExportTask{},
+	}
+
+	rows, err := s.db.Query(
+		`SELECT u.user_id, u.user_name, u.email, m.role, m.created_at
+		FROM members m
+		JOIN users u ON m.user_id = u.user_id
+		WHERE m.team_id = $1
+		ORDER BY m.created_at`,
+		teamID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m models.ExportMember
+		if err := rows.Scan(&m.UserID, &m.UserName, &m.Email, &m.Role, &m.JoinedAt); err != nil {
+			return nil, err
+		}
+		export.Members = append(export.Members, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	taskRows, err := s.db.Query(
+		`SELECT t.task_id, t.title, COALESCE(t.description, ''), t.status, t.priority,
+			COALESCE(a.email, ''), COALESCE(a.user_name, ''), COALESCE(c.email, ''),
+			t.due_date, t.created_at, t.updated_at
+		FROM tasks t
+		LEFT JOIN users a ON t.assignee_id = a.user_id
+		LEFT JOIN users c ON t.creator_id = c.user_id
+		WHERE t.team_id = $1 AND `+liveTaskCondition+`
+		ORDER BY t.task_id`,
+		teamID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer taskRows.Close()
+
+	index := map[int]int{}
+	for taskRows.Next() {
+		var t models.ExportTask
+		var dueDate, updatedAt sql.NullTime
+		if err := taskRows.Scan(&t.TaskID, &t.Title, &t.Description, &t.Status, &t.Priority,
+			&t.AssigneeEmail, &t.AssigneeName, &t.CreatorEmail, &dueDate, &t.CreatedAt, &updatedAt); err != nil {
+			return nil, err
+		}
+		if dueDate.Valid {
+			t.DueDate = &dueDate.Time
+		}
+		if updatedAt.Valid {
+			t.UpdatedAt = &updatedAt.Time
+		}
+		t.Comments = []models.ExportComment{}
+		index[t.TaskID] = len(export.Tasks)
+		export.Tasks = append(export.Tasks, t)
+	}
+	if err := taskRows.Err(); err != nil {
+		return nil, err
+	}
+
+	commentRows, err := s.db.Query(
+		`SELECT c.comment_id, c.task_id, COALESCE(u.email, ''), COALESCE(c.user_name, u.user_name, ''), c.content, c.created_at
+		FROM comments c
+		JOIN tasks t ON c.task_id = t.task_id
+		LEFT JOIN users u ON c.user_id = u.user_id
+		WHERE t.team_id = $1 AND `+liveTaskCondition+` AND c.deleted_at IS NULL
+		ORDER BY c.created_at, c.comment_id`,
+		teamID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer commentRows.Close()
+
+	for commentRows.Next() {
+		var c models.ExportComment
+		if err := commentRows.Scan(&c.CommentID, &c.TaskID, &c.AuthorEmail, &c.AuthorName, &c.Content, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		if i, ok := index[c.TaskID]; ok {
+			export.Tasks[i].Comments = append(export.Tasks[i].Comments, c)
+		}
+	}
+	return export, commentRows.Err()
+}
+
+// tasks.title is a VARCHAR(255)
+const maxTaskTitleLength = 255
+
+type importUser struct {
+	id   int
+	name string
+}
+
+// teamUsersByEmail maps the lower-cased email of every team member to the user
+func (s *Store) teamUsersByEmail(teamID int) (map[string]importUser, error) {
+	rows, err := s.db.Query(
+		`SELECT u.user_id, u.user_name, u.email
+		FROM members m
+		JOIN users u ON m.user_id = u.user_id
+		WHERE m.team_id = $1`,
+		teamID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := map[string]importUser{}
+	for rows.Next() {
+		var u importUser
+		var email string
+		if err := rows.Scan(&u.id, &u.name, &email); err != nil {
+			return nil, err
+		}
+		users[strings.ToLower(email)] = u
+	}
+	return users, rows.Err()
+}
+
+// GetTeamMemberEmails returns the lower-cased emails of the team's members
+func (s *Store) GetTeamMemberEmails(teamID int) (map[string]bool, error) {
+	users, err := s.teamUsersByEmail(teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	emails := make(map[string]bool, len(users))
+	for email := range users {
+		emails[email] = true
+	}
+	return emails, nil
+}
+
+// parseImportTime accepts RFC3339 timestamps and YYYY-MM-DD dates (midnight UTC)
+func parseImportTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", v)
+}
+
+// normalizeImportEnum lower-cases a status or priority and accepts "In Progress" and "in-progress" for in_progress
+func normalizeImportEnum(v string) string {
+	v = strings.ToLower(strings.TrimSpace(v))
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(v)
+}
+
+type importedTask struct {
+	task     models.Task
+	comments []models.Comment
+}
+
+/*
+ImportTasks validates every row and, unless dryRun is set or a row is
+invalid, creates the tasks and their comments in one transaction, so either
+the whole file is imported or nothing is. Assignees and comment authors are
+matched to team members by email. Comments by people who are not members
+are attributed to the importer under the original author's name.
+*/
+func (s *Store) ImportTasks(teamID, importerID int, rows []models.ImportTask, dryRun bool) (*models.ImportResult, error) {
+	return s.ImportTasksWithProgress(teamID, importerID, rows, dryRun, nil)
+}
+
+// importProgressInterval is how many tasks are written between progress reports
+const importProgressInterval = 25
+
+// ImportTasksWithProgress is ImportTasks reporting the number of tasks written so far to progress
+func (s *Store) ImportTasksWithProgress(teamID, importerID int, rows []models.ImportTask, dryRun bool, progress func(done int)) (*models.ImportResult, error) {
+	result := &models.ImportResult{
+		DryRun: dryRun,
+		Rows:   len(rows),
+		Errors: []models.ImportRowError{},
+	}
+
+	users, err := s.teamUsersByEmail(teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	importerName := ""
+	for _, u := range users {
+		if u.id == importerID {
+			importerName = u.name
+		}
+	}
+
+	fail := func(row int, field, format string, args ...interface{}) {
+		result.Errors = append(result.Errors, models.ImportRowError{
+			Row:     row,
+			Field:   field,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	imported := make([]importedTask, 0, len(rows))
+	for i, r := range rows {
+		row := r.Row
+		if row == 0 {
+			row = i + 1
+		}
+
+		t := models.Task{
+			TeamID:    teamID,
+			CreatorID: importerID,
+			Title:     strings.TrimSpace(r.Title),
+			Status:    models.TaskStatusTodo,
+			Priority:  models.TaskPriorityMedium,
+		}
+
+		if t.Title == "" {
+			fail(row, "title", "title is required")
+		} else if utf8.RuneCountInString(t.Title) > maxTaskTitleLength {
+			fail(row, "title", "title is longer than %d characters", maxTaskTitleLength)
+		}
+
+		if d := strings.TrimSpace(r.Description); d != "" {
+			t.Description = sql.NullString{String: d, Valid: true}
+		}
+
+		if v := normalizeImportEnum(r.Status); v != "" {
+			t.Status = models.TaskStatus(v)
+			if !t.Status.IsValid() {
+				fail(row, "status", "invalid status %q", r.Status)
+			}
+		}
+
+		if v := normalizeImportEnum(r.Priority); v != "" {
+			t.Priority = models.TaskPriority(v)
+			if !t.Priority.IsValid() {
+				fail(row, "priority", "invalid priority %q", r.Priority)
+			}
+		}
+
+		if email := strings.TrimSpace(r.AssigneeEmail); email != "" {
+			if u, ok := users[strings.ToLower(email)]; ok {
+				t.AssigneeID = sql.NullInt64{Int64: int64(u.id), Valid: true}
+			} else {
+				fail(row, "assignee_email", "no team member with email %s", email)
+			}
+		}
+
+		if v := strings.TrimSpace(r.DueDate); v != "" {
+			due, err := parseImportTime(v)
+			if err != nil {
+				fail(row, "due_date", "invalid due date %q, expected RFC3339 or YYYY-MM-DD", v)
+			}
+			t.DueDate = sql.NullTime{Time: due, Valid: err == nil}
+		}
+
+		it := importedTask{task: t}
+		for j, rc := range r.Comments {
+			field := fmt.Sprintf("comments[%d]", j)
+			c := models.Comment{Content: strings.TrimSpace(rc.Content)}
+			if c.Content == "" {
+				fail(row, field, "comment content is required")
+			}
+
+			if v := strings.TrimSpace(rc.CreatedAt); v != "" {
+				createdAt, err := parseImportTime(v)
+				if err != nil {
+					fail(row, field, "invalid created_at %q", v)
+				}
+				c.CreatedAt = createdAt
+			}
+
+			if u, ok := users[strings.ToLower(strings.TrimSpace(rc.AuthorEmail))]; ok {
+				c.UserID, c.UserName = u.id, u.name
+			} else {
+				c.UserID, c.UserName = importerID, importerName
+				if name := strings.TrimSpace(rc.AuthorName); name != "" {
+					c.UserName = name
+				} else if rc.AuthorEmail != "" {
+					c.UserName = rc.AuthorEmail
+				}
+			}
+			it.comments = append(it.comments, c)
+		}
+		imported = append(imported, it)
+	}
+
+	if dryRun || len(result.Errors) > 0 {
+		return result, nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	for _, it := range imported {
+		t := it.task
+		err := tx.QueryRow(
+			`INSERT INTO tasks (team_id, creator_id, assignee_id, title, description, status, priority, due_date)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING task_id`,
+			t.TeamID, t.CreatorID, t.AssigneeID, t.Title, t.Description, t.Status, t.Priority, t.DueDate,
+		).Scan(&t.TaskID)
+		if err != nil {
+			return nil, err
+		}
+		result.CreatedTasks++
+
+		for _, c := range it.comments {
+			createdAt := sql.NullTime{Time: c.CreatedAt, Valid: !c.CreatedAt.IsZero()}
+			if _, err := tx.Exec(
+				`INSERT INTO comments (task_id, user_id, user_name, content, created_at)
+				VALUES ($1, $2, $3, $4, COALESCE($5, CURRENT_TIMESTAMP))`,
+				t.TaskID, c.UserID, c.UserName, c.Content, createdAt,
+			); err != nil {
+				return nil, err
+			}
+			result.CreatedComments++
+		}
+
+		if progress != nil && result.CreatedTasks%importProgressInterval == 0 {
+			progress(result.CreatedTasks)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,131 @@
+package store
+
+import (
+	"regexp"
+	"strconv"
+
+	"github.com/drumilbhati/teamsync/models"
+)
+
+const gitIntegrationColumns = `integration_id, team_id, provider, secret, auto_transition, COALESCE(created_by, 0), created_at`
+
+func scanGitIntegration(row interface{ Scan(...interface{}) error }, g *models.GitIntegration) error {
+	return row.Scan(&g.IntegrationID, &g.TeamID, &g.Provider, &g.Secret, &g.AutoTransition, &g.CreatedBy, &g.CreatedAt)
+}
+
+func (s *Store) CreateGitIntegration(g *models.GitIntegration) error {
+	return s.db.QueryRow(
+		`INSERT INTO git_integrations (team_id, provider, secret, auto_transition, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING integration_id, created_at`,
+		g.TeamID, g.Provider, g.Secret, g.AutoTransition, g.CreatedBy,
+	).Scan(&g.IntegrationID, &g.CreatedAt)
+}
+
+func (s *Store) GetGitIntegrationByID(integrationID int) (*models.GitIntegration, error) {
+	var g models.GitIntegration
+	row := s.db.QueryRow(
+		"SELECT "+gitIntegrationColumns+" FROM git_integrations WHERE integration_id = $1",
+		integrationID,
+	)
+	if err := scanGitIntegration(row, &g); err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+func (s *Store) GetGitIntegrationsByTeamID(teamID int) ([]models.GitIntegration, error) {
+	rows, err := s.db.Query(
+		"SELECT "+gitIntegrationColumns+" FROM git_integrations WHERE team_id = $1 ORDER BY integration_id",
+		teamID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	integrations := []models.GitIntegration{}
+	for rows.Next() {
+		var g models.GitIntegration
+		if err := scanGitIntegration(rows, &g); err != nil {
+			return nil, err
+		}
+		integrations = append(integrations, g)
+	}
+	return integrations, rows.Err()
+}
+
+func (s *Store) DeleteGitIntegrationByID(integrationID int) error {
+	_, err := s.db.Exec("DELETE FROM git_integrations WHERE integration_id = $1", integrationID)
+	return err
+}
+
+/*
+UpsertTaskLink records a commit or pull request on a task. A link that
+already exists is refreshed, e.g. when a pull request is merged; created
+reports whether the link is new.
+*/
+func (s *Store) UpsertTaskLink(l *models.TaskLink) (created bool, err error) {
+	err = s.db.QueryRow(
+		`INSERT INTO task_links (task_id, kind, provider, repository, external_id, url, title, author, state)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''))
+		ON CONFLICT (task_id, kind, repository, external_id) DO UPDATE
+		SET url = EXCLUDED.url, title = EXCLUDED.title, state = EXCLUDED.state, updated_at = CURRENT_TIMESTAMP
+		RETURNING link_id, created_at, updated_at, (xmax = 0)`,
+		l.TaskID, l.Kind, l.Provider, l.Repository, l.ExternalID, l.URL, l.Title, l.Author, l.State,
+	).Scan(&l.LinkID, &l.CreatedAt, &l.UpdatedAt, &created)
+	return created, err
+}
+
+func (s *Store) GetTaskLinksByTaskID(taskID int) ([]models.TaskLink, error) {
+	rows, err := s.db.Query(
+		`SELECT link_id, task_id, kind, provider, repository, external_id, COALESCE(url, ''),
+			COALESCE(title, ''), COALESCE(author, ''), COALESCE(state, ''), created_at, updated_at
+		FROM task_links
+		WHERE task_id = $1
+		ORDER BY created_at DESC`,
+		taskID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []models.TaskLink{}
+	for rows.Next() {
+		var l models.TaskLink
+		if err := rows.Scan(&l.LinkID, &l.TaskID, &l.Kind, &l.Provider, &l.Repository, &l.ExternalID, &l.URL,
+			&l.Title, &l.Author, &l.State, &l.CreatedAt, &l.UpdatedAt); err != nil {
+			return nil, err
+		}
+		links = append(links, l)
+	}
+	return links, rows.Err()
+}
+
+var (
+	// TS-123 anywhere, e.g. in a commit message or a branch name like TS-123-login-form
+	taskKeyPattern = regexp.MustCompile(`(?i)\bTS-(\d+)\b`)
+
+	// GitHub-style closing keywords: "fixes #123", "Closes #123", "resolved #123"
+	closingKeywordPattern = regexp.MustCompile(`(?i)\b(?:close[sd]?|fix(?:e[sd])?|resolve[sd]?):?\s+#(\d+)\b`)
+)
+
+// ParseTaskReferences returns the distinct task IDs referenced in the texts, in order of appearance
+func ParseTaskReferences(texts ...string) []int {
+	var ids []int
+	seen := map[int]bool{}
+	for _, text := range texts {
+		for _, pattern := range []*regexp.Regexp{taskKeyPattern, closingKeywordPattern} {
+			for _, m := range pattern.FindAllStringSubmatch(text, -1) {
+				id, err := strconv.Atoi(m[1])
+				if err != nil || id <= 0 || seen[id] {
+					continue
+				}
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
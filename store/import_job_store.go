@@ -0,0 +1,93 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/drumilbhati/teamsync/models"
+)
+
+func (s *Store) CreateImportJob(j *models.ImportJob) error {
+	userMap, err := json.Marshal(j.UserMap)
+	if err != nil {
+		return err
+	}
+
+	j.Status = models.ImportJobQueued
+	return s.db.QueryRow(
+		`INSERT INTO import_jobs (team_id, requested_by, source, storage_key, user_map, dry_run, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING job_id, created_at`,
+		j.TeamID, j.RequestedBy, j.Source, j.StorageKey, userMap, j.DryRun, j.Status,
+	).Scan(&j.JobID, &j.CreatedAt)
+}
+
+func (s *Store) GetImportJobByID(jobID int) (*models.ImportJob, error) {
+	var j models.ImportJob
+	var userMap []byte
+	var report []byte
+	var finishedAt sql.NullTime
+
+	err := s.db.QueryRow(
+		`SELECT job_id, team_id, COALESCE(requested_by, 0), source, storage_key, user_map, dry_run, status,
+			total, processed, report, COALESCE(error, ''), created_at, finished_at
+		FROM import_jobs
+		WHERE job_id = $1`,
+		jobID,
+	).Scan(&j.JobID, &j.TeamID, &j.RequestedBy, &j.Source, &j.StorageKey, &userMap, &j.DryRun, &j.Status,
+		&j.Total, &j.Processed, &report, &j.Error, &j.CreatedAt, &finishedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(userMap, &j.UserMap); err != nil {
+		return nil, err
+	}
+	if report != nil {
+		j.Report = &models.ImportReport{}
+		if err := json.Unmarshal(report, j.Report); err != nil {
+			return nil, err
+		}
+	}
+	if finishedAt.Valid {
+		j.FinishedAt = &finishedAt.Time
+	}
+	return &j, nil
+}
+
+// StartImportJob marks the job running with the number of tasks it will import
+func (s *Store) StartImportJob(jobID, total int) error {
+	_, err := s.db.Exec(
+		`UPDATE import_jobs SET status = $1, total = $2, processed = 0 WHERE job_id = $3`,
+		models.ImportJobRunning, total, jobID,
+	)
+	return err
+}
+
+func (s *Store) SetImportJobProgress(jobID, processed int) error {
+	_, err := s.db.Exec(
+		`UPDATE import_jobs SET processed = $1 WHERE job_id = $2`,
+		processed, jobID,
+	)
+	return err
+}
+
+// FinishImportJob stores the job's final status, report and error, if any
+func (s *Store) FinishImportJob(jobID int, status string, report *models.ImportReport, errMsg string) error {
+	var reportJSON []byte
+	if report != nil {
+		var err error
+		if reportJSON, err = json.Marshal(report); err != nil {
+			return err
+		}
+	}
+
+	_, err := s.db.Exec(
+		`UPDATE import_jobs
+		SET status = $1, report = $2, error = NULLIF($3, ''), finished_at = CURRENT_TIMESTAMP,
+			processed = CASE WHEN $1 = 'completed' THEN total ELSE processed END
+		WHERE job_id = $4`,
+		status, reportJSON, errMsg, jobID,
+	)
+	return err
+}
@@ -97,9 +97,11 @@ func (s *Store) IsTeamMember(userID int, teamID int) (bool, error) {
 	var exists bool
 	err := s.db.QueryRow(
 		`SELECT EXISTS(
-			SELECT 1 FROM members WHERE user_id = $1 AND team_id = $2
-		) OR EXISTS(
-			SELECT 1 FROM teams WHERE team_leader_id = $1 AND team_id = $2
+			SELECT 1 FROM teams t
+			WHERE t.team_id = $2 AND t.deleted_at IS NULL AND (
+				t.team_leader_id = $1
+				OR EXISTS(SELECT 1 FROM members m WHERE m.user_id = $1 AND m.team_id = t.team_id)
+			)
 		)`,
 		userID, teamID,
 	).Scan(&exists)
@@ -1,27 +1,48 @@
 package store
 
 import (
+	"time"
+
 	"github.com/drumilbhati/teamsync/models"
 )
 
+// CreateMessage saves a chat message, posting to the team's default channel
+// when no channel is given
 func (s *Store) CreateMessage(msg *models.Message) error {
+	if msg.ChannelID == 0 {
+		channelID, err := s.GetDefaultChannelID(msg.TeamID)
+		if err != nil {
+			return err
+		}
+		msg.ChannelID = channelID
+	}
+
 	err := s.db.QueryRow(
-		`INSERT INTO messages (team_id, user_id, user_name, content)
-		VALUES ($1, $2, $3, $4)
+		`INSERT INTO messages (team_id, channel_id, user_id, user_name, content)
+		VALUES ($1, $2, $3, $4, $5)
 		RETURNING message_id, created_at`,
-		msg.TeamID, msg.UserID, msg.UserName, msg.Content,
+		msg.TeamID, msg.ChannelID, msg.UserID, msg.UserName, msg.Content,
 	).Scan(&msg.MessageID, &msg.CreatedAt)
 
 	return err
 }
 
+// GetMessagesByTeamID returns the history of the team's default channel
 func (s *Store) GetMessagesByTeamID(teamID int) ([]models.Message, error) {
+	channelID, err := s.GetDefaultChannelID(teamID)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetMessagesByChannelID(channelID)
+}
+
+func (s *Store) GetMessagesByChannelID(channelID int) ([]models.Message, error) {
 	rows, err := s.db.Query(
-		`SELECT message_id, team_id, user_id, user_name, content, created_at
+		`SELECT message_id, team_id, channel_id, user_id, user_name, content, created_at
 		FROM messages
-		WHERE team_id = $1
+		WHERE channel_id = $1
 		ORDER BY created_at ASC`,
-		teamID,
+		channelID,
 	)
 	if err != nil {
 		return nil, err
@@ -31,10 +52,54 @@ func (s *Store) GetMessagesByTeamID(teamID int) ([]models.Message, error) {
 	var messages []models.Message
 	for rows.Next() {
 		var msg models.Message
-		if err := rows.Scan(&msg.MessageID, &msg.TeamID, &msg.UserID, &msg.UserName, &msg.Content, &msg.CreatedAt); err != nil {
+		if err := rows.Scan(&msg.MessageID, &msg.TeamID, &msg.ChannelID, &msg.UserID, &msg.UserName, &msg.Content, &msg.CreatedAt); err != nil {
 			return nil, err
 		}
 		messages = append(messages, msg)
 	}
 	return messages, nil
 }
+
+// GetMessagesByChannelIDSince returns the latest limit messages posted after since, oldest first
+func (s *Store) GetMessagesByChannelIDSince(channelID int, since time.Time, limit int) ([]models.Message, error) {
+	rows, err := s.db.Query(
+		`SELECT message_id, team_id, channel_id, user_id, user_name, content, created_at
+		FROM (
+			SELECT message_id, team_id, channel_id, user_id, user_name, content, created_at
+			FROM messages
+			WHERE channel_id = $1 AND created_at > $2
+			ORDER BY created_at DESC
+			LIMIT $3
+		) recent
+		ORDER BY created_at ASC`,
+		channelID, since, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []models.Message
+	for rows.Next() {
+		var msg models.Message
+		if err := rows.Scan(&msg.MessageID, &msg.TeamID, &msg.ChannelID, &msg.UserID, &msg.UserName, &msg.Content, &msg.CreatedAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
+
+func (s *Store) GetMessageByID(messageID int) (*models.Message, error) {
+	var msg models.Message
+	err := s.db.QueryRow(
+		`SELECT message_id, team_id, channel_id, user_id, user_name, content, created_at
+		FROM messages
+		WHERE message_id = $1`,
+		messageID,
+	).Scan(&msg.MessageID, &msg.TeamID, &msg.ChannelID, &msg.UserID, &msg.UserName, &msg.Content, &msg.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
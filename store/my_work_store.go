@@ -0,0 +1,86 @@
+package store
+
+import (
+	"time"
+
+	"github.com/drumilbhati/teamsync/models"
+	"github.com/lib/pq"
+)
+
+/*
+GetRecentMentions returns the comments and channel messages of the given
+teams posted since the cutoff that mention the user as @ followed by their
+user name, newest first. The user's own posts, trashed comments and channels
+the user cannot read are left out.
+*/
+func (s *Store) GetRecentMentions(userID int, teamIDs []int, since time.Time, limit int) ([]models.Mention, error) {
+	rows, err := s.db.Query(
+		`WITH me AS (
+			SELECT '@' || lower(user_name) AS handle FROM users WHERE user_id = $1
+		)
+		SELECT 'comment', cm.comment_id, t.team_id, t.task_id, t.title, 0,
+			cm.user_id, COALESCE(cm.user_name, ''), cm.content, cm.created_at
+		FROM comments cm
+		JOIN tasks t ON cm.task_id = t.task_id
+		CROSS JOIN me
+		WHERE t.team_id = ANY($2) AND `+liveTaskCondition+`
+			AND cm.deleted_at IS NULL AND cm.user_id <> $1 AND cm.created_at >= $3
+			AND position(me.handle IN lower(cm.content)) > 0
+		UNION ALL
+		SELECT 'message', msg.message_id, c.team_id, 0, '', c.channel_id,
+			msg.user_id, msg.user_name, msg.content, msg.created_at
+		FROM messages msg
+		JOIN channels c ON msg.channel_id = c.channel_id
+		CROSS JOIN me
+		WHERE c.team_id = ANY($2) AND `+channelAccessCondition+`
+			AND msg.user_id <> $1 AND msg.created_at >= $3
+			AND position(me.handle IN lower(msg.content)) > 0
+		ORDER BY 10 DESC
+		LIMIT $4`,
+		userID, pq.Array(teamIDs), since, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mentions := []models.Mention{}
+	for rows.Next() {
+		var m models.Mention
+		if err := rows.Scan(&m.Source, &m.ID, &m.TeamID, &m.TaskID, &m.TaskTitle, &m.ChannelID,
+			&m.AuthorID, &m.AuthorName, &m.Content, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		mentions = append(mentions, m)
+	}
+	return mentions, rows.Err()
+}
+
+// GetRecentCommentsOnUserTasks returns others' comments posted since the cutoff on tasks the user created or is assigned, newest first
+func (s *Store) GetRecentCommentsOnUserTasks(userID int, teamIDs []int, since time.Time, limit int) ([]models.WorkComment, error) {
+	rows, err := s.db.Query(
+		`SELECT cm.comment_id, cm.task_id, cm.user_id, COALESCE(cm.user_name, ''), cm.content, cm.created_at, t.team_id, t.title
+		FROM comments cm
+		JOIN tasks t ON cm.task_id = t.task_id
+		WHERE t.team_id = ANY($2) AND `+liveTaskCondition+`
+			AND (t.assignee_id = $1 OR t.creator_id = $1)
+			AND cm.deleted_at IS NULL AND cm.user_id <> $1 AND cm.created_at >= $3
+		ORDER BY cm.created_at DESC
+		LIMIT $4`,
+		userID, pq.Array(teamIDs), since, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []models.WorkComment{}
+	for rows.Next() {
+		var c models.WorkComment
+		if err := rows.Scan(&c.CommentID, &c.TaskID, &c.UserID, &c.UserName, &c.Content, &c.CreatedAt, &c.TeamID, &c.TaskTitle); err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+	return comments, rows.Err()
+}
@@ -0,0 +1,120 @@
+package store
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/drumilbhati/teamsync/models"
+	"github.com/lib/pq"
+)
+
+// ts_headline does not escape the text it highlights, so matches are wrapped
+// in control characters and turned into <mark> tags after escaping
+const (
+	highlightStart = "\x01"
+	highlightStop  = "\x02"
+)
+
+var headlineOptions = fmt.Sprintf("StartSel=%s, StopSel=%s, MaxWords=35, MinWords=15, MaxFragments=2", highlightStart, highlightStop)
+
+// Each source selects the same columns so they can be combined with UNION ALL.
+// $1 is the requester (used for private channel access), $2 the tsquery
+// and $3 the team ids the requester belongs to.
+var searchSources = map[string]string{
+	models.SearchTypeMessage: `
+		SELECT 'message' AS type, m.message_id AS id, m.team_id, m.channel_id, 0 AS task_id,
+			m.user_id AS author_id, m.user_name AS author_name, '' AS title,
+			ts_headline('english', m.content, q.query, '` + headlineOptions + `') AS snippet,
+			ts_rank(m.search_vector, q.query) AS rank, m.created_at
+		FROM messages m
+		JOIN channels c ON c.channel_id = m.channel_id, q
+		WHERE m.search_vector @@ q.query AND m.team_id = ANY($3) AND ` + channelAccessCondition,
+	models.SearchTypeComment: `
+		SELECT 'comment' AS type, cm.comment_id AS id, t.team_id, 0 AS channel_id, cm.task_id,
+			cm.user_id AS author_id, COALESCE(cm.user_name, '') AS author_name, t.title,
+			ts_headline('english', cm.content, q.query, '` + headlineOptions + `') AS snippet,
+			ts_rank(cm.search_vector, q.query) AS rank, cm.created_at
+		FROM comments cm
+		JOIN tasks t ON t.task_id = cm.task_id, q
+		WHERE cm.search_vector @@ q.query AND t.team_id = ANY($3) AND cm.deleted_at IS NULL AND ` + liveTaskCondition,
+	models.SearchTypeTask: `
+		SELECT 'task' AS type, t.task_id AS id, t.team_id, 0 AS channel_id, t.task_id,
+			COALESCE(t.creator_id, 0) AS author_id, COALESCE(u.user_name, '') AS author_name, t.title,
+			ts_headline('english', t.title || ' ' || COALESCE(t.description, ''), q.query, '` + headlineOptions + `') AS snippet,
+			ts_rank(t.search_vector, q.query) AS rank, t.created_at
+		FROM tasks t
+		LEFT JOIN users u ON u.user_id = t.creator_id, q
+		WHERE t.search_vector @@ q.query AND t.team_id = ANY($3) AND ` + liveTaskCondition,
+}
+
+/*
+Given a search filter return matching messages, comments and tasks of the
+filter's teams, most relevant first
+*/
+func (s *Store) Search(userID int, f models.SearchFilter) ([]models.SearchResult, error) {
+	var sources []string
+	for _, t := range f.Types {
+		source, ok := searchSources[t]
+		if !ok {
+			return nil, fmt.Errorf("unknown search type: %s", t)
+		}
+		sources = append(sources, source)
+	}
+
+	args := []interface{}{userID, f.Query, pq.Array(f.TeamIDs)}
+	var conditions []string
+
+	if f.AuthorID != 0 {
+		args = append(args, f.AuthorID)
+		conditions = append(conditions, fmt.Sprintf("author_id = $%d", len(args)))
+	}
+	if !f.From.IsZero() {
+		args = append(args, f.From)
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if !f.To.IsZero() {
+		args = append(args, f.To)
+		conditions = append(conditions, fmt.Sprintf("created_at <= $%d", len(args)))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	args = append(args, f.Limit, f.Offset)
+	query := fmt.Sprintf(`
+		WITH q AS (SELECT websearch_to_tsquery('english', $2) AS query, $1::int AS requester_id)
+		SELECT type, id, team_id, channel_id, task_id, author_id, author_name, title, snippet, rank, created_at
+		FROM (%s) results
+		%s
+		ORDER BY rank DESC, created_at DESC
+		LIMIT $%d OFFSET $%d`,
+		strings.Join(sources, " UNION ALL "), where, len(args)-1, len(args),
+	)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []models.SearchResult{}
+	for rows.Next() {
+		var r models.SearchResult
+		if err := rows.Scan(&r.Type, &r.ID, &r.TeamID, &r.ChannelID, &r.TaskID, &r.AuthorID, &r.AuthorName, &r.Title, &r.Snippet, &r.Rank, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		r.Snippet = highlight(r.Snippet)
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// highlight escapes a ts_headline snippet and marks the matched terms
+func highlight(snippet string) string {
+	snippet = html.EscapeString(snippet)
+	snippet = strings.ReplaceAll(snippet, highlightStart, "<mark>")
+	return strings.ReplaceAll(snippet, highlightStop, "</mark>")
+}
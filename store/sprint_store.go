@@ -0,0 +1,410 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/drumilbhati/teamsync/models"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrSprintAlreadyActive = errors.New("the team already has an active sprint")
+	ErrSprintNotActive     = errors.New("only an active sprint can be closed")
+)
+
+const sprintColumns = `sprint_id, team_id, name, goal, to_char(start_date, 'YYYY-MM-DD'), to_char(end_date, 'YYYY-MM-DD'),
+	state, COALESCE(created_by, 0), created_at, started_at, closed_at`
+
+func scanSprint(row interface{ Scan(...interface{}) error }) (*models.Sprint, error) {
+	var sp models.Sprint
+	var startedAt, closedAt sql.NullTime
+	err := row.Scan(&sp.SprintID, &sp.TeamID, &sp.Name, &sp.Goal, &sp.StartDate, &sp.EndDate,
+		&sp.State, &sp.CreatedBy, &sp.CreatedAt, &startedAt, &closedAt)
+	if err != nil {
+		return nil, err
+	}
+	if startedAt.Valid {
+		sp.StartedAt = &startedAt.Time
+	}
+	if closedAt.Valid {
+		sp.ClosedAt = &closedAt.Time
+	}
+	return &sp, nil
+}
+
+func (s *Store) CreateSprint(sp *models.Sprint) error {
+	sp.State = models.SprintPlanned
+	return s.db.QueryRow(
+		`INSERT INTO sprints (team_id, name, goal, start_date, end_date, state, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING sprint_id, created_at`,
+		sp.TeamID, sp.Name, sp.Goal, sp.StartDate, sp.EndDate, sp.State, sp.CreatedBy,
+	).Scan(&sp.SprintID, &sp.CreatedAt)
+}
+
+func (s *Store) GetSprintByID(sprintID int) (*models.Sprint, error) {
+	return scanSprint(s.db.QueryRow(
+		`SELECT `+sprintColumns+` FROM sprints
+		WHERE sprint_id = $1 AND team_id IN (SELECT team_id FROM teams WHERE deleted_at IS NULL)`,
+		sprintID,
+	))
+}
+
+// GetSprintsByTeamID returns the team's sprints in the order they run
+func (s *Store) GetSprintsByTeamID(teamID int) ([]models.Sprint, error) {
+	rows, err := s.db.Query(
+		`SELECT `+sprintColumns+` FROM sprints
+		WHERE team_id = $1
+		ORDER BY start_date, sprint_id`,
+		teamID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sprints := []models.Sprint{}
+	for rows.Next() {
+		sp, err := scanSprint(rows)
+		if err != nil {
+			return nil, err
+		}
+		sprints = append(sprints, *sp)
+	}
+	return sprints, rows.Err()
+}
+
+// UpdateSprint changes the name, goal and dates of a sprint that is not closed
+func (s *Store) UpdateSprint(sp *models.Sprint) error {
+	res, err := s.db.Exec(
+		`UPDATE sprints SET name = $2, goal = $3, start_date = $4, end_date = $5
+		WHERE sprint_id = $1 AND state <> 'closed'`,
+		sp.SprintID, sp.Name, sp.Goal, sp.StartDate, sp.EndDate,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+/*
+StartSprint makes a planned sprint the team's active one. The tasks in it at
+that moment are what the sprint commits to. It returns sql.ErrNoRows if the
+sprint is not planned and ErrSprintAlreadyActive if another sprint of the
+team is running.
+*/
+func (s *Store) StartSprint(sprintID int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var teamID int
+	err = tx.QueryRow(
+		`SELECT team_id FROM sprints WHERE sprint_id = $1 AND state = 'planned' FOR UPDATE`,
+		sprintID,
+	).Scan(&teamID)
+	if err != nil {
+		return err
+	}
+
+	var active bool
+	err = tx.QueryRow(
+		`SELECT EXISTS(SELECT 1 FROM sprints WHERE team_id = $1 AND state = 'active')`,
+		teamID,
+	).Scan(&active)
+	if err != nil {
+		return err
+	}
+	if active {
+		return ErrSprintAlreadyActive
+	}
+
+	_, err = tx.Exec(
+		`UPDATE sprints SET state = 'active', started_at = CURRENT_TIMESTAMP WHERE sprint_id = $1`,
+		sprintID,
+	)
+	if err != nil {
+		// The unique index catches a sprint started concurrently
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrSprintAlreadyActive
+		}
+		return err
+	}
+
+	_, err = tx.Exec(
+		`UPDATE sprint_tasks SET committed = TRUE WHERE sprint_id = $1 AND removed_at IS NULL`,
+		sprintID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+/*
+SetTaskSprint moves a task into a sprint, or back to the backlog when
+sprintID is 0, and returns the sprint it was in before (0 for the backlog).
+A task leaving a closed sprint keeps its place in that sprint's report.
+*/
+func (s *Store) SetTaskSprint(taskID, sprintID int) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var previous int
+	err = tx.QueryRow(
+		`SELECT COALESCE(sprint_id, 0) FROM tasks WHERE task_id = $1 FOR UPDATE`,
+		taskID,
+	).Scan(&previous)
+	if err != nil {
+		return 0, err
+	}
+	if previous == sprintID {
+		return previous, nil
+	}
+
+	if _, err := tx.Exec(`UPDATE tasks SET sprint_id = NULLIF($2, 0) WHERE task_id = $1`, taskID, sprintID); err != nil {
+		return 0, err
+	}
+
+	if previous != 0 {
+		_, err = tx.Exec(
+			`UPDATE sprint_tasks SET removed_at = CURRENT_TIMESTAMP
+			WHERE sprint_id = $1 AND task_id = $2 AND removed_at IS NULL
+				AND sprint_id IN (SELECT sprint_id FROM sprints WHERE state <> 'closed')`,
+			previous, taskID,
+		)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if sprintID != 0 {
+		_, err = tx.Exec(
+			`INSERT INTO sprint_tasks (sprint_id, task_id) VALUES ($1, $2)
+			ON CONFLICT (sprint_id, task_id) DO UPDATE SET removed_at = NULL, carried_over = FALSE`,
+			sprintID, taskID,
+		)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return previous, tx.Commit()
+}
+
+// GetSprintTasks returns the tasks currently in the sprint
+func (s *Store) GetSprintTasks(sprintID int) ([]models.Task, error) {
+	rows, err := s.db.Query(
+		`SELECT t.task_id, t.team_id, t.creator_id, t.assignee_id, u.user_name, t.title, t.description, t.status, t.priority, t.due_date, t.created_at, t.updated_at
+		FROM tasks t
+		LEFT JOIN users u ON t.assignee_id = u.user_id
+		WHERE t.sprint_id = $1 AND `+liveTaskCondition+`
+		ORDER BY t.created_at, t.task_id`,
+		sprintID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := []models.Task{}
+	for rows.Next() {
+		var t models.Task
+		var assigneeName *string
+		if err := rows.Scan(&t.TaskID, &t.TeamID, &t.CreatorID, &t.AssigneeID, &assigneeName, &t.Title, &t.Description, &t.Status, &t.Priority, &t.DueDate, &t.CreatedAt, &t.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if assigneeName != nil {
+			t.AssigneeName = *assigneeName
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, rows.Err()
+}
+
+/*
+CloseSprint closes an active sprint in one transaction. The status of each
+of its tasks is recorded for the report, and the unfinished ones move to the
+team's next planned sprint, or back to the backlog when there is none. It
+returns the next sprint's id (0 for the backlog) and the number of tasks
+carried over, or ErrSprintNotActive if the sprint is not running.
+*/
+func (s *Store) CloseSprint(sprintID int) (int, int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	var teamID int
+	var state string
+	err = tx.QueryRow(
+		`SELECT team_id, state FROM sprints WHERE sprint_id = $1 FOR UPDATE`,
+		sprintID,
+	).Scan(&teamID, &state)
+	if err != nil {
+		return 0, 0, err
+	}
+	if state != models.SprintActive {
+		return 0, 0, ErrSprintNotActive
+	}
+
+	var nextID int
+	err = tx.QueryRow(
+		`SELECT sprint_id FROM sprints
+		WHERE team_id = $1 AND state = 'planned'
+		ORDER BY start_date, sprint_id
+		LIMIT 1`,
+		teamID,
+	).Scan(&nextID)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, 0, err
+	}
+
+	_, err = tx.Exec(
+		`UPDATE sprint_tasks st SET final_status = t.status
+		FROM tasks t
+		WHERE st.task_id = t.task_id AND st.sprint_id = $1 AND st.removed_at IS NULL`,
+		sprintID,
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	_, err = tx.Exec(
+		`UPDATE sprint_tasks SET carried_over = TRUE, removed_at = CURRENT_TIMESTAMP
+		WHERE sprint_id = $1 AND removed_at IS NULL AND final_status <> 'done'`,
+		sprintID,
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Tasks in the trash move along, so restoring one puts it where its sprint's work went
+	rows, err := tx.Query(
+		`UPDATE tasks t SET sprint_id = NULLIF($2, 0)
+		WHERE t.sprint_id = $1 AND t.status <> 'done'
+		RETURNING t.task_id, `+liveTaskCondition,
+		sprintID, nextID,
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+	var carried []int64
+	carriedOver := 0
+	for rows.Next() {
+		var taskID int64
+		var live bool
+		if err := rows.Scan(&taskID, &live); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		carried = append(carried, taskID)
+		if live {
+			carriedOver++
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	if nextID != 0 && len(carried) > 0 {
+		_, err = tx.Exec(
+			`INSERT INTO sprint_tasks (sprint_id, task_id)
+			SELECT $1, unnest($2::int[])
+			ON CONFLICT (sprint_id, task_id) DO UPDATE SET removed_at = NULL, carried_over = FALSE`,
+			nextID, pq.Int64Array(carried),
+		)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	_, err = tx.Exec(
+		`UPDATE sprints SET state = 'closed', closed_at = CURRENT_TIMESTAMP WHERE sprint_id = $1`,
+		sprintID,
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	return nextID, carriedOver, nil
+}
+
+// GetSprintReport returns what the sprint committed to and what it completed
+func (s *Store) GetSprintReport(sp *models.Sprint) (*models.SprintReport, error) {
+	rows, err := s.db.Query(
+		`SELECT t.task_id, t.title, t.status,
+			sp.state = 'planned' OR st.committed,
+			CASE WHEN sp.state = 'closed' THEN COALESCE(st.final_status = 'done', FALSE)
+				ELSE st.removed_at IS NULL AND t.status = 'done' END,
+			st.carried_over,
+			st.removed_at IS NOT NULL AND NOT st.carried_over
+		FROM sprint_tasks st
+		JOIN sprints sp ON st.sprint_id = sp.sprint_id
+		JOIN tasks t ON st.task_id = t.task_id
+		WHERE st.sprint_id = $1 AND `+liveTaskCondition+`
+			AND NOT (sp.state = 'planned' AND st.removed_at IS NOT NULL)
+		ORDER BY st.added_at, t.task_id`,
+		sp.SprintID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report := &models.SprintReport{Sprint: *sp, Tasks: []models.SprintReportTask{}}
+	for rows.Next() {
+		var rt models.SprintReportTask
+		if err := rows.Scan(&rt.TaskID, &rt.Title, &rt.Status, &rt.Committed, &rt.Completed, &rt.CarriedOver, &rt.Removed); err != nil {
+			return nil, err
+		}
+
+		if rt.Committed {
+			report.Committed++
+			if rt.Completed {
+				report.CommittedCompleted++
+			}
+		} else {
+			report.Added++
+		}
+		if rt.Completed {
+			report.Completed++
+		}
+		if rt.CarriedOver {
+			report.CarriedOver++
+		}
+		if rt.Removed {
+			report.Removed++
+		}
+		report.Tasks = append(report.Tasks, rt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if report.Committed > 0 {
+		report.CompletionRate = float64(report.CommittedCompleted) / float64(report.Committed)
+	}
+	return report, nil
+}
@@ -0,0 +1,96 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	summaryCacheTTL = 7 * 24 * time.Hour
+
+	// Bounds on what is sent to the model; the most recent content is kept
+	maxSummaryItems     = 500
+	maxTranscriptLength = 60000
+	summaryTimeFormat   = "2006-01-02 15:04"
+)
+
+// BuildTaskThreadTranscript renders a task and its comment thread as plain text
+func (s *Store) BuildTaskThreadTranscript(taskID int) (string, int, error) {
+	task, err := s.GetTaskByTaskID(taskID)
+	if err != nil {
+		return "", 0, err
+	}
+
+	comments, err := s.GetCommentsByTaskID(taskID)
+	if err != nil {
+		return "", 0, err
+	}
+	if len(comments) > maxSummaryItems {
+		comments = comments[len(comments)-maxSummaryItems:]
+	}
+
+	var lines []string
+	for _, c := range comments {
+		lines = append(lines, fmt.Sprintf("[%s] %s: %s", c.CreatedAt.UTC().Format(summaryTimeFormat), c.UserName, c.Content))
+	}
+
+	header := fmt.Sprintf("Task: %s\nStatus: %s\nDescription: %s\n\nComments:\n", task.Title, task.Status, task.Description.String)
+	return header + joinTranscript(lines), len(comments), nil
+}
+
+// BuildChatTranscript renders a channel's messages posted after since as plain text
+func (s *Store) BuildChatTranscript(channelID int, since time.Time) (string, int, error) {
+	messages, err := s.GetMessagesByChannelIDSince(channelID, since, maxSummaryItems)
+	if err != nil {
+		return "", 0, err
+	}
+
+	var lines []string
+	for _, m := range messages {
+		lines = append(lines, fmt.Sprintf("[%s] %s: %s", m.CreatedAt.UTC().Format(summaryTimeFormat), m.UserName, m.Content))
+	}
+	return joinTranscript(lines), len(messages), nil
+}
+
+// joinTranscript drops the oldest lines until the transcript fits the model budget
+func joinTranscript(lines []string) string {
+	total := 0
+	start := len(lines)
+	for start > 0 && total+len(lines[start-1])+1 <= maxTranscriptLength {
+		start--
+		total += len(lines[start]) + 1
+	}
+	return strings.Join(lines[start:], "\n")
+}
+
+// SummaryContentHash identifies a summary by what was summarised, so the
+// cached result is reused until the thread or chat changes
+func SummaryContentHash(kind, transcript string) string {
+	sum := sha256.Sum256([]byte(kind + "\n" + transcript))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *Store) GetCachedSummary(hash string) (string, bool, error) {
+	ctx := context.Background()
+	key := fmt.Sprintf("summary:%s", hash)
+
+	summary, err := s.rdb.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, err
+	}
+	return summary, true, nil
+}
+
+func (s *Store) SetCachedSummary(hash, summary string) error {
+	ctx := context.Background()
+	key := fmt.Sprintf("summary:%s", hash)
+	return s.rdb.Set(ctx, key, summary, summaryCacheTTL).Err()
+}
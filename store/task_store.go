@@ -1,9 +1,13 @@
 package store
 
 import (
+	"database/sql"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/drumilbhati/teamsync/models"
+	"github.com/lib/pq"
 )
 
 func (s *Store) CreateTask(t *models.Task) error {
@@ -17,6 +21,31 @@ func (s *Store) CreateTask(t *models.Task) error {
 	return err
 }
 
+// CreateTasks inserts all tasks in one transaction, so either every task is created or none are
+func (s *Store) CreateTasks(tasks []models.Task) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i := range tasks {
+		t := &tasks[i]
+		err = tx.QueryRow(
+			`INSERT INTO tasks (team_id, creator_id, assignee_id, title, description, status, priority, due_date)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING task_id, created_at`,
+			t.TeamID, t.CreatorID, t.AssigneeID, t.Title, t.Description, t.Status, t.Priority, t.DueDate,
+		).Scan(&t.TaskID, &t.CreatedAt)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
 func (s *Store) GetTaskByTaskID(taskID int) (*models.Task, error) {
 	var t models.Task
 	var assigneeName *string
@@ -24,7 +53,7 @@ func (s *Store) GetTaskByTaskID(taskID int) (*models.Task, error) {
 		`SELECT t.task_id, t.team_id, t.creator_id, t.assignee_id, u.user_name, t.title, t.description, t.status, t.priority, t.due_date, t.created_at, t.updated_at
 		FROM tasks t
 		LEFT JOIN users u ON t.assignee_id = u.user_id
-		WHERE t.task_id = $1`,
+		WHERE t.task_id = $1 AND `+liveTaskCondition,
 		taskID,
 	).Scan(&t.TaskID, &t.TeamID, &t.CreatorID, &t.AssigneeID, &assigneeName, &t.Title, &t.Description, &t.Status, &t.Priority, &t.DueDate, &t.CreatedAt, &t.UpdatedAt)
 	if err != nil {
@@ -41,7 +70,7 @@ func (s *Store) GetTasksByTeamID(teamID int) ([]models.Task, error) {
 		`SELECT t.task_id, t.team_id, t.creator_id, t.assignee_id, u.user_name, t.title, t.description, t.status, t.priority, t.due_date, t.created_at, t.updated_at
 		FROM tasks t
 		LEFT JOIN users u ON t.assignee_id = u.user_id
-		WHERE t.team_id = $1`,
+		WHERE t.team_id = $1 AND `+liveTaskCondition,
 		teamID,
 	)
 	if err != nil {
@@ -74,7 +103,7 @@ func (s *Store) GetTasksByTeamIDWithPriority(teamID int, priority models.TaskPri
 		`SELECT t.task_id, t.team_id, t.creator_id, t.assignee_id, u.user_name, t.title, t.description, t.status, t.priority, t.due_date, t.created_at, t.updated_at
 		FROM tasks t
 		LEFT JOIN users u ON t.assignee_id = u.user_id
-		WHERE t.team_id = $1 AND t.priority = $2`, teamID, priority,
+		WHERE t.team_id = $1 AND t.priority = $2 AND `+liveTaskCondition, teamID, priority,
 	)
 	if err != nil {
 		return nil, err
@@ -102,7 +131,7 @@ func (s *Store) GetTasksByTeamIDWithStatus(teamID int, status models.TaskStatus)
 		`SELECT t.task_id, t.team_id, t.creator_id, t.assignee_id, u.user_name, t.title, t.description, t.status, t.priority, t.due_date, t.created_at, t.updated_at
 		FROM tasks t
 		LEFT JOIN users u ON t.assignee_id = u.user_id
-		WHERE t.team_id = $1 AND t.status = $2`, teamID, status,
+		WHERE t.team_id = $1 AND t.status = $2 AND `+liveTaskCondition, teamID, status,
 	)
 	if err != nil {
 		return nil, err
@@ -127,17 +156,118 @@ func (s *Store) GetTasksByTeamIDWithStatus(teamID int, status models.TaskStatus)
 func (s *Store) UpdateTaskByID(taskID int, t *models.Task) error {
 	_, err := s.db.Exec(
 		`UPDATE tasks
-		SET title = $1, assignee_id = $2, description = $3, status = $4, priority = $5, due_date = $6, updated_at = $7 WHERE task_id = $8`,
+		SET title = $1, assignee_id = $2, description = $3, status = $4, priority = $5, due_date = $6, updated_at = $7 WHERE task_id = $8 AND deleted_at IS NULL`,
 		t.Title, t.AssigneeID, t.Description, t.Status, t.Priority, t.DueDate, time.Now(), taskID,
 	)
 
 	return err
 }
 
-func (s *Store) DeleteTaskByID(taskID int) error {
-	_, err := s.db.Exec(
-		`DELETE FROM tasks WHERE task_id = $1`,
-		taskID,
+// DeleteTaskByID moves the task to the trash; its comments and attachments are hidden with it
+func (s *Store) DeleteTaskByID(taskID int, deletedBy int) error {
+	res, err := s.db.Exec(
+		`UPDATE tasks SET deleted_at = CURRENT_TIMESTAMP, deleted_by = $2
+		WHERE task_id = $1 AND deleted_at IS NULL`,
+		taskID, deletedBy,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+/*
+QueryTasks runs a validated task filter. Every condition is a bound
+parameter; f.TeamIDs must already be limited to the requester's teams.
+*/
+func (s *Store) QueryTasks(f models.TaskFilter) ([]models.Task, error) {
+	args := []interface{}{pq.Array(f.TeamIDs)}
+	conditions := []string{"t.team_id = ANY($1)", liveTaskCondition}
+
+	if len(f.Statuses) > 0 {
+		statuses := make([]string, len(f.Statuses))
+		for i, status := range f.Statuses {
+			statuses[i] = string(status)
+		}
+		args = append(args, pq.Array(statuses))
+		conditions = append(conditions, fmt.Sprintf("t.status = ANY($%d)", len(args)))
+	}
+	if len(f.Priorities) > 0 {
+		priorities := make([]string, len(f.Priorities))
+		for i, priority := range f.Priorities {
+			priorities[i] = string(priority)
+		}
+		args = append(args, pq.Array(priorities))
+		conditions = append(conditions, fmt.Sprintf("t.priority = ANY($%d)", len(args)))
+	}
+	if len(f.AssigneeIDs) > 0 && f.Unassigned {
+		args = append(args, pq.Array(f.AssigneeIDs))
+		conditions = append(conditions, fmt.Sprintf("(t.assignee_id = ANY($%d) OR t.assignee_id IS NULL)", len(args)))
+	} else if len(f.AssigneeIDs) > 0 {
+		args = append(args, pq.Array(f.AssigneeIDs))
+		conditions = append(conditions, fmt.Sprintf("t.assignee_id = ANY($%d)", len(args)))
+	} else if f.Unassigned {
+		conditions = append(conditions, "t.assignee_id IS NULL")
+	}
+	if len(f.CreatorIDs) > 0 {
+		args = append(args, pq.Array(f.CreatorIDs))
+		conditions = append(conditions, fmt.Sprintf("t.creator_id = ANY($%d)", len(args)))
+	}
+	if f.DueAfter != "" {
+		args = append(args, f.DueAfter)
+		conditions = append(conditions, fmt.Sprintf("t.due_date >= $%d::date", len(args)))
+	}
+	if f.DueBefore != "" {
+		args = append(args, f.DueBefore)
+		conditions = append(conditions, fmt.Sprintf("t.due_date < $%d::date + 1", len(args)))
+	}
+	if f.Overdue {
+		conditions = append(conditions, "t.due_date < NOW() AND t.status <> 'done'")
+	}
+	if f.Text != "" {
+		args = append(args, f.Text)
+		conditions = append(conditions, fmt.Sprintf("t.search_vector @@ websearch_to_tsquery('english', $%d)", len(args)))
+	}
+
+	args = append(args, f.Limit)
+	query := `
+		SELECT t.task_id, t.team_id, t.creator_id, t.assignee_id, u.user_name, t.title, t.description, t.status, t.priority, t.due_date, t.created_at, t.updated_at
+		FROM tasks t
+		LEFT JOIN users u ON t.assignee_id = u.user_id
+		WHERE ` + strings.Join(conditions, " AND ") + fmt.Sprintf(`
+		ORDER BY t.due_date ASC NULLS LAST, t.created_at DESC
+		LIMIT $%d`, len(args))
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	tasks := []models.Task{}
+	for rows.Next() {
+		var t models.Task
+		var assigneeName *string
+		if err := rows.Scan(&t.TaskID, &t.TeamID, &t.CreatorID, &t.AssigneeID, &assigneeName, &t.Title, &t.Description, &t.Status, &t.Priority, &t.DueDate, &t.CreatedAt, &t.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if assigneeName != nil {
+			t.AssigneeName = *assigneeName
+		}
+		tasks = append(tasks, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tasks, nil
 }
@@ -18,6 +18,8 @@ package store
 */
 
 import (
+	"database/sql"
+
 	"github.com/drumilbhati/teamsync/models"
 )
 
@@ -47,12 +49,12 @@ Given a team_id return the entire team
 func (s *Store) GetTeamByID(team_id int) (*models.Team, error) {
 	var team models.Team
 	err := s.db.QueryRow(
-		`SELECT t.team_id, t.team_name, t.team_leader_id, u.user_name, t.created_at
+		`SELECT t.team_id, t.team_name, t.team_leader_id, u.user_name, t.ai_enabled, t.created_at
 		FROM teams t
 		JOIN users u ON t.team_leader_id = u.user_id
-		WHERE t.team_id = $1`,
+		WHERE t.team_id = $1 AND t.deleted_at IS NULL`,
 		team_id,
-	).Scan(&team.TeamID, &team.TeamName, &team.TeamLeaderID, &team.TeamLeaderName, &team.CreatedAt)
+	).Scan(&team.TeamID, &team.TeamName, &team.TeamLeaderID, &team.TeamLeaderName, &team.AIEnabled, &team.CreatedAt)
 
 	if err != nil {
 		return nil, err
@@ -91,7 +93,7 @@ func (s *Store) GetTeamsByTeamLeaderID(team_leader_id int) ([]models.Team, error
 		`SELECT t.team_id, t.team_name, t.team_leader_id, u.user_name, t.created_at
 			FROM teams t
 			JOIN users u ON t.team_leader_id = u.user_id
-			WHERE t.team_leader_id = $1
+			WHERE t.team_leader_id = $1 AND t.deleted_at IS NULL
 		`,
 		team_leader_id,
 	)
@@ -115,11 +117,11 @@ func (s *Store) GetTeamsByTeamLeaderID(team_leader_id int) ([]models.Team, error
 func (s *Store) GetTeamsByUserID(user_id int) ([]models.Team, error) {
 	teams := []models.Team{}
 	rows, err := s.db.Query(
-		`SELECT DISTINCT t.team_id, t.team_name, t.team_leader_id, u.user_name, t.created_at
+		`SELECT DISTINCT t.team_id, t.team_name, t.team_leader_id, u.user_name, t.ai_enabled, t.created_at
 		FROM teams t
 		LEFT JOIN members m ON t.team_id = m.team_id
 		JOIN users u ON t.team_leader_id = u.user_id
-		WHERE m.user_id = $1 OR t.team_leader_id = $1`,
+		WHERE (m.user_id = $1 OR t.team_leader_id = $1) AND t.deleted_at IS NULL`,
 		user_id,
 	)
 	if err != nil {
@@ -131,7 +133,7 @@ func (s *Store) GetTeamsByUserID(user_id int) ([]models.Team, error) {
 	for rows.Next() {
 		var t models.Team
 		t.Members = []models.Member{}
-		if err := rows.Scan(&t.TeamID, &t.TeamName, &t.TeamLeaderID, &t.TeamLeaderName, &t.CreatedAt); err != nil {
+		if err := rows.Scan(&t.TeamID, &t.TeamName, &t.TeamLeaderID, &t.TeamLeaderName, &t.AIEnabled, &t.CreatedAt); err != nil {
 			return nil, err
 		}
 		t.TeamCode = generateTeamCode(t.TeamID)
@@ -173,6 +175,16 @@ func (s *Store) CreateTeam(t *models.Team) error {
 		return err
 	}
 
+	_, err = tx.Exec(
+		`INSERT INTO channels (team_id, name, is_default, created_by)
+		VALUES ($1, 'general', TRUE, $2)`,
+		t.TeamID, t.TeamLeaderID,
+	)
+
+	if err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
@@ -183,19 +195,33 @@ func (s *Store) UpdateTeamByID(team_id int, t *models.Team) error {
 	_, err := s.db.Exec(
 		`UPDATE teams
 		SET team_name = $1, team_leader_id = $2
-		WHERE team_id = $3`,
+		WHERE team_id = $3 AND deleted_at IS NULL`,
 		t.TeamName, t.TeamLeaderID, team_id,
 	)
 	return err
 }
 
 /*
-Given a team_id delete it from database
+Given a team_id move it to the trash, hiding it with everything it holds
+until it is restored or purged
 */
-func (s *Store) DeleteTeamByID(team_id int) error {
-	_, err := s.db.Exec(
-		"DELETE FROM teams WHERE team_id = $1",
-		team_id,
+func (s *Store) DeleteTeamByID(team_id int, deleted_by int) error {
+	res, err := s.db.Exec(
+		`UPDATE teams
+		SET deleted_at = CURRENT_TIMESTAMP, deleted_by = $2
+		WHERE team_id = $1 AND deleted_at IS NULL`,
+		team_id, deleted_by,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
 }
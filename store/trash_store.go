@@ -0,0 +1,208 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/drumilbhati/teamsync/models"
+)
+
+// liveTaskCondition matches tasks (aliased t) that are not in the trash,
+// neither by themselves nor with their team
+const liveTaskCondition = `t.deleted_at IS NULL AND NOT EXISTS(
+	SELECT 1 FROM teams dt WHERE dt.team_id = t.team_id AND dt.deleted_at IS NOT NULL
+)`
+
+// attachmentNotTrashed matches attachments whose task or comment, and the
+// comment's task, are not in the trash
+const attachmentNotTrashed = `NOT EXISTS(
+	SELECT 1 FROM comments c
+	JOIN tasks t ON c.task_id = t.task_id
+	WHERE c.comment_id = attachments.comment_id AND (c.deleted_at IS NOT NULL OR t.deleted_at IS NOT NULL)
+) AND NOT EXISTS(
+	SELECT 1 FROM tasks t WHERE t.task_id = attachments.task_id AND t.deleted_at IS NOT NULL
+)`
+
+var trashQueries = map[string]string{
+	models.TrashTypeTeam: `
+		SELECT 'team', tm.team_id, tm.team_id, 0, tm.team_name,
+			COALESCE(tm.deleted_by, 0), COALESCE(u.user_name, ''), tm.deleted_at
+		FROM teams tm
+		LEFT JOIN users u ON tm.deleted_by = u.user_id
+		WHERE tm.deleted_at IS NOT NULL`,
+	models.TrashTypeTask: `
+		SELECT 'task', t.task_id, t.team_id, t.task_id, t.title,
+			COALESCE(t.deleted_by, 0), COALESCE(u.user_name, ''), t.deleted_at
+		FROM tasks t
+		LEFT JOIN users u ON t.deleted_by = u.user_id
+		WHERE t.deleted_at IS NOT NULL`,
+	models.TrashTypeComment: `
+		SELECT 'comment', c.comment_id, t.team_id, c.task_id, LEFT(c.content, 100),
+			COALESCE(c.deleted_by, 0), COALESCE(u.user_name, ''), c.deleted_at
+		FROM comments c
+		JOIN tasks t ON c.task_id = t.task_id
+		LEFT JOIN users u ON c.deleted_by = u.user_id
+		WHERE c.deleted_at IS NOT NULL`,
+}
+
+func scanTrashItem(row interface{ Scan(...interface{}) error }) (*models.TrashItem, error) {
+	var item models.TrashItem
+	err := row.Scan(&item.Type, &item.ID, &item.TeamID, &item.TaskID, &item.Title,
+		&item.DeletedBy, &item.DeletedByName, &item.DeletedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (s *Store) queryTrash(query string, args ...interface{}) ([]models.TrashItem, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.TrashItem{}
+	for rows.Next() {
+		item, err := scanTrashItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *item)
+	}
+	return items, rows.Err()
+}
+
+/*
+GetTeamTrash returns the team's deleted tasks and comments, most recently
+deleted first. Comments of a deleted task are not listed on their own; they
+come back with the task.
+*/
+func (s *Store) GetTeamTrash(teamID int) ([]models.TrashItem, error) {
+	return s.queryTrash(
+		trashQueries[models.TrashTypeTask]+` AND t.team_id = $1
+		UNION ALL`+
+			trashQueries[models.TrashTypeComment]+` AND t.team_id = $1 AND t.deleted_at IS NULL
+		ORDER BY 8 DESC`,
+		teamID,
+	)
+}
+
+// GetDeletedTeamsByLeaderID returns the deleted teams the user leads
+func (s *Store) GetDeletedTeamsByLeaderID(userID int) ([]models.TrashItem, error) {
+	return s.queryTrash(
+		trashQueries[models.TrashTypeTeam]+` AND tm.team_leader_id = $1
+		ORDER BY tm.deleted_at DESC`,
+		userID,
+	)
+}
+
+// GetTrashItem returns a deleted team, task or comment, or sql.ErrNoRows if it is not in the trash
+func (s *Store) GetTrashItem(itemType string, id int) (*models.TrashItem, error) {
+	query, ok := trashQueries[itemType]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	column := map[string]string{
+		models.TrashTypeTeam:    "tm.team_id",
+		models.TrashTypeTask:    "t.task_id",
+		models.TrashTypeComment: "c.comment_id",
+	}[itemType]
+	return scanTrashItem(s.db.QueryRow(query+` AND `+column+` = $1`, id))
+}
+
+// RestoreTrashItem takes a team, task or comment out of the trash
+func (s *Store) RestoreTrashItem(itemType string, id int) error {
+	table, column := "", ""
+	switch itemType {
+	case models.TrashTypeTeam:
+		table, column = "teams", "team_id"
+	case models.TrashTypeTask:
+		table, column = "tasks", "task_id"
+	case models.TrashTypeComment:
+		table, column = "comments", "comment_id"
+	default:
+		return sql.ErrNoRows
+	}
+
+	res, err := s.db.Exec(
+		`UPDATE `+table+` SET deleted_at = NULL, deleted_by = NULL
+		WHERE `+column+` = $1 AND deleted_at IS NOT NULL`,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+/*
+PurgeTrash permanently deletes the teams, tasks and comments deleted before
+the cutoff, with everything that cascades from them. It returns the number
+of purged items and the storage keys of the attachment files that went with
+them, which the caller deletes once the transaction has committed.
+*/
+func (s *Store) PurgeTrash(deletedBefore time.Time) (int64, []string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(
+		`SELECT a.storage_key, a.thumbnail_key
+		FROM attachments a
+		LEFT JOIN comments c ON a.comment_id = c.comment_id
+		LEFT JOIN tasks t ON t.task_id = COALESCE(a.task_id, c.task_id)
+		JOIN teams tm ON a.team_id = tm.team_id
+		WHERE tm.deleted_at < $1 OR t.deleted_at < $1 OR c.deleted_at < $1`,
+		deletedBefore,
+	)
+	if err != nil {
+		return 0, nil, err
+	}
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		var thumbnailKey sql.NullString
+		if err := rows.Scan(&key, &thumbnailKey); err != nil {
+			rows.Close()
+			return 0, nil, err
+		}
+		keys = append(keys, key)
+		if thumbnailKey.Valid {
+			keys = append(keys, thumbnailKey.String)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+
+	var purged int64
+	for _, table := range []string{"comments", "tasks", "teams"} {
+		res, err := tx.Exec(`DELETE FROM `+table+` WHERE deleted_at < $1`, deletedBefore)
+		if err != nil {
+			return 0, nil, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, nil, err
+		}
+		purged += n
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, nil, err
+	}
+	return purged, keys, nil
+}
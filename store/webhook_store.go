@@ -0,0 +1,148 @@
+package store
+
+import (
+	"github.com/drumilbhati/teamsync/models"
+	"github.com/lib/pq"
+)
+
+const webhookColumns = `webhook_id, team_id, COALESCE(created_by, 0), url, secret, events, active, created_at`
+
+func scanWebhook(row interface{ Scan(...interface{}) error }, wh *models.Webhook) error {
+	return row.Scan(&wh.WebhookID, &wh.TeamID, &wh.CreatedBy, &wh.URL, &wh.Secret,
+		pq.Array(&wh.Events), &wh.Active, &wh.CreatedAt)
+}
+
+func (s *Store) CreateWebhook(wh *models.Webhook) error {
+	return s.db.QueryRow(
+		`INSERT INTO webhooks (team_id, created_by, url, secret, events, active)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING webhook_id, created_at`,
+		wh.TeamID, wh.CreatedBy, wh.URL, wh.Secret, pq.Array(wh.Events), wh.Active,
+	).Scan(&wh.WebhookID, &wh.CreatedAt)
+}
+
+func (s *Store) GetWebhookByID(webhookID int) (*models.Webhook, error) {
+	var wh models.Webhook
+	row := s.db.QueryRow(
+		"SELECT "+webhookColumns+" FROM webhooks WHERE webhook_id = $1",
+		webhookID,
+	)
+	if err := scanWebhook(row, &wh); err != nil {
+		return nil, err
+	}
+	return &wh, nil
+}
+
+func (s *Store) GetWebhooksByTeamID(teamID int) ([]models.Webhook, error) {
+	return s.getWebhooks(
+		"SELECT "+webhookColumns+" FROM webhooks WHERE team_id = $1 ORDER BY webhook_id",
+		teamID,
+	)
+}
+
+// GetWebhooksForEvent returns the team's active webhooks subscribed to the event
+func (s *Store) GetWebhooksForEvent(teamID int, event string) ([]models.Webhook, error) {
+	return s.getWebhooks(
+		"SELECT "+webhookColumns+` FROM webhooks
+		WHERE team_id = $1 AND active AND (cardinality(events) = 0 OR $2 = ANY(events))`,
+		teamID, event,
+	)
+}
+
+func (s *Store) getWebhooks(query string, args ...interface{}) ([]models.Webhook, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []models.Webhook{}
+	for rows.Next() {
+		var wh models.Webhook
+		if err := scanWebhook(rows, &wh); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, wh)
+	}
+	return webhooks, rows.Err()
+}
+
+func (s *Store) UpdateWebhookByID(webhookID int, wh *models.Webhook) error {
+	_, err := s.db.Exec(
+		`UPDATE webhooks SET url = $1, secret = $2, events = $3, active = $4 WHERE webhook_id = $5`,
+		wh.URL, wh.Secret, pq.Array(wh.Events), wh.Active, webhookID,
+	)
+	return err
+}
+
+func (s *Store) DeleteWebhookByID(webhookID int) error {
+	_, err := s.db.Exec("DELETE FROM webhooks WHERE webhook_id = $1", webhookID)
+	return err
+}
+
+const webhookDeliveryColumns = `delivery_id, webhook_id, event, payload, status, attempts,
+	COALESCE(response_status, 0), COALESCE(last_error, ''), created_at, updated_at`
+
+func scanWebhookDelivery(row interface{ Scan(...interface{}) error }, d *models.WebhookDelivery) error {
+	return row.Scan(&d.DeliveryID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts,
+		&d.ResponseStatus, &d.LastError, &d.CreatedAt, &d.UpdatedAt)
+}
+
+func (s *Store) CreateWebhookDelivery(d *models.WebhookDelivery) error {
+	d.Status = models.WebhookDeliveryPending
+	return s.db.QueryRow(
+		`INSERT INTO webhook_deliveries (webhook_id, event, payload, status)
+		VALUES ($1, $2, $3, $4)
+		RETURNING delivery_id, created_at, updated_at`,
+		d.WebhookID, d.Event, d.Payload, d.Status,
+	).Scan(&d.DeliveryID, &d.CreatedAt, &d.UpdatedAt)
+}
+
+func (s *Store) GetWebhookDeliveryByID(deliveryID int) (*models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	row := s.db.QueryRow(
+		"SELECT "+webhookDeliveryColumns+" FROM webhook_deliveries WHERE delivery_id = $1",
+		deliveryID,
+	)
+	if err := scanWebhookDelivery(row, &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// GetWebhookDeliveries returns a webhook's deliveries, newest first, optionally only those with the given status
+func (s *Store) GetWebhookDeliveries(webhookID int, status string, limit int) ([]models.WebhookDelivery, error) {
+	rows, err := s.db.Query(
+		"SELECT "+webhookDeliveryColumns+` FROM webhook_deliveries
+		WHERE webhook_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC, delivery_id DESC
+		LIMIT $3`,
+		webhookID, status, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var d models.WebhookDelivery
+		if err := scanWebhookDelivery(rows, &d); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// RecordWebhookAttempt stores the outcome of one delivery attempt
+func (s *Store) RecordWebhookAttempt(deliveryID int, status string, responseStatus int, lastError string) error {
+	_, err := s.db.Exec(
+		`UPDATE webhook_deliveries
+		SET status = $1, attempts = attempts + 1, response_status = NULLIF($2, 0),
+			last_error = NULLIF($3, ''), updated_at = CURRENT_TIMESTAMP
+		WHERE delivery_id = $4`,
+		status, responseStatus, lastError, deliveryID,
+	)
+	return err
+}
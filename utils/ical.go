@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ICalEvent is a single VEVENT of an iCalendar feed
+type ICalEvent struct {
+	// UID must stay the same for the same item across refreshes so
+	// calendar clients update the event instead of adding a new one
+	UID          string
+	Summary      string
+	Description  string
+	Categories   []string
+	Start        time.Time
+	Duration     time.Duration
+	AllDay       bool
+	LastModified time.Time
+}
+
+const (
+	icalUTCFormat  = "20060102T150405Z"
+	icalDateFormat = "20060102"
+
+	// RFC 5545 limits content lines to 75 octets
+	icalMaxLineOctets = 75
+)
+
+/*
+WriteICalendar writes events as an RFC 5545 calendar. Timed events are
+written in UTC so clients show them in their own timezone; all-day events
+use the date of Start in Start's location. timezone is only advertised to
+clients as the calendar's display timezone.
+*/
+func WriteICalendar(w io.Writer, name, timezone string, events []ICalEvent) error {
+	bw := bufio.NewWriter(w)
+	line := func(s string) {
+		writeICalLine(bw, s)
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//TeamSync//Task Due Dates//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	line("X-WR-CALNAME:" + escapeICalText(name))
+	if timezone != "" {
+		line("X-WR-TIMEZONE:" + timezone)
+	}
+
+	for _, e := range events {
+		line("BEGIN:VEVENT")
+		line("UID:" + e.UID)
+		line("DTSTAMP:" + e.LastModified.UTC().Format(icalUTCFormat))
+		line("LAST-MODIFIED:" + e.LastModified.UTC().Format(icalUTCFormat))
+		if e.AllDay {
+			line("DTSTART;VALUE=DATE:" + e.Start.Format(icalDateFormat))
+			line("DTEND;VALUE=DATE:" + e.Start.AddDate(0, 0, 1).Format(icalDateFormat))
+		} else {
+			line("DTSTART:" + e.Start.UTC().Format(icalUTCFormat))
+			line("DTEND:" + e.Start.Add(e.Duration).UTC().Format(icalUTCFormat))
+		}
+		line("SUMMARY:" + escapeICalText(e.Summary))
+		if e.Description != "" {
+			line("DESCRIPTION:" + escapeICalText(e.Description))
+		}
+		if len(e.Categories) > 0 {
+			categories := make([]string, len(e.Categories))
+			for i, c := range e.Categories {
+				categories[i] = escapeICalText(c)
+			}
+			line("CATEGORIES:" + strings.Join(categories, ","))
+		}
+		line("TRANSP:TRANSPARENT")
+		line("END:VEVENT")
+	}
+
+	line("END:VCALENDAR")
+	return bw.Flush()
+}
+
+func escapeICalText(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	).Replace(s)
+}
+
+// writeICalLine folds a content line into CRLF-terminated lines of at most
+// 75 octets, without splitting UTF-8 sequences
+func writeICalLine(w *bufio.Writer, s string) {
+	limit := icalMaxLineOctets
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		w.WriteString(s[:cut])
+		w.WriteString("\r\n ")
+		s = s[cut:]
+		// Continuation lines start with a space, which counts towards the limit
+		limit = icalMaxLineOctets - 1
+	}
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
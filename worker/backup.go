@@ -0,0 +1,122 @@
+package worker
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/drumilbhati/teamsync/logs"
+	"github.com/drumilbhati/teamsync/models"
+	"github.com/drumilbhati/teamsync/storage"
+	"github.com/drumilbhati/teamsync/store"
+	"github.com/hibiken/asynq"
+)
+
+// Unique name for task type
+const TypeTeamBackup = "backup:team"
+
+type TeamBackupPayload struct {
+	BackupID int `json:"backup_id"`
+}
+
+/*	Producer Logic (Used by controller)	 */
+
+// NewTeamBackupTask creates a task to write a queued backup's archive
+func NewTeamBackupTask(backupID int) (*asynq.Task, error) {
+	payloadBytes, err := json.Marshal(TeamBackupPayload{BackupID: backupID})
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(TypeTeamBackup, payloadBytes,
+		asynq.MaxRetry(3),
+		asynq.Timeout(30*time.Minute),
+	), nil
+}
+
+// BackupStorageKey is where the archive of a backup is stored
+func BackupStorageKey(teamID, backupID int) string {
+	return fmt.Sprintf("backups/%d/%d.json.gz", teamID, backupID)
+}
+
+/*	Consumer Logic (Used by Background Worker) */
+
+type BackupProcessor struct {
+	store   *store.Store
+	storage storage.Storage
+}
+
+func NewBackupProcessor(s *store.Store, st storage.Storage) *BackupProcessor {
+	return &BackupProcessor{store: s, storage: st}
+}
+
+// ProcessTask snapshots the team into a gzipped JSON archive and stores it
+func (p *BackupProcessor) ProcessTask(ctx context.Context, t *asynq.Task) error {
+	var payload TeamBackupPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("json.Unmarshal failed%v: %w", err, asynq.SkipRetry)
+	}
+
+	backup, err := p.store.GetBackupByID(payload.BackupID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("backup %d not found: %w", payload.BackupID, asynq.SkipRetry)
+	}
+	if err != nil {
+		return err
+	}
+	if backup.Status == models.BackupCompleted || backup.Status == models.BackupFailed {
+		return nil
+	}
+	if backup.TeamID == 0 {
+		p.store.FinishBackup(backup.BackupID, models.BackupFailed, "", 0, "the team was deleted before the backup ran")
+		return nil
+	}
+
+	if err := p.store.StartBackup(backup.BackupID); err != nil {
+		return err
+	}
+
+	key := BackupStorageKey(backup.TeamID, backup.BackupID)
+	size, err := p.write(ctx, backup.TeamID, key)
+	if err != nil {
+		retried, _ := asynq.GetRetryCount(ctx)
+		maxRetry, _ := asynq.GetMaxRetry(ctx)
+		if retried >= maxRetry {
+			p.store.FinishBackup(backup.BackupID, models.BackupFailed, "", 0, err.Error())
+		}
+		return fmt.Errorf("backup %d failed: %w", backup.BackupID, err)
+	}
+
+	if err := p.store.FinishBackup(backup.BackupID, models.BackupCompleted, key, size, ""); err != nil {
+		return fmt.Errorf("failed to record backup %d: %w", backup.BackupID, err)
+	}
+
+	logs.Log.Infof("Backup %d of team %d completed (%d bytes)", backup.BackupID, backup.TeamID, size)
+	return nil
+}
+
+func (p *BackupProcessor) write(ctx context.Context, teamID int, key string) (int64, error) {
+	archive, err := p.store.BuildTeamBackup(ctx, teamID)
+	if err != nil {
+		return 0, err
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(archive); err != nil {
+		return 0, err
+	}
+	if err := zw.Close(); err != nil {
+		return 0, err
+	}
+
+	size := int64(buf.Len())
+	if err := p.storage.Put(ctx, key, &buf, size, "application/gzip"); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
@@ -0,0 +1,186 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/drumilbhati/teamsync/importer"
+	"github.com/drumilbhati/teamsync/logs"
+	"github.com/drumilbhati/teamsync/models"
+	"github.com/drumilbhati/teamsync/storage"
+	"github.com/drumilbhati/teamsync/store"
+	"github.com/drumilbhati/teamsync/ws"
+	"github.com/hibiken/asynq"
+)
+
+// Unique name for task type
+const TypeExternalImport = "import:external"
+
+type ExternalImportPayload struct {
+	JobID int `json:"job_id"`
+}
+
+/*	Producer Logic (Used by controller)	 */
+
+// NewExternalImportTask creates a task to run a queued Trello or Jira import.
+// Imports are not retried: a failed import is reported and can be uploaded again.
+func NewExternalImportTask(jobID int) (*asynq.Task, error) {
+	payloadBytes, err := json.Marshal(ExternalImportPayload{JobID: jobID})
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(TypeExternalImport, payloadBytes,
+		asynq.MaxRetry(0),
+		asynq.Timeout(30*time.Minute),
+	), nil
+}
+
+/*	Consumer Logic (Used by Background Worker) */
+
+type ImportProcessor struct {
+	store   *store.Store
+	storage storage.Storage
+	wsHub   *ws.Hub
+}
+
+func NewImportProcessor(s *store.Store, st storage.Storage, wsHub *ws.Hub) *ImportProcessor {
+	return &ImportProcessor{store: s, storage: st, wsHub: wsHub}
+}
+
+// ProcessTask parses the uploaded export, imports it through the store and records the report on the job
+func (p *ImportProcessor) ProcessTask(ctx context.Context, t *asynq.Task) error {
+	var payload ExternalImportPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("json.Unmarshal failed%v: %w", err, asynq.SkipRetry)
+	}
+
+	job, err := p.store.GetImportJobByID(payload.JobID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("import job %d not found: %w", payload.JobID, asynq.SkipRetry)
+	}
+	if err != nil {
+		return err
+	}
+	if job.Status != models.ImportJobQueued {
+		return nil
+	}
+
+	defer func() {
+		if err := p.storage.Delete(context.Background(), job.StorageKey); err != nil {
+			logs.Log.Warnf("Failed to delete import file of job %d: %v", job.JobID, err)
+		}
+	}()
+
+	report, err := p.run(ctx, job)
+	status := models.ImportJobCompleted
+	errMsg := ""
+	if err != nil {
+		status, errMsg = models.ImportJobFailed, err.Error()
+	} else if len(report.Errors) > 0 {
+		status, errMsg = models.ImportJobFailed, fmt.Sprintf("%d rows are invalid; nothing was imported", len(report.Errors))
+	}
+
+	if err := p.store.FinishImportJob(job.JobID, status, report, errMsg); err != nil {
+		return fmt.Errorf("failed to record import job %d: %w", job.JobID, err)
+	}
+
+	if status == models.ImportJobCompleted && !job.DryRun && report.CreatedTasks > 0 {
+		msg, _ := json.Marshal(map[string]interface{}{
+			"type": "TASKS_IMPORTED",
+			"data": map[string]int{"team_id": job.TeamID, "created_tasks": report.CreatedTasks, "job_id": job.JobID},
+		})
+		p.wsHub.BroadcastToTeam(job.TeamID, msg)
+
+		activity := models.Activity{
+			TeamID:  job.TeamID,
+			ActorID: job.RequestedBy,
+			Type:    models.ActivityTasksImported,
+			Data:    map[string]interface{}{"source": job.Source, "created_tasks": report.CreatedTasks, "job_id": job.JobID},
+		}
+		if err := p.store.CreateActivity(&activity); err != nil {
+			logs.Log.Errorf("Failed to record import activity for job %d: %v", job.JobID, err)
+		} else {
+			msg, _ := json.Marshal(map[string]interface{}{"type": "ACTIVITY", "data": activity})
+			p.wsHub.BroadcastToTeam(job.TeamID, msg)
+		}
+	}
+
+	logs.Log.Infof("Import job %d %s", job.JobID, status)
+	return nil
+}
+
+func (p *ImportProcessor) run(ctx context.Context, job *models.ImportJob) (*models.ImportReport, error) {
+	src, err := p.storage.Get(ctx, job.StorageKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the uploaded file: %w", err)
+	}
+	data, err := io.ReadAll(src)
+	src.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the uploaded file: %w", err)
+	}
+
+	var parsed *importer.Result
+	switch job.Source {
+	case importer.SourceTrello:
+		parsed, err = importer.ParseTrello(data, job.UserMap)
+	case importer.SourceJira:
+		parsed, err = importer.ParseJira(data, job.UserMap)
+	default:
+		err = fmt.Errorf("unknown import source %q", job.Source)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.ImportReport{
+		Source:         job.Source,
+		Skipped:        parsed.Skipped,
+		UnmatchedUsers: []string{},
+		Warnings:       parsed.Warnings,
+	}
+	if report.Warnings == nil {
+		report.Warnings = []string{}
+	}
+
+	// People who are not in the team are dropped rather than failing the import
+	members, err := p.store.GetTeamMemberEmails(job.TeamID)
+	if err != nil {
+		return nil, err
+	}
+	unmatched := map[string]bool{}
+	for i := range parsed.Tasks {
+		t := &parsed.Tasks[i]
+		if t.AssigneeEmail != "" && !members[strings.ToLower(t.AssigneeEmail)] {
+			unmatched[t.AssigneeEmail] = true
+			t.AssigneeEmail = ""
+		}
+	}
+	for email := range unmatched {
+		report.UnmatchedUsers = append(report.UnmatchedUsers, email)
+	}
+	sort.Strings(report.UnmatchedUsers)
+
+	if err := p.store.StartImportJob(job.JobID, len(parsed.Tasks)); err != nil {
+		return nil, err
+	}
+
+	result, err := p.store.ImportTasksWithProgress(job.TeamID, job.RequestedBy, parsed.Tasks, job.DryRun, func(done int) {
+		if err := p.store.SetImportJobProgress(job.JobID, done); err != nil {
+			logs.Log.Warnf("Failed to record progress of import job %d: %v", job.JobID, err)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report.ImportResult = *result
+	return report, nil
+}
@@ -0,0 +1,114 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/drumilbhati/teamsync/ai"
+	"github.com/drumilbhati/teamsync/logs"
+	"github.com/drumilbhati/teamsync/models"
+	"github.com/drumilbhati/teamsync/store"
+	"github.com/hibiken/asynq"
+)
+
+// Unique name for task type
+const TypeCopilotSummary = "copilot:summary"
+
+type CopilotSummaryPayload struct {
+	Kind        string    `json:"kind"`
+	TaskID      int       `json:"task_id,omitempty"`
+	ChannelID   int       `json:"channel_id,omitempty"`
+	Since       time.Time `json:"since,omitempty"`
+	TeamID      int       `json:"team_id"`
+	RequestedBy int       `json:"requested_by"`
+	ContentHash string    `json:"content_hash"`
+}
+
+/*	Producer Logic (Used by controller)	 */
+
+// NewCopilotSummaryTask creates a task to summarise a comment thread or chat.
+// Identical payloads are deduplicated while a summary is being generated.
+func NewCopilotSummaryTask(payload CopilotSummaryPayload) (*asynq.Task, error) {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(TypeCopilotSummary, payloadBytes,
+		asynq.Unique(5*time.Minute),
+		asynq.MaxRetry(3),
+		asynq.Timeout(2*time.Minute),
+	), nil
+}
+
+/*	Consumer Logic (Used by Background Worker) */
+
+type SummaryProcessor struct {
+	store    *store.Store
+	provider ai.Provider
+}
+
+func NewSummaryProcessor(s *store.Store, p ai.Provider) *SummaryProcessor {
+	return &SummaryProcessor{store: s, provider: p}
+}
+
+// ProcessTask rebuilds the transcript, summarises it and caches the result under its content hash
+func (p *SummaryProcessor) ProcessTask(ctx context.Context, t *asynq.Task) error {
+	var payload CopilotSummaryPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("json.Unmarshal failed%v: %w", err, asynq.SkipRetry)
+	}
+
+	var transcript string
+	var err error
+	switch payload.Kind {
+	case models.SummaryKindTask:
+		transcript, _, err = p.store.BuildTaskThreadTranscript(payload.TaskID)
+	case models.SummaryKindChat:
+		transcript, _, err = p.store.BuildChatTranscript(payload.ChannelID, payload.Since)
+	default:
+		return fmt.Errorf("unknown summary kind %q: %w", payload.Kind, asynq.SkipRetry)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to build transcript: %w", err)
+	}
+
+	// The content may have changed since the job was queued; cache under the current hash
+	hash := store.SummaryContentHash(payload.Kind, transcript)
+	if _, ok, err := p.store.GetCachedSummary(hash); err == nil && ok {
+		return nil
+	}
+
+	meter := ai.NewMeter(p.provider)
+	start := time.Now()
+	summary, err := store.Summarize(ctx, meter, payload.Kind, transcript)
+
+	call := models.CopilotCall{
+		UserID:    payload.RequestedBy,
+		TeamID:    payload.TeamID,
+		TaskID:    payload.TaskID,
+		Feature:   models.CopilotFeatureSummary,
+		Model:     p.provider.Name(),
+		LatencyMs: int(time.Since(start).Milliseconds()),
+		Outcome:   store.CopilotOutcome(err),
+	}
+	call.PromptTokens, call.CompletionTokens = meter.Tokens()
+	if err != nil {
+		call.Error = err.Error()
+	}
+	if err := p.store.CreateCopilotCall(&call); err != nil {
+		logs.Log.Errorf("Failed to record copilot call: %v", err)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to summarise: %w", err)
+	}
+
+	if err := p.store.SetCachedSummary(hash, summary); err != nil {
+		return fmt.Errorf("failed to cache summary: %w", err)
+	}
+
+	logs.Log.Infof("Generated %s summary %s", payload.Kind, hash[:12])
+	return nil
+}
@@ -0,0 +1,152 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+
+	_ "image/gif"
+	_ "image/png"
+
+	"github.com/drumilbhati/teamsync/logs"
+	"github.com/drumilbhati/teamsync/storage"
+	"github.com/drumilbhati/teamsync/store"
+	"github.com/hibiken/asynq"
+)
+
+// Unique name for task type
+const TypeAttachmentThumbnail = "attachment:thumbnail"
+
+const (
+	// Longest side of a generated thumbnail in pixels
+	thumbnailSize = 256
+
+	// Images larger than this are not decoded, to bound worker memory
+	maxThumbnailSourcePixels = 40_000_000
+)
+
+type AttachmentThumbnailPayload struct {
+	AttachmentID int `json:"attachment_id"`
+}
+
+/*	Producer Logic (Used by controller)	 */
+
+// NewAttachmentThumbnailTask creates a task to generate a thumbnail for an image attachment
+func NewAttachmentThumbnailTask(attachmentID int) (*asynq.Task, error) {
+	payloadBytes, err := json.Marshal(AttachmentThumbnailPayload{AttachmentID: attachmentID})
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(TypeAttachmentThumbnail, payloadBytes), nil
+}
+
+/*	Consumer Logic (Used by Background Worker) */
+
+type ThumbnailProcessor struct {
+	store   *store.Store
+	storage storage.Storage
+}
+
+func NewThumbnailProcessor(s *store.Store, st storage.Storage) *ThumbnailProcessor {
+	return &ThumbnailProcessor{store: s, storage: st}
+}
+
+// ProcessTask downloads the attachment, scales it down and stores a JPEG thumbnail next to it
+func (p *ThumbnailProcessor) ProcessTask(ctx context.Context, t *asynq.Task) error {
+	var payload AttachmentThumbnailPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("json.Unmarshal failed%v: %w", err, asynq.SkipRetry)
+	}
+
+	attachment, err := p.store.GetAttachmentByID(payload.AttachmentID)
+	if err != nil {
+		return fmt.Errorf("attachment %d not found: %v: %w", payload.AttachmentID, err, asynq.SkipRetry)
+	}
+
+	src, err := p.storage.Get(ctx, attachment.StorageKey)
+	if err != nil {
+		return fmt.Errorf("failed to read attachment %d: %w", attachment.AttachmentID, err)
+	}
+	data, err := io.ReadAll(src)
+	src.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read attachment %d: %w", attachment.AttachmentID, err)
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("unsupported image for attachment %d: %v: %w", attachment.AttachmentID, err, asynq.SkipRetry)
+	}
+	if cfg.Width*cfg.Height > maxThumbnailSourcePixels {
+		return fmt.Errorf("image for attachment %d is too large to thumbnail: %w", attachment.AttachmentID, asynq.SkipRetry)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to decode attachment %d: %v: %w", attachment.AttachmentID, err, asynq.SkipRetry)
+	}
+
+	var out bytes.Buffer
+	if err := jpeg.Encode(&out, scaleDown(img, thumbnailSize), &jpeg.Options{Quality: 80}); err != nil {
+		return fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+
+	key := attachment.StorageKey + ".thumb.jpg"
+	if err := p.storage.Put(ctx, key, &out, int64(out.Len()), "image/jpeg"); err != nil {
+		return fmt.Errorf("failed to store thumbnail: %w", err)
+	}
+
+	if err := p.store.SetAttachmentThumbnail(attachment.AttachmentID, key); err != nil {
+		return fmt.Errorf("failed to save thumbnail key: %w", err)
+	}
+
+	logs.Log.Infof("Generated thumbnail for attachment %d", attachment.AttachmentID)
+	return nil
+}
+
+// scaleDown fits img into a size x size box by averaging the source pixels
+// covered by each target pixel, flattening transparency onto white
+func scaleDown(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	tw, th := w, h
+	if w > size || h > size {
+		if w >= h {
+			tw, th = size, max(1, h*size/w)
+		} else {
+			tw, th = max(1, w*size/h), size
+		}
+	}
+
+	flat := image.NewRGBA(b)
+	draw.Draw(flat, b, image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, b, img, b.Min, draw.Over)
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := b.Min.Y+y*h/th, b.Min.Y+max((y+1)*h/th, y*h/th+1)
+		for x := 0; x < tw; x++ {
+			x0, x1 := b.Min.X+x*w/tw, b.Min.X+max((x+1)*w/tw, x*w/tw+1)
+
+			var r, g, bl, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := flat.RGBAAt(sx, sy)
+					r += uint64(c.R)
+					g += uint64(c.G)
+					bl += uint64(c.B)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{uint8(r / n), uint8(g / n), uint8(bl / n), 255})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,71 @@
+package worker
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/drumilbhati/teamsync/logs"
+	"github.com/drumilbhati/teamsync/storage"
+	"github.com/drumilbhati/teamsync/store"
+	"github.com/hibiken/asynq"
+)
+
+// Unique name for task type
+const TypeTrashPurge = "trash:purge"
+
+// TrashPurgeSchedule is the cron spec the purge job is scheduled with
+const TrashPurgeSchedule = "@hourly"
+
+const defaultTrashRetentionDays = 30
+
+// TrashRetention is how long deleted items stay in the trash, set in days by TRASH_RETENTION_DAYS
+func TrashRetention() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS"))
+	if err != nil || days <= 0 {
+		days = defaultTrashRetentionDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+/*	Producer Logic (Used by scheduler)	 */
+
+// NewTrashPurgeTask creates the periodic purge task. Every replica runs the
+// scheduler, so the task is unique for most of the schedule's interval.
+func NewTrashPurgeTask() (*asynq.Task, error) {
+	return asynq.NewTask(TypeTrashPurge, nil,
+		asynq.MaxRetry(3),
+		asynq.Unique(50*time.Minute),
+	), nil
+}
+
+/*	Consumer Logic (Used by Background Worker) */
+
+type TrashPurgeProcessor struct {
+	store   *store.Store
+	storage storage.Storage
+}
+
+func NewTrashPurgeProcessor(s *store.Store, st storage.Storage) *TrashPurgeProcessor {
+	return &TrashPurgeProcessor{store: s, storage: st}
+}
+
+// ProcessTask permanently deletes items that outlived the retention period, then their attachment files
+func (p *TrashPurgeProcessor) ProcessTask(ctx context.Context, t *asynq.Task) error {
+	purged, keys, err := p.store.PurgeTrash(time.Now().Add(-TrashRetention()))
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if err := p.storage.Delete(ctx, key); err != nil && err != storage.ErrNotFound {
+			logs.Log.Warnf("Failed to delete purged attachment %s: %v", key, err)
+		}
+	}
+
+	if purged > 0 {
+		logs.Log.Infof("Purged %d items and %d files from the trash", purged, len(keys))
+	}
+	return nil
+}
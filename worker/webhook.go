@@ -0,0 +1,377 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	mathrand "math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/drumilbhati/teamsync/logs"
+	"github.com/drumilbhati/teamsync/models"
+	"github.com/drumilbhati/teamsync/store"
+	"github.com/hibiken/asynq"
+)
+
+// Unique name for task type
+const TypeWebhookDelivery = "webhook:deliver"
+
+const (
+	// A delivery is attempted webhookMaxRetry+1 times before it is dead-lettered
+	webhookMaxRetry = 8
+
+	// Retries back off exponentially from webhookBaseDelay, capped at webhookMaxDelay
+	webhookBaseDelay = 30 * time.Second
+	webhookMaxDelay  = 6 * time.Hour
+
+	webhookRequestTimeout = 10 * time.Second
+)
+
+// Headers sent with every delivery
+const (
+	WebhookEventHeader     = "X-TeamSync-Event"
+	WebhookDeliveryHeader  = "X-TeamSync-Delivery"
+	WebhookSignatureHeader = "X-TeamSync-Signature-256"
+)
+
+type WebhookDeliveryPayload struct {
+	DeliveryID int `json:"delivery_id"`
+}
+
+/*
+WebhookEvent is the body of every delivery. ID identifies the event and is
+kept when a delivery is replayed, so receivers can discard repeats.
+*/
+type WebhookEvent struct {
+	ID        string          `json:"id"`
+	Event     string          `json:"event"`
+	TeamID    int             `json:"team_id"`
+	Timestamp time.Time       `json:"timestamp"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// SignWebhookPayload returns the signature header value for a body: the hex
+// HMAC-SHA256 of the exact bytes, keyed by the webhook's secret
+func SignWebhookPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+var ErrWebhookAddressNotAllowed = errors.New("webhook URL must not point at a private, loopback or link-local address")
+
+// Shared address space (carrier-grade NAT) and the NAT64 prefix, which can
+// translate to any IPv4 address including private ones
+var (
+	_, sharedAddressSpace, _ = net.ParseCIDR("100.64.0.0/10")
+	_, nat64Prefix, _        = net.ParseCIDR("64:ff9b::/96")
+)
+
+// allowedWebhookIP reports whether deliveries may be sent to ip. Private,
+// shared, NAT64, loopback, link-local (including cloud metadata at
+// 169.254.169.254) and unspecified addresses are refused so webhooks cannot
+// reach internal services.
+func allowedWebhookIP(ip net.IP) bool {
+	return !(ip.IsPrivate() || ip.IsLoopback() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		sharedAddressSpace.Contains(ip) || nat64Prefix.Contains(ip))
+}
+
+/*
+CheckWebhookURL resolves the URL's host and fails with
+ErrWebhookAddressNotAllowed when any of its addresses may not receive
+deliveries. The host is checked again on every connection, as its DNS
+records can change after the webhook is saved.
+*/
+func CheckWebhookURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("cannot resolve webhook host %s", u.Hostname())
+	}
+	for _, addr := range addrs {
+		if !allowedWebhookIP(addr.IP) {
+			return ErrWebhookAddressNotAllowed
+		}
+	}
+	return nil
+}
+
+// webhookDialControl runs after DNS resolution, so it sees the address a
+// delivery or a redirect actually connects to
+func webhookDialControl(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !allowedWebhookIP(ip) {
+		return ErrWebhookAddressNotAllowed
+	}
+	return nil
+}
+
+/*	Producer Logic (Used by controller)	 */
+
+// NewWebhookDeliveryTask creates a task to deliver a recorded webhook delivery
+func NewWebhookDeliveryTask(deliveryID int) (*asynq.Task, error) {
+	payloadBytes, err := json.Marshal(WebhookDeliveryPayload{DeliveryID: deliveryID})
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(TypeWebhookDelivery, payloadBytes,
+		asynq.MaxRetry(webhookMaxRetry),
+		asynq.Timeout(time.Minute),
+	), nil
+}
+
+// RetryDelay backs webhook deliveries off exponentially with jitter and
+// leaves every other task type on asynq's default schedule
+func RetryDelay(n int, err error, t *asynq.Task) time.Duration {
+	if t.Type() != TypeWebhookDelivery {
+		return asynq.DefaultRetryDelayFunc(n, err, t)
+	}
+
+	delay := time.Duration(float64(webhookBaseDelay) * math.Pow(2, float64(n)))
+	if delay > webhookMaxDelay || delay <= 0 {
+		delay = webhookMaxDelay
+	}
+	return delay + time.Duration(mathrand.Int64N(int64(delay/10)+1))
+}
+
+/*
+WebhookDispatcher turns team events into webhook deliveries. It is
+registered as a websocket hub listener so every frame broadcast to a team
+is also offered to the team's webhooks.
+*/
+type WebhookDispatcher struct {
+	store  *store.Store
+	client *asynq.Client
+}
+
+func NewWebhookDispatcher(s *store.Store, c *asynq.Client) *WebhookDispatcher {
+	return &WebhookDispatcher{store: s, client: c}
+}
+
+// OnTeamBroadcast queues deliveries for a team broadcast without blocking the broadcaster
+func (d *WebhookDispatcher) OnTeamBroadcast(teamID int, message []byte) {
+	go d.dispatch(teamID, message)
+}
+
+func (d *WebhookDispatcher) dispatch(teamID int, message []byte) {
+	var msg struct {
+		Type string          `json:"type"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(message, &msg); err != nil || msg.Type == "" {
+		return
+	}
+
+	// Other team frames, such as ACTIVITY, are not webhook events
+	if !slices.Contains(models.WebhookEvents, msg.Type) {
+		return
+	}
+
+	webhooks, err := d.store.GetWebhooksForEvent(teamID, msg.Type)
+	if err != nil {
+		logs.Log.Errorf("Failed to load webhooks of team %d: %v", teamID, err)
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+
+	payload, err := NewWebhookEventPayload(msg.Type, teamID, msg.Data)
+	if err != nil {
+		logs.Log.Errorf("Failed to build %s webhook payload: %v", msg.Type, err)
+		return
+	}
+
+	for _, wh := range webhooks {
+		if _, err := d.Send(wh.WebhookID, msg.Type, payload); err != nil {
+			logs.Log.Errorf("Failed to queue %s for webhook %d: %v", msg.Type, wh.WebhookID, err)
+		}
+	}
+}
+
+// NewWebhookEventPayload builds the body of a new event with a fresh event ID
+func NewWebhookEventPayload(event string, teamID int, data interface{}) (string, error) {
+	raw, ok := data.(json.RawMessage)
+	if !ok {
+		b, err := json.Marshal(data)
+		if err != nil {
+			return "", err
+		}
+		raw = b
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	body, err := json.Marshal(WebhookEvent{
+		ID:        hex.EncodeToString(id),
+		Event:     event,
+		TeamID:    teamID,
+		Timestamp: time.Now().UTC(),
+		Data:      raw,
+	})
+	return string(body), err
+}
+
+// Send records a delivery of payload to the webhook and queues it
+func (d *WebhookDispatcher) Send(webhookID int, event, payload string) (*models.WebhookDelivery, error) {
+	delivery := models.WebhookDelivery{
+		WebhookID: webhookID,
+		Event:     event,
+		Payload:   payload,
+	}
+	if err := d.store.CreateWebhookDelivery(&delivery); err != nil {
+		return nil, err
+	}
+
+	task, err := NewWebhookDeliveryTask(delivery.DeliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.client.Enqueue(task); err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+/*	Consumer Logic (Used by Background Worker) */
+
+type WebhookProcessor struct {
+	store  *store.Store
+	client *http.Client
+}
+
+func NewWebhookProcessor(s *store.Store) *WebhookProcessor {
+	dialer := &net.Dialer{Timeout: webhookRequestTimeout, Control: webhookDialControl}
+	return &WebhookProcessor{
+		store: s,
+		client: &http.Client{
+			Timeout: webhookRequestTimeout,
+			// No proxy: the dialer must see the receiver's address
+			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: webhookRequestTimeout,
+			},
+		},
+	}
+}
+
+/*
+ProcessTask posts the delivery's payload to the webhook URL and records the
+outcome. Any response other than 2xx is retried; once the retries are used
+up the delivery is marked dead and can only be sent again by a replay.
+*/
+func (p *WebhookProcessor) ProcessTask(ctx context.Context, t *asynq.Task) error {
+	var payload WebhookDeliveryPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("json.Unmarshal failed%v: %w", err, asynq.SkipRetry)
+	}
+
+	delivery, err := p.store.GetWebhookDeliveryByID(payload.DeliveryID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("webhook delivery %d not found: %w", payload.DeliveryID, asynq.SkipRetry)
+	}
+	if err != nil {
+		return err
+	}
+	if delivery.Status == models.WebhookDeliveryDelivered {
+		return nil
+	}
+
+	webhook, err := p.store.GetWebhookByID(delivery.WebhookID)
+	if err != nil || !webhook.Active {
+		p.record(delivery.DeliveryID, models.WebhookDeliveryDead, 0, "webhook deleted or disabled")
+		return fmt.Errorf("webhook %d is not active: %w", delivery.WebhookID, asynq.SkipRetry)
+	}
+
+	status, err := p.post(ctx, webhook, delivery)
+	if err == nil {
+		p.record(delivery.DeliveryID, models.WebhookDeliveryDelivered, status, "")
+		return nil
+	}
+
+	retried, _ := asynq.GetRetryCount(ctx)
+	maxRetry, _ := asynq.GetMaxRetry(ctx)
+	if retried >= maxRetry {
+		p.record(delivery.DeliveryID, models.WebhookDeliveryDead, status, webhookFailure(err))
+		logs.Log.Warnf("Webhook delivery %d is dead after %d attempts: %v", delivery.DeliveryID, retried+1, err)
+	} else {
+		p.record(delivery.DeliveryID, models.WebhookDeliveryRetrying, status, webhookFailure(err))
+	}
+	return err
+}
+
+var errWebhookStatus = errors.New("webhook responded with a non-2xx status")
+
+// webhookFailure describes a failed attempt for the delivery log without
+// echoing the receiver's response or internal network details
+func webhookFailure(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, errWebhookStatus):
+		return errWebhookStatus.Error()
+	case errors.Is(err, ErrWebhookAddressNotAllowed):
+		return ErrWebhookAddressNotAllowed.Error()
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "webhook request timed out"
+	default:
+		return "webhook request failed"
+	}
+}
+
+// post sends the delivery and returns the response status, if any
+func (p *WebhookProcessor) post(ctx context.Context, webhook *models.Webhook, delivery *models.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "TeamSync-Webhook/1.0")
+	req.Header.Set(WebhookEventHeader, delivery.Event)
+	req.Header.Set(WebhookDeliveryHeader, strconv.Itoa(delivery.DeliveryID))
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(webhook.Secret, body))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("%w: %s", errWebhookStatus, resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func (p *WebhookProcessor) record(deliveryID int, status string, responseStatus int, lastError string) {
+	if err := p.store.RecordWebhookAttempt(deliveryID, status, responseStatus, lastError); err != nil {
+		logs.Log.Errorf("Failed to record webhook delivery %d: %v", deliveryID, err)
+	}
+}
@@ -0,0 +1,106 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAllowedWebhookIP(t *testing.T) {
+	tests := []struct {
+		ip      string
+		allowed bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"fd00::1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"100.127.255.254", false},
+		{"100.63.255.255", true},
+		{"100.128.0.1", true},
+		{"64:ff9b::a9fe:a9fe", false},
+		{"64:ff9b::5db8:d822", false},
+	}
+
+	for _, tt := range tests {
+		if got := allowedWebhookIP(net.ParseIP(tt.ip)); got != tt.allowed {
+			t.Errorf("allowedWebhookIP(%s) = %v, want %v", tt.ip, got, tt.allowed)
+		}
+	}
+}
+
+func TestCheckWebhookURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://93.184.216.34/hook", true},
+		{"http://127.0.0.1:8080/hook", false},
+		{"http://localhost/hook", false},
+		{"http://169.254.169.254/latest/meta-data/", false},
+		{"http://[::1]/hook", false},
+		{"http://0.0.0.0/hook", false},
+	}
+
+	for _, tt := range tests {
+		err := CheckWebhookURL(context.Background(), tt.url)
+		if tt.allowed && err != nil {
+			t.Errorf("CheckWebhookURL(%s) = %v, want nil", tt.url, err)
+		}
+		if !tt.allowed && !errors.Is(err, ErrWebhookAddressNotAllowed) {
+			t.Errorf("CheckWebhookURL(%s) = %v, want %v", tt.url, err, ErrWebhookAddressNotAllowed)
+		}
+	}
+}
+
+func TestWebhookClientRefusesLocalAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("delivery reached a loopback address")
+	}))
+	defer srv.Close()
+
+	// The check runs at dial time, so it holds even for a URL saved before
+	// its host started resolving to a local address
+	p := NewWebhookProcessor(nil)
+	resp, err := p.client.Post(srv.URL, "application/json", nil)
+	if err == nil {
+		resp.Body.Close()
+	}
+	if !errors.Is(err, ErrWebhookAddressNotAllowed) {
+		t.Errorf("post returned %v, want %v", err, ErrWebhookAddressNotAllowed)
+	}
+}
+
+func TestWebhookFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"status", fmt.Errorf("%w: 500 Internal Server Error", errWebhookStatus), errWebhookStatus.Error()},
+		{"blocked address", &net.OpError{Op: "dial", Err: ErrWebhookAddressNotAllowed}, ErrWebhookAddressNotAllowed.Error()},
+		{"timeout", &net.DNSError{Err: "i/o timeout", Name: "10.0.0.5", IsTimeout: true}, "webhook request timed out"},
+		{"other", errors.New("dial tcp 10.0.0.5:80: connection refused"), "webhook request failed"},
+	}
+
+	for _, tt := range tests {
+		if got := webhookFailure(tt.err); got != tt.want {
+			t.Errorf("%s: webhookFailure = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/drumilbhati/teamsync/logs"
+)
+
+// Broker fans hub events out to every API replica. Each replica publishes
+// the events it produces and delivers everything it receives to its own
+// local connections.
+type Broker interface {
+	Publish(ctx context.Context, payload []byte) error
+	Subscribe(ctx context.Context) (<-chan []byte, error)
+	Close() error
+}
+
+var ErrBrokerClosed = errors.New("broker closed")
+
+// memoryBrokerBuffer is how many events a lagging subscriber of a
+// MemoryBroker can fall behind before broadcasts to it are dropped
+const memoryBrokerBuffer = 256
+
+// MemoryBroker is an in-process Broker. Every hub subscribed to the same
+// MemoryBroker behaves like a separate replica, which makes it suitable for
+// single-instance deployments and tests.
+type MemoryBroker struct {
+	mu     sync.Mutex
+	subs   []*memorySubscriber
+	closed bool
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{}
+}
+
+/*
+Publish queues the payload for every subscriber without blocking. When a
+subscriber has fallen memoryBrokerBuffer events behind, further broadcasts
+to it are dropped, but subscription changes are always kept so a lagging
+hub never leaves a connection subscribed to a team or channel it was
+removed from.
+*/
+func (b *MemoryBroker) Publish(ctx context.Context, payload []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrBrokerClosed
+	}
+
+	for _, sub := range b.subs {
+		if !sub.push(payload) {
+			logs.Log.Warnf("Dropping hub broadcast for a subscriber that is %d events behind", memoryBrokerBuffer)
+		}
+	}
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe(ctx context.Context) (<-chan []byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrBrokerClosed
+	}
+
+	sub := &memorySubscriber{
+		out:  make(chan []byte),
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+	b.subs = append(b.subs, sub)
+	go sub.pump()
+	return sub.out, nil
+}
+
+func (b *MemoryBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+
+	for _, sub := range b.subs {
+		close(sub.done)
+	}
+	b.subs = nil
+	return nil
+}
+
+// memorySubscriber hands queued events to a subscriber in order from its own goroutine
+type memorySubscriber struct {
+	out  chan []byte
+	wake chan struct{}
+	done chan struct{}
+
+	mu    sync.Mutex
+	queue [][]byte
+}
+
+// push queues payload, reporting false when it is a broadcast dropped because the queue is full
+func (s *memorySubscriber) push(payload []byte) bool {
+	s.mu.Lock()
+	if len(s.queue) >= memoryBrokerBuffer && isBroadcast(payload) {
+		s.mu.Unlock()
+		return false
+	}
+	s.queue = append(s.queue, payload)
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+func (s *memorySubscriber) pump() {
+	defer close(s.out)
+
+	for {
+		select {
+		case <-s.wake:
+		case <-s.done:
+			return
+		}
+
+		for {
+			s.mu.Lock()
+			if len(s.queue) == 0 {
+				s.mu.Unlock()
+				break
+			}
+			payload := s.queue[0]
+			s.queue[0] = nil
+			s.queue = s.queue[1:]
+			s.mu.Unlock()
+
+			select {
+			case s.out <- payload:
+			case <-s.done:
+				return
+			}
+		}
+	}
+}
+
+// isBroadcast reports whether a hub event only delivers a message, and so
+// can be skipped without leaving the subscriptions of a hub out of date
+func isBroadcast(payload []byte) bool {
+	var ev struct {
+		Op string `json:"op"`
+	}
+	if err := json.Unmarshal(payload, &ev); err != nil {
+		return false
+	}
+	switch ev.Op {
+	case opBroadcastTeam, opBroadcastChannel, opBroadcastUsers:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,222 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newReplicas returns two hubs that share one broker, like two API replicas
+func newReplicas(t *testing.T) (*Hub, *Hub) {
+	t.Helper()
+	broker := NewMemoryBroker()
+	a, err := NewHubWithBroker(broker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewHubWithBroker(broker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { broker.Close() })
+	return a, b
+}
+
+func TestMemoryBrokerRoundTripsOpsBetweenHubs(t *testing.T) {
+	tests := []struct {
+		name     string
+		teams    []int
+		channels []int
+		// change updates subscriptions from hub a, send broadcasts from hub a
+		change func(a *Hub)
+		send   func(a *Hub)
+	}{
+		{
+			name:  "broadcast to team",
+			teams: []int{1},
+			send:  func(a *Hub) { a.BroadcastToTeam(1, []byte("msg")) },
+		},
+		{
+			name:     "broadcast to channel",
+			channels: []int{10},
+			send:     func(a *Hub) { a.BroadcastToChannel(10, []byte("msg")) },
+		},
+		{
+			name: "broadcast to users",
+			send: func(a *Hub) { a.BroadcastToUsers([]int{7}, []byte("msg")) },
+		},
+		{
+			name:   "subscribe team to channel",
+			teams:  []int{3},
+			change: func(a *Hub) { a.SubscribeTeamToChannel(3, 30) },
+			send:   func(a *Hub) { a.BroadcastToChannel(30, []byte("msg")) },
+		},
+		{
+			name:   "subscribe users to channel",
+			change: func(a *Hub) { a.SubscribeUsersToChannel(40, []int{7}) },
+			send:   func(a *Hub) { a.BroadcastToChannel(40, []byte("msg")) },
+		},
+		{
+			name:   "join team",
+			change: func(a *Hub) { a.JoinTeam(5, 7, []int{50}) },
+			send:   func(a *Hub) { a.BroadcastToChannel(50, []byte("msg")) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := newReplicas(t)
+			c := newTestClient(7)
+			b.Register(c, tt.teams, tt.channels)
+			if tt.change != nil {
+				tt.change(a)
+			}
+			tt.send(a)
+
+			if got := string(receive(t, c)); got != "msg" {
+				t.Errorf("got %q, want %q", got, "msg")
+			}
+		})
+	}
+}
+
+func TestMemoryBrokerRoundTripsUnsubscribes(t *testing.T) {
+	tests := []struct {
+		name        string
+		unsubscribe func(a *Hub)
+	}{
+		{"unsubscribe users from channel", func(a *Hub) { a.UnsubscribeUsersFromChannel(10, []int{7}) }},
+		{"remove channel", func(a *Hub) { a.RemoveChannel(10) }},
+		{"leave team", func(a *Hub) { a.LeaveTeam(1, 7, []int{10}) }},
+		{"remove team", func(a *Hub) { a.RemoveTeam(1, []int{10}) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := newReplicas(t)
+			c := newTestClient(7)
+			b.Register(c, []int{1}, []int{10})
+
+			tt.unsubscribe(a)
+			a.BroadcastToChannel(10, []byte("dropped"))
+			// Events are applied in order, so this arrives after the channel broadcast
+			a.BroadcastToUsers([]int{7}, []byte("marker"))
+
+			if got := string(receive(t, c)); got != "marker" {
+				t.Errorf("got %q after unsubscribing, want %q", got, "marker")
+			}
+		})
+	}
+}
+
+func hubEvent(t *testing.T, ev event) []byte {
+	t.Helper()
+	b, err := json.Marshal(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestMemoryBrokerLaggingSubscriber(t *testing.T) {
+	broker := NewMemoryBroker()
+	defer broker.Close()
+
+	stalled, err := broker.Subscribe(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	live, err := broker.Subscribe(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	broadcast := hubEvent(t, event{Op: opBroadcastChannel, ChannelID: 10, Payload: []byte("msg")})
+	unsubscribe := hubEvent(t, event{Op: opUnsubscribeUsersChannel, ChannelID: 10, UserIDs: []int{7}})
+	leave := hubEvent(t, event{Op: opLeaveTeam, TeamID: 1, UserIDs: []int{7}, ChannelIDs: []int{10}})
+	last := hubEvent(t, event{Op: opRemoveChannel, ChannelID: 10})
+
+	var published [][]byte
+	for i := 0; i < 2*memoryBrokerBuffer; i++ {
+		published = append(published, broadcast)
+	}
+	published = append(published, unsubscribe, leave)
+	for i := 0; i < memoryBrokerBuffer; i++ {
+		published = append(published, broadcast)
+	}
+	published = append(published, last)
+
+	// The live subscriber keeps up and receives everything
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, payload := range published {
+			if err := broker.Publish(context.Background(), payload); err != nil {
+				t.Errorf("publish: %v", err)
+				return
+			}
+			if got := <-live; string(got) != string(payload) {
+				t.Errorf("live subscriber got %s, want %s", got, payload)
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("publish blocked on a subscriber that stopped reading")
+	}
+
+	// The stalled subscriber misses broadcasts but keeps subscription changes, in order
+	var broadcasts int
+	var got []string
+	for payload := range stalled {
+		if string(payload) == string(broadcast) {
+			broadcasts++
+			continue
+		}
+		got = append(got, string(payload))
+		if string(payload) == string(last) {
+			break
+		}
+	}
+	if want := []string{string(unsubscribe), string(leave), string(last)}; strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("stalled subscriber got subscription changes %v, want %v", got, want)
+	}
+	if broadcasts < memoryBrokerBuffer || broadcasts > memoryBrokerBuffer+1 {
+		t.Errorf("stalled subscriber got %d broadcasts, want the %d that fit its queue", broadcasts, memoryBrokerBuffer)
+	}
+}
+
+func TestMemoryBrokerClose(t *testing.T) {
+	broker := NewMemoryBroker()
+	sub, err := broker.Subscribe(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := broker.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case _, ok := <-sub:
+		if ok {
+			t.Error("subscription still open after close")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("subscription not closed")
+	}
+	if err := broker.Publish(context.Background(), []byte("msg")); !errors.Is(err, ErrBrokerClosed) {
+		t.Errorf("publish after close returned %v, want %v", err, ErrBrokerClosed)
+	}
+	if _, err := broker.Subscribe(context.Background()); !errors.Is(err, ErrBrokerClosed) {
+		t.Errorf("subscribe after close returned %v, want %v", err, ErrBrokerClosed)
+	}
+	if err := broker.Close(); err != nil {
+		t.Errorf("second close returned %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package ws
+
+import (
+	"sync"
+	"time"
+
+	"github.com/drumilbhati/teamsync/logs"
+	"github.com/gorilla/websocket"
+)
+
+// Heartbeat timings are variables so tests can shorten them
+var (
+	// Time allowed to write a message to the peer
+	writeWait = 10 * time.Second
+
+	// Time allowed to read the next pong message from the peer
+	pongWait = 60 * time.Second
+
+	// Send pings to peer with this period, must be less than pongWait
+	pingPeriod = (pongWait * 9) / 10
+)
+
+const (
+	// Maximum message size allowed from peer
+	maxMessageSize = 32 * 1024
+
+	// Number of outbound messages buffered per connection before it is
+	// considered a slow consumer and evicted
+	sendBufferSize = 256
+)
+
+// Client is a single websocket connection. Outbound messages are queued on
+// send and written by a dedicated writer goroutine, so no other goroutine
+// ever writes to conn and a slow peer cannot stall a broadcast.
+type Client struct {
+	UserID int
+
+	conn *websocket.Conn
+	send chan []byte
+
+	// done is closed when the client is unregistered or evicted
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// team and channel subscriptions, guarded by the hub's mutex
+	teams    map[int]bool
+	channels map[int]bool
+}
+
+func NewClient(conn *websocket.Conn, userID int) *Client {
+	conn.SetReadLimit(maxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	return &Client{
+		UserID:   userID,
+		conn:     conn,
+		send:     make(chan []byte, sendBufferSize),
+		done:     make(chan struct{}),
+		teams:    make(map[int]bool),
+		channels: make(map[int]bool),
+	}
+}
+
+// ReadMessage returns the next data message from the peer. Any message or
+// pong extends the read deadline; a silent peer fails after pongWait.
+func (c *Client) ReadMessage() ([]byte, error) {
+	_, message, err := c.conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	return message, nil
+}
+
+// WritePump writes queued messages and periodic pings until the client is closed
+func (c *Client) WritePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case message := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				c.close()
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				c.close()
+				return
+			}
+		case <-c.done:
+			c.conn.WriteControl(
+				websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+				time.Now().Add(writeWait),
+			)
+			return
+		}
+	}
+}
+
+// enqueue queues a message without blocking, evicting the client when its queue is full
+func (c *Client) enqueue(message []byte) {
+	select {
+	case <-c.done:
+		return
+	default:
+	}
+
+	select {
+	case c.send <- message:
+	default:
+		logs.Log.Warnf("Evicting slow websocket consumer for user %d", c.UserID)
+		c.close()
+	}
+}
+
+func (c *Client) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+}
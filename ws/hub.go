@@ -1,64 +1,408 @@
 package ws
 
 import (
+	"context"
+	"encoding/json"
 	"sync"
 
-	"github.com/gorilla/websocket"
+	"github.com/drumilbhati/teamsync/logs"
 )
 
+// Hub operations that must reach every replica travel through the broker as events
+const (
+	opBroadcastTeam           = "broadcast_team"
+	opBroadcastChannel        = "broadcast_channel"
+	opBroadcastUsers          = "broadcast_users"
+	opSubscribeTeamChannel    = "subscribe_team_channel"
+	opSubscribeUsersChannel   = "subscribe_users_channel"
+	opUnsubscribeUsersChannel = "unsubscribe_users_channel"
+	opRemoveChannel           = "remove_channel"
+	opJoinTeam                = "join_team"
+	opLeaveTeam               = "leave_team"
+	opRemoveTeam              = "remove_team"
+)
+
+type event struct {
+	Op        string `json:"op"`
+	TeamID    int    `json:"team_id,omitempty"`
+	ChannelID int    `json:"channel_id,omitempty"`
+	UserIDs   []int  `json:"user_ids,omitempty"`
+	// ChannelIDs lists the channels affected by a team membership change
+	ChannelIDs []int  `json:"channel_ids,omitempty"`
+	Payload    []byte `json:"payload,omitempty"`
+}
+
 type Hub struct {
-	// teamID -> list of connections
-	teams map[int]map[*websocket.Conn]bool
+	// teamID -> list of clients
+	teams map[int]map[*Client]bool
+
+	// channelID -> list of clients subscribed to the channel's chat
+	channels map[int]map[*Client]bool
+
+	// userID -> list of clients, used for direct and group conversations
+	users map[int]map[*Client]bool
 
 	mu sync.Mutex
+
+	broker Broker
+
+	// Called once per team broadcast, on the replica that sent it
+	teamListeners []func(teamID int, message []byte)
 }
 
+// NewHub creates a hub that only delivers to connections of this process
 func NewHub() *Hub {
-	return &Hub{
-		teams: make(map[int]map[*websocket.Conn]bool),
+	h, _ := NewHubWithBroker(NewMemoryBroker())
+	return h
+}
+
+// NewHubWithBroker creates a hub whose broadcasts and subscription changes
+// are fanned out through the broker to every replica
+func NewHubWithBroker(broker Broker) (*Hub, error) {
+	h := &Hub{
+		teams:    make(map[int]map[*Client]bool),
+		channels: make(map[int]map[*Client]bool),
+		users:    make(map[int]map[*Client]bool),
+		broker:   broker,
+	}
+
+	events, err := broker.Subscribe(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	go h.run(events)
+	return h, nil
+}
+
+// Close stops receiving events from the broker
+func (h *Hub) Close() error {
+	return h.broker.Close()
+}
+
+func (h *Hub) run(events <-chan []byte) {
+	for payload := range events {
+		var ev event
+		if err := json.Unmarshal(payload, &ev); err != nil {
+			logs.Log.Errorf("Error unmarshalling hub event: %v", err)
+			continue
+		}
+		h.apply(ev)
 	}
 }
 
-func (h *Hub) AddUser(conn *websocket.Conn, teamIDs []int) {
+// publish sends an event to every replica, applying it locally if the broker is unavailable
+func (h *Hub) publish(ev event) {
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		logs.Log.Errorf("Error marshalling hub event: %v", err)
+		return
+	}
+
+	if err := h.broker.Publish(context.Background(), payload); err != nil {
+		logs.Log.Errorf("Error publishing hub event: %v", err)
+		h.apply(ev)
+	}
+}
+
+func (h *Hub) apply(ev event) {
+	switch ev.Op {
+	case opBroadcastTeam:
+		h.deliverToTeam(ev.TeamID, ev.Payload)
+	case opBroadcastChannel:
+		h.deliverToChannel(ev.ChannelID, ev.Payload)
+	case opBroadcastUsers:
+		h.deliverToUsers(ev.UserIDs, ev.Payload)
+	case opSubscribeTeamChannel:
+		h.subscribeTeamToChannel(ev.TeamID, ev.ChannelID)
+	case opSubscribeUsersChannel:
+		h.subscribeUsersToChannel(ev.ChannelID, ev.UserIDs)
+	case opUnsubscribeUsersChannel:
+		h.unsubscribeUsersFromChannel(ev.ChannelID, ev.UserIDs)
+	case opRemoveChannel:
+		h.removeChannel(ev.ChannelID)
+	case opJoinTeam:
+		h.joinTeam(ev.TeamID, ev.UserIDs, ev.ChannelIDs)
+	case opLeaveTeam:
+		h.leaveTeam(ev.TeamID, ev.UserIDs, ev.ChannelIDs)
+	case opRemoveTeam:
+		h.removeTeam(ev.TeamID, ev.ChannelIDs)
+	default:
+		logs.Log.Warnf("Unknown hub event: %s", ev.Op)
+	}
+}
+
+// Register starts delivering team, channel and user events to the client
+func (h *Hub) Register(c *Client, teamIDs []int, channelIDs []int) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
 	for _, teamID := range teamIDs {
-		if _, ok := h.teams[teamID]; !ok {
-			h.teams[teamID] = make(map[*websocket.Conn]bool)
+		h.join(teamID, c)
+	}
+
+	for _, channelID := range channelIDs {
+		h.subscribe(channelID, c)
+	}
+
+	if _, ok := h.users[c.UserID]; !ok {
+		h.users[c.UserID] = make(map[*Client]bool)
+	}
+	h.users[c.UserID][c] = true
+}
+
+// Unregister removes the client from every subscription and closes it
+func (h *Hub) Unregister(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for teamID := range c.teams {
+		h.leave(teamID, c)
+	}
+
+	for channelID := range c.channels {
+		h.unsubscribe(channelID, c)
+	}
+
+	if clients, ok := h.users[c.UserID]; ok {
+		delete(clients, c)
+		if len(clients) == 0 {
+			delete(h.users, c.UserID)
 		}
-		h.teams[teamID][conn] = true
 	}
+	c.close()
+}
+
+// SubscribeTeamToChannel subscribes every connection of a team to a public channel
+func (h *Hub) SubscribeTeamToChannel(teamID int, channelID int) {
+	h.publish(event{Op: opSubscribeTeamChannel, TeamID: teamID, ChannelID: channelID})
+}
+
+// SubscribeUsersToChannel subscribes every connection of the given users to a channel
+func (h *Hub) SubscribeUsersToChannel(channelID int, userIDs []int) {
+	h.publish(event{Op: opSubscribeUsersChannel, ChannelID: channelID, UserIDs: userIDs})
+}
+
+// UnsubscribeUsersFromChannel stops delivering a channel's messages to the given users
+func (h *Hub) UnsubscribeUsersFromChannel(channelID int, userIDs []int) {
+	h.publish(event{Op: opUnsubscribeUsersChannel, ChannelID: channelID, UserIDs: userIDs})
 }
 
-func (h *Hub) RemoveUser(conn *websocket.Conn, teamIDs []int) {
+// RemoveChannel drops every subscription of a deleted channel
+func (h *Hub) RemoveChannel(channelID int) {
+	h.publish(event{Op: opRemoveChannel, ChannelID: channelID})
+}
+
+// JoinTeam subscribes the user's open connections to a team they were added to
+// and to the given channels of that team
+func (h *Hub) JoinTeam(teamID int, userID int, channelIDs []int) {
+	h.publish(event{Op: opJoinTeam, TeamID: teamID, UserIDs: []int{userID}, ChannelIDs: channelIDs})
+}
+
+// LeaveTeam unsubscribes the user's open connections from a team they were
+// removed from and from the given channels of that team
+func (h *Hub) LeaveTeam(teamID int, userID int, channelIDs []int) {
+	h.publish(event{Op: opLeaveTeam, TeamID: teamID, UserIDs: []int{userID}, ChannelIDs: channelIDs})
+}
+
+// RemoveTeam drops every subscription of a deleted team and its channels
+func (h *Hub) RemoveTeam(teamID int, channelIDs []int) {
+	h.publish(event{Op: opRemoveTeam, TeamID: teamID, ChannelIDs: channelIDs})
+}
+
+func (h *Hub) BroadcastToTeam(teamID int, message []byte) {
+	h.publish(event{Op: opBroadcastTeam, TeamID: teamID, Payload: message})
+
+	h.mu.Lock()
+	listeners := h.teamListeners
+	h.mu.Unlock()
+
+	for _, fn := range listeners {
+		fn(teamID, message)
+	}
+}
+
+// OnTeamBroadcast registers fn to be called with every message broadcast to a
+// team from this process, e.g. to forward team events outside the websocket.
+// fn runs on the broadcasting goroutine and must not block.
+func (h *Hub) OnTeamBroadcast(fn func(teamID int, message []byte)) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
+	h.teamListeners = append(h.teamListeners, fn)
+}
 
-	for _, teamID := range teamIDs {
-		if members, ok := h.teams[teamID]; ok {
-			delete(members, conn)
-			if len(members) == 0 {
-				delete(h.teams, teamID)
+func (h *Hub) BroadcastToChannel(channelID int, message []byte) {
+	h.publish(event{Op: opBroadcastChannel, ChannelID: channelID, Payload: message})
+}
+
+// BroadcastToUsers delivers a message to every open connection of the given users
+func (h *Hub) BroadcastToUsers(userIDs []int, message []byte) {
+	h.publish(event{Op: opBroadcastUsers, UserIDs: userIDs, Payload: message})
+}
+
+func (h *Hub) subscribeTeamToChannel(teamID int, channelID int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for c := range h.teams[teamID] {
+		h.subscribe(channelID, c)
+	}
+}
+
+func (h *Hub) subscribeUsersToChannel(channelID int, userIDs []int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, userID := range userIDs {
+		for c := range h.users[userID] {
+			h.subscribe(channelID, c)
+		}
+	}
+}
+
+func (h *Hub) unsubscribeUsersFromChannel(channelID int, userIDs []int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, userID := range userIDs {
+		for c := range h.users[userID] {
+			h.unsubscribe(channelID, c)
+		}
+	}
+}
+
+func (h *Hub) removeChannel(channelID int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for c := range h.channels[channelID] {
+		delete(c.channels, channelID)
+	}
+	delete(h.channels, channelID)
+}
+
+func (h *Hub) joinTeam(teamID int, userIDs []int, channelIDs []int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, userID := range userIDs {
+		for c := range h.users[userID] {
+			h.join(teamID, c)
+			for _, channelID := range channelIDs {
+				h.subscribe(channelID, c)
 			}
 		}
 	}
-	conn.Close()
 }
 
-func (h *Hub) BroadcastToTeam(teamID int, message []byte) {
+func (h *Hub) leaveTeam(teamID int, userIDs []int, channelIDs []int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, userID := range userIDs {
+		for c := range h.users[userID] {
+			h.leave(teamID, c)
+			for _, channelID := range channelIDs {
+				h.unsubscribe(channelID, c)
+			}
+		}
+	}
+}
+
+func (h *Hub) removeTeam(teamID int, channelIDs []int) {
 	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for c := range h.teams[teamID] {
+		h.leave(teamID, c)
+	}
 
-	var connections []*websocket.Conn
-	for conn := range h.teams[teamID] {
-		connections = append(connections, conn)
+	for _, channelID := range channelIDs {
+		for c := range h.channels[channelID] {
+			h.unsubscribe(channelID, c)
+		}
+	}
+}
+
+// The helpers below must be called with h.mu held
+
+func (h *Hub) join(teamID int, c *Client) {
+	if _, ok := h.teams[teamID]; !ok {
+		h.teams[teamID] = make(map[*Client]bool)
+	}
+	h.teams[teamID][c] = true
+	c.teams[teamID] = true
+}
+
+func (h *Hub) leave(teamID int, c *Client) {
+	if clients, ok := h.teams[teamID]; ok {
+		delete(clients, c)
+		if len(clients) == 0 {
+			delete(h.teams, teamID)
+		}
+	}
+	delete(c.teams, teamID)
+}
+
+func (h *Hub) subscribe(channelID int, c *Client) {
+	if _, ok := h.channels[channelID]; !ok {
+		h.channels[channelID] = make(map[*Client]bool)
+	}
+	h.channels[channelID][c] = true
+	c.channels[channelID] = true
+}
+
+func (h *Hub) unsubscribe(channelID int, c *Client) {
+	if clients, ok := h.channels[channelID]; ok {
+		delete(clients, c)
+		if len(clients) == 0 {
+			delete(h.channels, channelID)
+		}
+	}
+	delete(c.channels, channelID)
+}
+
+func (h *Hub) deliverToTeam(teamID int, message []byte) {
+	h.mu.Lock()
+
+	var clients []*Client
+	for c := range h.teams[teamID] {
+		clients = append(clients, c)
+	}
+	h.mu.Unlock()
+
+	deliver(clients, message)
+}
+
+func (h *Hub) deliverToChannel(channelID int, message []byte) {
+	h.mu.Lock()
+
+	var clients []*Client
+	for c := range h.channels[channelID] {
+		clients = append(clients, c)
 	}
 	h.mu.Unlock()
 
-	for _, conn := range connections {
-		err := conn.WriteMessage(websocket.TextMessage, message)
-		if err != nil {
-			conn.Close()
+	deliver(clients, message)
+}
+
+func (h *Hub) deliverToUsers(userIDs []int, message []byte) {
+	h.mu.Lock()
+
+	var clients []*Client
+	for _, userID := range userIDs {
+		for c := range h.users[userID] {
+			clients = append(clients, c)
 		}
 	}
+	h.mu.Unlock()
+
+	deliver(clients, message)
+}
+
+// deliver queues the message on each client without blocking on any of them
+func deliver(clients []*Client, message []byte) {
+	for _, c := range clients {
+		c.enqueue(message)
+	}
 }
@@ -0,0 +1,288 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newTestClient returns a client without a connection; its queued messages are read from send
+func newTestClient(userID int) *Client {
+	return &Client{
+		UserID:   userID,
+		send:     make(chan []byte, sendBufferSize),
+		done:     make(chan struct{}),
+		teams:    make(map[int]bool),
+		channels: make(map[int]bool),
+	}
+}
+
+func receive(t *testing.T, c *Client) []byte {
+	t.Helper()
+	select {
+	case msg := <-c.send:
+		return msg
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for a message")
+		return nil
+	}
+}
+
+func isClosed(c *Client) bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// failingBroker accepts subscriptions but fails every publish
+type failingBroker struct {
+	events chan []byte
+}
+
+func (b *failingBroker) Publish(ctx context.Context, payload []byte) error {
+	return errors.New("broker unavailable")
+}
+
+func (b *failingBroker) Subscribe(ctx context.Context) (<-chan []byte, error) {
+	return b.events, nil
+}
+
+func (b *failingBroker) Close() error {
+	close(b.events)
+	return nil
+}
+
+func TestHubConcurrentBroadcastAndRegistration(t *testing.T) {
+	h := NewHub()
+	defer h.Close()
+
+	const teamID, channelID = 1, 10
+	const broadcasters, rounds = 4, 200
+	var wg sync.WaitGroup
+
+	// A connection that stays registered throughout keeps receiving. Its queue
+	// holds the whole burst, so it keeps up however the goroutines are
+	// scheduled; eviction is covered by TestHubEvictsSlowConsumer.
+	stayer := newTestClient(100)
+	stayer.send = make(chan []byte, broadcasters*rounds+sendBufferSize)
+	h.Register(stayer, []int{teamID}, nil)
+	defer h.Unregister(stayer)
+
+	gotAfter := make(chan struct{})
+	go func() {
+		for msg := range stayer.send {
+			if string(msg) == "after" {
+				close(gotAfter)
+				return
+			}
+		}
+	}()
+
+	// Connections come and go while messages are broadcast
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(userID int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c := newTestClient(userID)
+				h.Register(c, []int{teamID}, []int{channelID})
+				h.JoinTeam(teamID+1, userID, []int{channelID + 1})
+				h.LeaveTeam(teamID+1, userID, []int{channelID + 1})
+				h.Unregister(c)
+			}
+		}(i + 1)
+	}
+
+	for i := 0; i < broadcasters; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < rounds; j++ {
+				h.BroadcastToTeam(teamID, []byte("team"))
+				h.BroadcastToChannel(channelID, []byte("channel"))
+				h.BroadcastToUsers([]int{1, 2, 3}, []byte("users"))
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	// The broker may still be draining the burst and shed broadcasts meanwhile, so resend until one arrives
+	retry := time.NewTicker(10 * time.Millisecond)
+	defer retry.Stop()
+	timeout := time.After(2 * time.Second)
+	for received := false; !received; {
+		h.BroadcastToTeam(teamID, []byte("after"))
+		select {
+		case <-gotAfter:
+			received = true
+		case <-retry.C:
+		case <-timeout:
+			t.Fatal("the registered connection stopped receiving broadcasts")
+		}
+	}
+	if isClosed(stayer) {
+		t.Error("a connection that kept up was evicted")
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if n := len(h.teams[teamID]); n != 1 {
+		t.Errorf("team has %d clients after all others unregistered, want 1", n)
+	}
+	if n := len(h.channels[channelID]); n != 0 {
+		t.Errorf("channel has %d clients after they unregistered, want 0", n)
+	}
+}
+
+func TestHubEvictsSlowConsumer(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages int
+		evicted  bool
+	}{
+		{"queue not full", sendBufferSize - 1, false},
+		{"queue exactly full", sendBufferSize, false},
+		{"queue overflows", sendBufferSize + 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHub()
+			defer h.Close()
+
+			slow := newTestClient(1)
+			fast := newTestClient(2)
+			h.Register(slow, []int{1}, nil)
+			h.Register(fast, []int{1}, nil)
+
+			for i := 0; i < tt.messages; i++ {
+				h.deliverToTeam(1, []byte("msg"))
+				// The fast consumer keeps up
+				<-fast.send
+			}
+
+			if got := isClosed(slow); got != tt.evicted {
+				t.Errorf("slow consumer evicted = %v, want %v", got, tt.evicted)
+			}
+			if isClosed(fast) {
+				t.Error("fast consumer was evicted")
+			}
+			if len(slow.send) > sendBufferSize {
+				t.Errorf("slow consumer queued %d messages, more than %d", len(slow.send), sendBufferSize)
+			}
+		})
+	}
+}
+
+func TestHubPublishFallsBackToLocalDelivery(t *testing.T) {
+	h, err := NewHubWithBroker(&failingBroker{events: make(chan []byte)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	c := newTestClient(1)
+	h.Register(c, nil, nil)
+
+	// Subscription changes and broadcasts are applied locally when publishing fails
+	h.JoinTeam(5, 1, []int{50})
+	h.BroadcastToTeam(5, []byte("team"))
+	h.BroadcastToChannel(50, []byte("channel"))
+	h.BroadcastToUsers([]int{1}, []byte("user"))
+
+	for _, want := range []string{"team", "channel", "user"} {
+		if got := receive(t, c); string(got) != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+}
+
+// serveClient upgrades each request and reports how its read loop ended
+func serveClient(t *testing.T) (*httptest.Server, <-chan error) {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	readErr := make(chan error, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		c := NewClient(conn, 1)
+		go c.WritePump()
+		defer c.close()
+
+		for {
+			if _, err := c.ReadMessage(); err != nil {
+				readErr <- err
+				return
+			}
+		}
+	}))
+	return srv, readErr
+}
+
+func dial(t *testing.T, srv *httptest.Server) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func TestClientReadDeadline(t *testing.T) {
+	t.Run("peer answering pings stays connected", func(t *testing.T) {
+		srv, readErr := serveClient(t)
+		defer srv.Close()
+
+		conn := dial(t, srv)
+		defer conn.Close()
+
+		// Reading lets the dialer answer the server's pings with pongs
+		go func() {
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		select {
+		case err := <-readErr:
+			t.Fatalf("connection ended while the peer answered pings: %v", err)
+		case <-time.After(3 * pongWait):
+		}
+	})
+
+	t.Run("silent peer expires", func(t *testing.T) {
+		srv, readErr := serveClient(t)
+		defer srv.Close()
+
+		// Never reading means the server's pings go unanswered
+		conn := dial(t, srv)
+		defer conn.Close()
+
+		select {
+		case err := <-readErr:
+			var netErr interface{ Timeout() bool }
+			if !errors.As(err, &netErr) || !netErr.Timeout() {
+				t.Errorf("read ended with %v, want a timeout", err)
+			}
+		case <-time.After(5 * pongWait):
+			t.Fatal("silent peer was not disconnected after the pong deadline")
+		}
+	})
+}
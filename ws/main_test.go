@@ -0,0 +1,21 @@
+package ws
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/drumilbhati/teamsync/logs"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	logs.Log = zap.NewNop().Sugar()
+
+	// Short heartbeats keep the deadline tests fast
+	pongWait = 300 * time.Millisecond
+	pingPeriod = (pongWait * 9) / 10
+	writeWait = time.Second
+
+	os.Exit(m.Run())
+}
@@ -0,0 +1,64 @@
+package ws
+
+import (
+	"context"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisBroker fans hub events out over a Redis pub/sub channel so that every
+// replica connected to the same Redis receives them.
+type RedisBroker struct {
+	rdb     *redis.Client
+	channel string
+
+	mu      sync.Mutex
+	pubsubs []*redis.PubSub
+}
+
+func NewRedisBroker(rdb *redis.Client, channel string) *RedisBroker {
+	return &RedisBroker{rdb: rdb, channel: channel}
+}
+
+func (b *RedisBroker) Publish(ctx context.Context, payload []byte) error {
+	return b.rdb.Publish(ctx, b.channel, payload).Err()
+}
+
+func (b *RedisBroker) Subscribe(ctx context.Context) (<-chan []byte, error) {
+	pubsub := b.rdb.Subscribe(ctx, b.channel)
+
+	// Wait for the subscription to be confirmed so no events published
+	// after Subscribe returns are missed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	b.mu.Lock()
+	b.pubsubs = append(b.pubsubs, pubsub)
+	b.mu.Unlock()
+
+	out := make(chan []byte, 256)
+	go func() {
+		defer close(out)
+		for msg := range pubsub.Channel() {
+			out <- []byte(msg.Payload)
+		}
+	}()
+	return out, nil
+}
+
+func (b *RedisBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var firstErr error
+	for _, pubsub := range b.pubsubs {
+		if err := pubsub.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	b.pubsubs = nil
+	return firstErr
+}